@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"frag-aggra/internal/alerts"
+	"frag-aggra/internal/database"
+	"frag-aggra/internal/models"
+	"frag-aggra/internal/pubsub"
+	"frag-aggra/internal/routing"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// saved searches and the alert matcher
+//
+//	alerts run
+//	alerts add -name "cheap layton" -pattern "marly layton" -min-ml 100 -max-price 180 -kind discord -target https://discord.com/api/webhooks/...
+//	alerts list
+//	alerts delete -id 3
+func main() {
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd := os.Args[1]
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	repo, err := database.New(ctx, os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	switch cmd {
+	case "run":
+		run(ctx, repo)
+	case "add":
+		add(ctx, repo, os.Args[2:])
+	case "list":
+		list(ctx, repo)
+	case "delete":
+		fs := flag.NewFlagSet("delete", flag.ExitOnError)
+		id := fs.Int64("id", 0, "saved search id")
+		fs.Parse(os.Args[2:])
+		if err := repo.DeleteSavedSearch(ctx, *id); err != nil {
+			log.Fatalf("failed to delete saved search %d: %v", *id, err)
+		}
+		log.Printf("Deleted saved search %d", *id)
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: alerts run|add|list|delete [flags]")
+	os.Exit(2)
+}
+
+// consumes listing events and notifies every saved search they match
+func run(ctx context.Context, repo *database.Repository) {
+	rmqUrl := os.Getenv("RABBITMQ_URL")
+	if rmqUrl == "" {
+		log.Fatal("RABBITMQ_URL not set")
+	}
+	rmq, err := pubsub.New(rmqUrl)
+	if err != nil {
+		log.Fatalf("Failed to innit RabbitMQ Client: %v", err)
+	}
+	defer rmq.Close()
+
+	q, err := rmq.DeclareAndBind(routing.ExchangePostDirect, routing.ListingAlertQueue, routing.ListingKey)
+	if err != nil {
+		log.Fatalf("Failed to declare queue: %v", err)
+	}
+	msgs, err := rmq.ConsumeFromClient(q.Name)
+	if err != nil {
+		log.Fatalf("Error consuming and getting channel: %v", err)
+	}
+
+	sinks := alerts.DefaultSinks()
+	if _, ok := sinks[alerts.SinkSMTP]; !ok {
+		log.Println("SMTP not configured, email alerts are disabled")
+	}
+	matcher := alerts.NewMatcher(repo, sinks)
+
+	// failed sends are retried with backoff, see FinishNotification
+	retry := time.NewTicker(time.Minute)
+	defer retry.Stop()
+
+	log.Println("Alert matcher started")
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Shutting down alert matcher...")
+			return
+		case <-retry.C:
+			n, err := matcher.RetryDue(ctx)
+			if err != nil {
+				log.Printf("failed to retry notifications: %v", err)
+			} else if n > 0 {
+				log.Printf("retried %d failed notifications", n)
+			}
+		case msg, ok := <-msgs:
+			if !ok {
+				log.Println("RabbitMQ channel closed, exiting")
+				return
+			}
+			var event models.ListingEvent
+			if err := json.Unmarshal(msg.Body, &event); err != nil {
+				log.Printf("bad json: %v", err)
+				msg.Nack(false, false)
+				continue
+			}
+			if err := matcher.HandleEvent(ctx, event); err != nil {
+				log.Printf("failed to match listings for post %s: %v", event.RedditID, err)
+				msg.Nack(false, true)
+				continue
+			}
+			msg.Ack(false)
+		}
+	}
+}
+
+func add(ctx context.Context, repo *database.Repository, args []string) {
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	name := fs.String("name", "", "label for the search")
+	pattern := fs.String("pattern", "", "words that must all appear in the perfume name")
+	minML := fs.Float64("min-ml", 0, "minimum ml left in the bottle")
+	maxML := fs.Float64("max-ml", 0, "maximum ml left in the bottle")
+	kind := fs.String("bottle", "", "full, partial or decant")
+	maxPrice := fs.Float64("max-price", 0, "maximum price in dollars")
+	blocklist := fs.String("block", "", "comma separated sellers to ignore")
+	notifyKind := fs.String("kind", alerts.SinkWebhook, "webhook, discord or smtp")
+	target := fs.String("target", "", "webhook url or email address")
+	fs.Parse(args)
+
+	if *pattern == "" || *target == "" {
+		log.Fatal("-pattern and -target are required")
+	}
+	switch *notifyKind {
+	case alerts.SinkWebhook, alerts.SinkDiscord, alerts.SinkSMTP:
+	default:
+		log.Fatalf("-kind must be %s, %s or %s, got %q", alerts.SinkWebhook, alerts.SinkDiscord, alerts.SinkSMTP, *notifyKind)
+	}
+	if *name == "" {
+		*name = *pattern
+	}
+
+	s := models.SavedSearch{
+		Name:         *name,
+		NamePattern:  *pattern,
+		NotifyKind:   *notifyKind,
+		NotifyTarget: *target,
+	}
+	if *minML > 0 {
+		s.MinML = minML
+	}
+	if *maxML > 0 {
+		s.MaxML = maxML
+	}
+	if *kind != "" {
+		s.BottleKind = kind
+	}
+	if *maxPrice > 0 {
+		cents := int64(math.Round(*maxPrice * 100))
+		s.MaxPriceCents = &cents
+	}
+	for _, seller := range strings.Split(*blocklist, ",") {
+		if seller = strings.TrimSpace(seller); seller != "" {
+			s.SellerBlocklist = append(s.SellerBlocklist, seller)
+		}
+	}
+
+	id, err := repo.CreateSavedSearch(ctx, s)
+	if err != nil {
+		log.Fatalf("failed to save search: %v", err)
+	}
+	log.Printf("Created saved search %d", id)
+}
+
+func list(ctx context.Context, repo *database.Repository) {
+	searches, err := repo.ListSavedSearches(ctx, false)
+	if err != nil {
+		log.Fatalf("failed to list saved searches: %v", err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(searches); err != nil {
+		log.Fatalf("failed to print saved searches: %v", err)
+	}
+}
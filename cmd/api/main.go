@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"frag-aggra/internal/api"
+	"frag-aggra/internal/database"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	addr := os.Getenv("API_ADDR")
+	if addr == "" {
+		addr = ":8080"
+	}
+
+	repo, err := database.New(ctx, os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	if err := repo.Ping(ctx); err != nil {
+		log.Fatalf("failed to ping database: %v", err)
+	}
+	log.Println("Database connection verified")
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           api.New(repo),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Printf("API listening on %s", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("api server failed: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down api...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("api shutdown error: %v", err)
+	}
+}
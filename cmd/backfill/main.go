@@ -42,8 +42,16 @@ func main() {
 		limitInt = 5
 	}
 
+	sources := scraper.DefaultSources(limitInt)
+	if sourcesPath := os.Getenv("SOURCES_FILE"); sourcesPath != "" {
+		sources, err = scraper.LoadSources(sourcesPath, limitInt)
+		if err != nil {
+			log.Fatalf("Failed to load sources: %v", err)
+		}
+	}
+
 	// init a scraper
-	scraper, err := scraper.New()
+	reddit, err := scraper.New()
 	if err != nil {
 		log.Fatalf("Failed to init reddit scraper: %v", err)
 	}
@@ -62,43 +70,10 @@ func main() {
 	key := routing.PostKey
 	queue := routing.PostQueue
 
-	// declare the exchange
+	// declare the exchange, queue and binding
 	// safe to do indempotently
-	err = rmq.Channel.ExchangeDeclare(
-		exchange, //name
-		"direct", //type
-		true,     // durability
-		false,    // autoDelete
-		false,    // internal?
-		false,    //no wait
-		nil,
-	)
-	if err != nil {
-		log.Printf("Error declaring the exchange: %v", err)
-	}
-
-	//declare the queue
-	q, err := rmq.Channel.QueueDeclare(
-		queue, //queue name
-		true,  //durable
-		false, //delete when unused
-		false, //exclusive
-		false, //nowait
-		nil,
-	)
-	if err != nil {
-		log.Fatalf("Failed to declare queue: %v", err)
-	}
-
-	//Bind the queue
-	err = rmq.Channel.QueueBind(
-		q.Name,
-		key,
-		exchange,
-		false, nil,
-	)
-	if err != nil {
-		log.Fatalf("Failed to bind queue with exchange: %v", err)
+	if _, err := rmq.DeclareAndBind(exchange, queue, key); err != nil {
+		log.Fatalf("Failed to declare post topology: %v", err)
 	}
 
 	// jobPostings, err := scraper.FetchPost("fragranceswap", *repo, limitInt)
@@ -109,15 +84,30 @@ func main() {
 	// grab a cut off date
 	cutoffDate := time.Now().Add(-14 * 24 * time.Hour) // 2 weeks ago
 	maxPostLimit := 1000
+
+	totalPublished := 0
+	for _, src := range sources {
+		totalPublished += backfillSource(ctx, reddit, rmq, exchange, key, src, cutoffDate, maxPostLimit)
+	}
+
+	log.Printf("Backfill complete. Published %d total jobs", totalPublished)
+
+}
+
+// publishes up to maxPostLimit tagged posts from one source, newest first,
+// stopping at the cutoff date
+func backfillSource(ctx context.Context, reddit *scraper.RedditScraper, rmq *pubsub.RabbitMQClient, exchange, key string,
+	src scraper.Source, cutoffDate time.Time, maxPostLimit int) int {
+
 	totalPublished := 0
 	afterToken := ""
 
-	log.Printf("Starting backfill. Cutoff date: %s, Max posts: %d", cutoffDate.Format(time.RFC3339), maxPostLimit)
+	log.Printf("Starting backfill of r/%s (%s). Cutoff date: %s, Max posts: %d", src.Subreddit, src.Name, cutoffDate.Format(time.RFC3339), maxPostLimit)
 
 	for totalPublished < maxPostLimit {
 		log.Printf("Fetching page of posts (after: %s)...", afterToken)
 
-		posts, err := scraper.FetchPaginatedPosts(ctx, "fragranceswap", limitInt, afterToken)
+		posts, err := reddit.FetchPaginatedPosts(ctx, src.Subreddit, src.FetchLimit, afterToken)
 		if err != nil {
 			log.Printf("Error fetching historical posts, stopping: %v", err)
 			break
@@ -145,9 +135,10 @@ func main() {
 				break
 			}
 
-			//check if post has the wts tag to filter it out
-			if !scraper.ContainsWTS(post.Title) && !scraper.ContainsWTS(post.Body) {
-				log.Printf("Skipping post %s without [WTS] in title or body", post.ID)
+			//check if post has one of the source's tags to filter it out
+			tradeType, ok := src.Classify(post.Title, post.Body)
+			if !ok {
+				log.Printf("Skipping post %s without a %s tag in title or body", post.ID, src.Name)
 				continue
 			}
 
@@ -157,6 +148,9 @@ func main() {
 				Title:          post.Title,
 				Body:           post.Body,
 				SellerUsername: post.Author,
+				Subreddit:      post.SubredditName,
+				Source:         src.Name,
+				TradeType:      tradeType,
 			}
 
 			err := rmq.Publish2JSON(exchange, key, job_post, ctx)
@@ -172,10 +166,9 @@ func main() {
 		}
 		// afterToken used as the achor point.
 		afterToken = posts[len(posts)-1].ID
-		log.Printf("Published %d jobs so far. Sleeping for 2s...", totalPublished)
+		log.Printf("Published %d %s jobs so far. Sleeping for 2s...", totalPublished, src.Name)
 		time.Sleep(2 * time.Second) // Being nice to Reddit's API
 	}
 
-	log.Printf("Backfill complete. Published %d total jobs", totalPublished)
-
+	return totalPublished
 }
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"frag-aggra/internal/catalog"
+	"frag-aggra/internal/database"
+	"frag-aggra/internal/models"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// manage the fragrance catalog and the review queue of names it couldn't resolve
+//
+//	catalog list
+//	catalog add -brand "Creed" -line "Aventus" -concentration EDP -aliases "Avi"
+//	catalog resolve "PdM Laytn"
+//	catalog review [-limit 25]
+//	catalog accept -review 12 -fragrance 4
+//	catalog ignore -review 12
+func main() {
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+
+	ctx := context.Background()
+	repo, err := database.New(ctx, os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	switch cmd {
+	case "list":
+		fragrances, err := repo.LoadCatalog(ctx)
+		if err != nil {
+			log.Fatalf("failed to load catalog: %v", err)
+		}
+		for _, f := range fragrances {
+			fmt.Printf("%4d  %s  %v\n", f.ID, f.FullName(), f.Aliases)
+		}
+	case "add":
+		add(ctx, repo, args)
+	case "resolve":
+		if len(args) == 0 {
+			usage()
+		}
+		fragrances, err := repo.LoadCatalog(ctx)
+		if err != nil {
+			log.Fatalf("failed to load catalog: %v", err)
+		}
+		name := strings.Join(args, " ")
+		match, ok := catalog.NewResolver(fragrances).Resolve(name)
+		fmt.Printf("%q -> %d %s (score %.3f, confident: %v)\n", name, match.Fragrance.ID, match.Fragrance.FullName(), match.Score, ok)
+	case "review":
+		fs := flag.NewFlagSet("review", flag.ExitOnError)
+		limit := fs.Int("limit", 25, "max reviews to show")
+		fs.Parse(args)
+		reviews, err := repo.PendingFragranceReviews(ctx, *limit)
+		if err != nil {
+			log.Fatalf("failed to list reviews: %v", err)
+		}
+		printJSON(reviews)
+	case "accept":
+		fs := flag.NewFlagSet("accept", flag.ExitOnError)
+		reviewID := fs.Int64("review", 0, "review id")
+		fragranceID := fs.Int64("fragrance", 0, "catalog fragrance id the name belongs to")
+		fs.Parse(args)
+		linked, err := repo.ResolveFragranceReview(ctx, *reviewID, *fragranceID)
+		if err != nil {
+			log.Fatalf("failed to resolve review %d: %v", *reviewID, err)
+		}
+		log.Printf("Resolved review %d, linked %d listings", *reviewID, linked)
+	case "ignore":
+		fs := flag.NewFlagSet("ignore", flag.ExitOnError)
+		reviewID := fs.Int64("review", 0, "review id")
+		fs.Parse(args)
+		if err := repo.IgnoreFragranceReview(ctx, *reviewID); err != nil {
+			log.Fatalf("failed to ignore review %d: %v", *reviewID, err)
+		}
+		log.Printf("Ignored review %d", *reviewID)
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: catalog list|add|resolve|review|accept|ignore [flags]")
+	os.Exit(2)
+}
+
+func add(ctx context.Context, repo *database.Repository, args []string) {
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	brand := fs.String("brand", "", "canonical brand name")
+	brandAliases := fs.String("brand-aliases", "", "comma separated brand aliases")
+	line := fs.String("line", "", "fragrance line without the brand")
+	concentration := fs.String("concentration", "", "EDP, EDT, Parfum, Extrait, ...")
+	aliases := fs.String("aliases", "", "comma separated line aliases")
+	fs.Parse(args)
+
+	if *brand == "" || *line == "" {
+		log.Fatal("-brand and -line are required")
+	}
+	id, err := repo.AddFragrance(ctx, models.Fragrance{
+		Brand:         *brand,
+		BrandAliases:  splitList(*brandAliases),
+		Line:          *line,
+		Concentration: *concentration,
+		Aliases:       splitList(*aliases),
+	})
+	if err != nil {
+		log.Fatalf("failed to add fragrance: %v", err)
+	}
+	log.Printf("Added fragrance %d", id)
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatalf("failed to print: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"frag-aggra/internal/models"
+	"frag-aggra/internal/pubsub"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// inspect and replay posts that ended up in the dead-letter queue
+//
+//	deadletter list [-limit 50]
+//	deadletter replay [-limit 50] [-id <post_id>]
+func main() {
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd := os.Args[1]
+
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	limit := fs.Int("limit", 50, "max messages to look at")
+	postID := fs.String("id", "", "only replay the post with this reddit id")
+	fs.Parse(os.Args[2:])
+
+	rmqUrl := os.Getenv("RABBITMQ_URL")
+	if rmqUrl == "" {
+		log.Fatal("RABBITMQ_URL not set")
+	}
+	rmq, err := pubsub.New(rmqUrl)
+	if err != nil {
+		log.Fatalf("Failed to innit RabbitMQ Client: %v", err)
+	}
+	defer rmq.Close()
+
+	// delays don't matter here, only the exchange and queue names
+	policy := pubsub.PostRetryPolicy(0, time.Second)
+	if _, err := rmq.Channel.QueueDeclare(policy.DeadQueue, true, false, false, false, nil); err != nil {
+		log.Fatalf("Failed to declare dead-letter queue: %v", err)
+	}
+
+	ctx := context.Background()
+	switch cmd {
+	case "list":
+		list(rmq, policy, *limit)
+	case "replay":
+		replay(ctx, rmq, policy, *limit, *postID)
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: deadletter list|replay [-limit N] [-id post_id]")
+	os.Exit(2)
+}
+
+// pulls up to limit messages without acking them, prints them, then puts them all back
+func list(rmq *pubsub.RabbitMQClient, policy pubsub.RetryPolicy, limit int) {
+	msgs := fetch(rmq, policy.DeadQueue, limit)
+	for _, msg := range msgs {
+		post := decodePost(msg)
+		fmt.Printf("%-10s retries=%d failed_at=%v\n  url:   %s\n  error: %v\n",
+			post.PostID, pubsub.RetryCount(msg), msg.Headers[pubsub.HeaderFailedAt],
+			post.URL, msg.Headers[pubsub.HeaderLastError])
+	}
+	requeue(msgs)
+	fmt.Printf("%d dead-lettered messages shown\n", len(msgs))
+}
+
+func replay(ctx context.Context, rmq *pubsub.RabbitMQClient, policy pubsub.RetryPolicy, limit int, postID string) {
+	msgs := fetch(rmq, policy.DeadQueue, limit)
+	var skipped []amqp.Delivery
+	replayed := 0
+	for _, msg := range msgs {
+		if postID != "" && decodePost(msg).PostID != postID {
+			skipped = append(skipped, msg)
+			continue
+		}
+		if err := rmq.Replay(ctx, policy, msg); err != nil {
+			log.Printf("failed to replay message: %v", err)
+			skipped = append(skipped, msg)
+			continue
+		}
+		replayed++
+	}
+	requeue(skipped)
+	log.Printf("Replayed %d dead-lettered messages", replayed)
+}
+
+func fetch(rmq *pubsub.RabbitMQClient, queue string, limit int) []amqp.Delivery {
+	var msgs []amqp.Delivery
+	for len(msgs) < limit {
+		msg, ok, err := rmq.Channel.Get(queue, false)
+		if err != nil {
+			log.Fatalf("Failed to get from %s: %v", queue, err)
+		}
+		if !ok {
+			break
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func requeue(msgs []amqp.Delivery) {
+	for _, msg := range msgs {
+		if err := msg.Nack(false, true); err != nil {
+			log.Printf("failed to requeue message: %v", err)
+		}
+	}
+}
+
+// best effort, garbage bodies just come back empty
+func decodePost(msg amqp.Delivery) models.Post {
+	var post models.Post
+	_ = json.Unmarshal(msg.Body, &post)
+	return post
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"frag-aggra/internal/evaluate"
+	"frag-aggra/internal/parser"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// runs prompt/model configurations over the labeled fixtures and compares
+// their field level precision/recall
+//
+//	evaluate -a openai:gpt-4o-2024-08-06:v2 -b openai:gpt-4o-2024-08-06:v3 [-fixtures testdata/eval] [-v] [-json]
+//
+// a configuration is provider:model:prompt, empty parts come from the
+// environment like the worker's. a model with a tag keeps its colon, the
+// prompt is whatever follows the last one. prompt is a built in version or a path to a
+// prompt file. "rules" evaluates the rule extractor alone as a baseline.
+func main() {
+	_ = godotenv.Load()
+
+	fixturesDir := flag.String("fixtures", "testdata/eval", "directory of labeled fixtures")
+	a := flag.String("a", "", "first configuration, provider:model:prompt")
+	b := flag.String("b", "", "second configuration, provider:model:prompt (optional)")
+	verbose := flag.Bool("v", false, "show every fixture that wasn't parsed perfectly")
+	asJSON := flag.Bool("json", false, "print the full reports as json")
+	flag.Parse()
+
+	fixtures, err := evaluate.LoadFixtures(*fixturesDir)
+	if err != nil {
+		log.Fatalf("failed to load fixtures: %v", err)
+	}
+
+	specs := []string{*a}
+	if *b != "" {
+		specs = append(specs, *b)
+	}
+
+	ctx := context.Background()
+	pricing := parser.PricingFromEnv()
+	var reports []evaluate.Report
+	for _, spec := range specs {
+		if spec == "rules" {
+			reports = append(reports, evaluate.Run(ctx, "rules", parser.NewRuleExtractor(), pricing, fixtures))
+			continue
+		}
+		cfg, err := configFromSpec(spec)
+		if err != nil {
+			log.Fatalf("invalid configuration %q: %v", spec, err)
+		}
+		e, err := parser.NewFromConfig(cfg)
+		if err != nil {
+			log.Fatalf("failed to create parser for %q: %v", spec, err)
+		}
+		label := fmt.Sprintf("%s:%s:%s", cfg.Provider, parser.ModelOf(e), parser.PromptOf(e).ID())
+		log.Printf("Evaluating %s over %d fixtures...", label, len(fixtures))
+		reports = append(reports, evaluate.Run(ctx, label, e, pricing, fixtures))
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(reports); err != nil {
+			log.Fatalf("failed to print: %v", err)
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "config\tnames p/r\tsizes p/r\tprices p/r\terrors\ttokens in/out\tcost\tlatency")
+	for _, r := range reports {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d/%d\t$%.4f\t%s\n",
+			r.Label, r.Score.Names, r.Score.Sizes, r.Score.Prices, r.Errors,
+			r.PromptTokens, r.CompletionTokens, r.CostUSD, r.Latency.Round(time.Millisecond))
+	}
+	w.Flush()
+
+	if *verbose {
+		for _, r := range reports {
+			fmt.Printf("\n%s\n", r.Label)
+			for _, res := range r.Results {
+				if res.Err == "" && res.Score.Perfect() {
+					continue
+				}
+				fmt.Printf("  %s: names %s sizes %s prices %s %s\n",
+					res.Fixture, res.Score.Names, res.Score.Sizes, res.Score.Prices, res.Err)
+			}
+		}
+	}
+}
+
+// provider:model:prompt on top of the environment's config. the model is
+// everything between the first and last colon, so a tagged model keeps its
+// colon ("openai-compatible:llama3:8b:v3", or "openai-compatible:llama3:8b:"
+// for the default prompt)
+func configFromSpec(spec string) (parser.Config, error) {
+	parts := []string{spec}
+	if first, last := strings.Index(spec, ":"), strings.LastIndex(spec, ":"); first == last && first >= 0 {
+		parts = []string{spec[:first], spec[first+1:]}
+	} else if first >= 0 {
+		parts = []string{spec[:first], spec[first+1 : last], spec[last+1:]}
+	}
+	cfg := parser.ConfigFromEnv()
+	if parts[0] != "" {
+		cfg = parser.ConfigFromEnvFor(parts[0])
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		cfg.Model = parts[1]
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		prompt := parts[2]
+		if strings.ContainsAny(prompt, `/\`) || strings.HasSuffix(prompt, ".txt") {
+			cfg.PromptFile = prompt
+		} else {
+			cfg.PromptFile, cfg.PromptVersion = "", prompt
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"frag-aggra/internal/database"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// market value analytics from the command line
+//
+//	market stats -fragrance 12 [-window-days 30]
+//	market history -fragrance 12 [-bucket week] [-window-days 30] [-since-days 180]
+//	market deals [-window-days 30] [-recent-days 3] [-max-ratio 0.8] [-min-samples 5]
+//	market search -q "creed aventsu" [-brand Creed] [-status available] [-min-similarity 0.5]
+func main() {
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd := os.Args[1]
+
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	fragranceID := fs.Int64("fragrance", 0, "catalog fragrance id")
+	windowDays := fs.Int("window-days", 30, "rolling window the percentiles look back over")
+	bucket := fs.String("bucket", "week", "history bucket: day, week or month")
+	sinceDays := fs.Int("since-days", 180, "how far back the history goes")
+	recentDays := fs.Int("recent-days", 3, "only listings this new can be deals")
+	maxRatio := fs.Float64("max-ratio", 0.8, "deal if price per ml is at most this fraction of the median")
+	minSamples := fs.Int("min-samples", 5, "ignore medians from fewer listings than this")
+	limit := fs.Int("limit", 25, "max deals or search results to show")
+	query := fs.String("q", "", "name to search for, typos are fine")
+	brand := fs.String("brand", "", "only listings of this catalog brand")
+	status := fs.String("status", "", "only listings with this status")
+	minSimilarity := fs.Float64("min-similarity", database.DefaultMinSimilarity, "how close a misspelled name has to be, 0 to 1")
+	fs.Parse(os.Args[2:])
+
+	ctx := context.Background()
+	repo, err := database.New(ctx, os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	window := days(*windowDays)
+	switch cmd {
+	case "stats":
+		requireFragrance(*fragranceID)
+		stats, err := repo.MarketStats(ctx, *fragranceID, window)
+		if err != nil {
+			log.Fatalf("failed to get market stats: %v", err)
+		}
+		printJSON(stats)
+	case "history":
+		requireFragrance(*fragranceID)
+		history, err := repo.PriceHistory(ctx, *fragranceID, *bucket, window, time.Now().Add(-days(*sinceDays)))
+		if err != nil {
+			log.Fatalf("failed to get price history: %v", err)
+		}
+		printJSON(history)
+	case "deals":
+		deals, err := repo.Deals(ctx, database.DealFilter{
+			Window:     window,
+			Recent:     days(*recentDays),
+			MaxRatio:   *maxRatio,
+			MinSamples: *minSamples,
+			Limit:      *limit,
+		})
+		if err != nil {
+			log.Fatalf("failed to get deals: %v", err)
+		}
+		printJSON(deals)
+	case "search":
+		page, err := repo.SearchListings(ctx, database.SearchFilter{
+			Query:         *query,
+			Brand:         *brand,
+			Status:        *status,
+			MinSimilarity: *minSimilarity,
+			Limit:         *limit,
+		})
+		if err != nil {
+			log.Fatalf("failed to search listings: %v", err)
+		}
+		printJSON(page)
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: market stats|history|deals|search [flags]")
+	os.Exit(2)
+}
+
+func requireFragrance(id int64) {
+	if id <= 0 {
+		log.Fatal("-fragrance is required")
+	}
+}
+
+func days(n int) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatalf("failed to print: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"frag-aggra/internal/catalog"
+	"frag-aggra/internal/database"
+	"frag-aggra/internal/images"
+	"frag-aggra/internal/parser"
+	"frag-aggra/internal/pubsub"
+	"frag-aggra/internal/recheck"
+	"frag-aggra/internal/routing"
+	"frag-aggra/internal/scraper"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	// periodically re-fetch posts from the last N days and update listing
+	// status for the ones sellers edited (struck through, marked sold, ...)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	days, err := strconv.Atoi(os.Getenv("RECHECK_DAYS"))
+	if err != nil || days <= 0 {
+		days = 7
+	}
+	interval, err := time.ParseDuration(os.Getenv("RECHECK_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = time.Hour
+	}
+
+	repo, err := database.New(ctx, os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+	if err := repo.Ping(ctx); err != nil {
+		log.Fatalf("failed to ping database: %v", err)
+	}
+
+	reddit, err := scraper.New()
+	if err != nil {
+		log.Fatalf("Failed to init reddit scraper: %v", err)
+	}
+
+	llm, err := parser.New()
+	if err != nil {
+		log.Fatalf("failed to create parser: %v", err)
+	}
+	minConfidence, err := strconv.ParseFloat(os.Getenv("RULES_MIN_CONFIDENCE"), 64)
+	if err != nil {
+		minConfidence = parser.DefaultRuleConfidence
+	}
+
+	pipeline := parser.NewPipeline(llm, minConfidence)
+	// read the pictures again too, or items only shown in them look removed
+	if os.Getenv("LLM_VISION") == "on" {
+		pipeline.Images = images.FetcherFromEnv()
+		if maxImages, err := strconv.Atoi(os.Getenv("LLM_MAX_IMAGES")); err == nil && maxImages > 0 {
+			pipeline.MaxImages = maxImages
+		}
+	}
+	checker := recheck.NewChecker(repo, reddit, pipeline)
+	checker.Window = time.Duration(days) * 24 * time.Hour
+	checker.MinAge = interval
+	checker.Linker, err = catalog.NewLinker(ctx, repo)
+	if err != nil {
+		log.Fatalf("failed to load fragrance catalog: %v", err)
+	}
+
+	// new items found in edits go to the alert matcher like fresh posts do
+	if rmqUrl := os.Getenv("RABBITMQ_URL"); rmqUrl != "" {
+		rmq, err := pubsub.New(rmqUrl)
+		if err != nil {
+			log.Fatalf("Failed to innit RabbitMQ Client: %v", err)
+		}
+		defer rmq.Close()
+		if _, err := rmq.DeclareAndBind(routing.ExchangePostDirect, routing.ListingAlertQueue, routing.ListingKey); err != nil {
+			log.Fatalf("Failed to declare listing alert queue: %v", err)
+		}
+		checker.Events = rmq
+	} else {
+		log.Println("RABBITMQ_URL not set, items added in edits won't be alerted on")
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Recheck service started. Checking posts from the last %d days every %s", days, interval)
+	for {
+		reparsed, err := checker.RunOnce(ctx)
+		if err != nil {
+			log.Printf("recheck run failed, retrying next tick: %v", err)
+		} else {
+			log.Printf("Recheck run done, %d edited posts re-parsed", reparsed)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Shutting down recheck...")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"frag-aggra/internal/database"
+	"frag-aggra/internal/evaluate"
+	"frag-aggra/internal/images"
+	"frag-aggra/internal/models"
+	"frag-aggra/internal/parser"
+	"frag-aggra/internal/replay"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/joho/godotenv"
+)
+
+// offline regression run of the parser, normalization and optionally
+// InsertItem over the labeled fixtures, with the llm served from recordings
+//
+//	replay [-fixtures testdata/eval] [-recordings testdata/eval/recordings] [-golden testdata/eval/golden] [-images testdata/eval/images] [-record | -seed] [-update] [-rules] [-db postgres://...]
+//
+// by default every fixture goes to the llm so the recordings cover them all,
+// -rules puts the rule extractor in front like the worker does. each parse is
+// checked against its golden file (golden/llm or golden/rules), what the
+// recordings gave when they were last accepted, and scored against the
+// fixture's labels for information. -update rewrites the golden files.
+// -record calls the real api and saves its responses, -seed writes responses
+// built from each fixture's expected listing without calling anything, for
+// fixtures nobody has paid to record yet. -db round trips every parse through
+// the given database (never DATABASE_URL, the posts are deleted afterwards).
+// pictures a fixture's post lists in images are read from -images, named
+// like images.FileName.
+func main() {
+	_ = godotenv.Load()
+
+	fixturesDir := flag.String("fixtures", "testdata/eval", "directory of labeled fixtures")
+	recordings := flag.String("recordings", "testdata/eval/recordings", "directory of recorded llm responses")
+	goldenDir := flag.String("golden", "testdata/eval/golden", "directory of accepted parses")
+	update := flag.Bool("update", false, "rewrite the golden files with this run's parses")
+	imagesDir := flag.String("images", "testdata/eval/images", "directory of the pictures fixtures link to")
+	record := flag.Bool("record", false, "call the llm and save its responses")
+	seed := flag.Bool("seed", false, "save responses made from the fixtures' expected listings")
+	provider := flag.String("provider", parser.ProviderOpenAI, "llm provider the recordings are for")
+	model := flag.String("model", "", "llm model, empty for the provider's default")
+	prompt := flag.String("prompt", parser.DefaultPromptVersion, "built in prompt version")
+	rules := flag.Bool("rules", false, "parse through the worker's pipeline, rules first")
+	dbURL := flag.String("db", "", "database to round trip the parses through, e.g. a local test postgres")
+	flag.Parse()
+
+	if *record && *seed {
+		log.Fatal("-record and -seed are exclusive")
+	}
+	if *seed && *provider == parser.ProviderAnthropic {
+		log.Fatal("-seed only writes openai shaped responses")
+	}
+
+	fixtures, err := evaluate.LoadFixtures(*fixturesDir)
+	if err != nil {
+		log.Fatalf("failed to load fixtures: %v", err)
+	}
+
+	transport := &replay.Transport{Dir: *recordings}
+	cfg := parser.ConfigFromEnvFor(*provider)
+	cfg.Model, cfg.BaseURL, cfg.PromptVersion, cfg.PromptFile = *model, os.Getenv("LLM_BASE_URL"), *prompt, ""
+	cfg.HTTPClient = transport.Client()
+	switch {
+	case *record:
+		transport.Mode = replay.Record
+	case *seed:
+		transport.Mode = replay.Record
+		cfg.APIKey = "seed"
+	default:
+		// nothing leaves the machine, the key only has to be present
+		cfg.APIKey = "replay"
+	}
+	llm, err := parser.NewFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("failed to create parser: %v", err)
+	}
+	fetcher := images.DirFetcher{Dir: *imagesDir}
+	var extractor parser.ListingExtractor = llm
+	golden := filepath.Join(*goldenDir, "llm")
+	if *rules {
+		golden = filepath.Join(*goldenDir, "rules")
+		pipeline := parser.NewPipeline(llm, parser.DefaultRuleConfidence)
+		pipeline.Images = fetcher
+		extractor = pipeline
+	}
+
+	ctx := context.Background()
+	var repo *database.Repository
+	if *dbURL != "" {
+		repo, err = database.New(ctx, *dbURL)
+		if err != nil {
+			log.Fatalf("failed to create repository: %v", err)
+		}
+		defer repo.Close()
+	}
+
+	failed := 0
+	for _, f := range fixtures {
+		if *seed {
+			content, err := json.Marshal(f.Expected)
+			if err != nil {
+				log.Fatalf("failed to encode %s: %v", f.Name, err)
+			}
+			transport.Next = replay.OpenAIStub(string(content))
+		}
+		problems := check(ctx, extractor, fetcher, repo, golden, *update, f)
+		if len(problems) == 0 {
+			fmt.Printf("ok    %s\n", f.Name)
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL  %s\n", f.Name)
+		for _, p := range problems {
+			fmt.Printf("      %s\n", p)
+		}
+	}
+	fmt.Printf("%d/%d fixtures passed\n", len(fixtures)-failed, len(fixtures))
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// the fixture's text, and its pictures when the llm is called directly and can read them
+func parse(ctx context.Context, extractor parser.ListingExtractor, fetcher images.Fetcher, f evaluate.Fixture) (*models.FragranceListing, error) {
+	vision, ok := extractor.(parser.ImageExtractor)
+	if !ok || len(f.Post.Images) == 0 {
+		return parser.ParsePost(ctx, extractor, f.Post)
+	}
+	var imgs []images.Image
+	for _, link := range f.Post.Images {
+		fetched, err := fetcher.Fetch(ctx, link)
+		if err != nil {
+			return nil, fmt.Errorf("image %s: %w", link, err)
+		}
+		imgs = append(imgs, fetched...)
+	}
+	return vision.ParsePostImages(ctx, f.Input(), imgs)
+}
+
+// everything wrong with one fixture's parse, normalization and storage
+func check(ctx context.Context, extractor parser.ListingExtractor, fetcher images.Fetcher, repo *database.Repository, golden string, update bool, f evaluate.Fixture) []string {
+	got, err := parse(ctx, extractor, fetcher, f)
+	var invalid *parser.InvalidListingError
+	if errors.As(err, &invalid) {
+		got = invalid.Listing
+	}
+	if errors.Is(err, replay.ErrNoRecording) {
+		return []string{fmt.Sprintf("parse: %v", err)}
+	}
+
+	var problems []string
+	result := evaluate.NewGolden(got, err)
+	if update {
+		if err := evaluate.SaveGolden(golden, f.Name, result); err != nil {
+			return []string{fmt.Sprintf("golden: %v", err)}
+		}
+	} else {
+		accepted, err := evaluate.LoadGolden(golden, f.Name)
+		if err != nil {
+			return []string{fmt.Sprintf("golden: %v", err)}
+		}
+		for _, d := range accepted.Diff(result) {
+			problems = append(problems, "parse: "+d)
+		}
+	}
+	if got == nil {
+		got = &models.FragranceListing{}
+	}
+	// the model's mistakes are in the golden file, this is how far off they are
+	if score := evaluate.Compare(&f.Expected, got); !score.Perfect() {
+		fmt.Printf("      labels: names %s sizes %s prices %s\n", score.Names, score.Sizes, score.Prices)
+	}
+	// the labels have to normalize to the rows the fixture pins down
+	if len(f.Stored) > 0 {
+		for _, d := range evaluate.CompareRows(f.Stored, evaluate.NormalizedRows(&f.Expected)) {
+			problems = append(problems, "normalize: "+d)
+		}
+	}
+	if repo == nil || err != nil {
+		return problems
+	}
+
+	// own id and url so a fixture never collides with a real post
+	post := f.Post
+	post.PostID = "replay_" + post.PostID
+	post.URL += "#replay"
+	_ = repo.DeletePost(ctx, post.PostID)
+	defer func() {
+		if err := repo.DeletePost(ctx, post.PostID); err != nil {
+			log.Printf("failed to clean up %s: %v", post.PostID, err)
+		}
+	}()
+	if err := repo.InsertItem(ctx, post, *got); err != nil {
+		return append(problems, fmt.Sprintf("insert: %v", err))
+	}
+	stored, err := repo.GetPost(ctx, post.PostID)
+	if err != nil {
+		return append(problems, fmt.Sprintf("get post: %v", err))
+	}
+	for _, d := range evaluate.CompareRows(evaluate.NormalizedRows(got), stored.Listings) {
+		problems = append(problems, "stored: "+d)
+	}
+	return problems
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"frag-aggra/internal/catalog"
+	"frag-aggra/internal/database"
+	"frag-aggra/internal/models"
+	"frag-aggra/internal/parser"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+// work through the posts whose llm output kept failing validation
+//
+//	review list [-limit 25]
+//	review show -id 3
+//	review accept -id 3 [-file fixed.json] [-force]
+//	review ignore -id 3
+//
+// accept stores the review's last attempt, or the listing in -file after
+// fixing it by hand (show prints it in the same shape). a listing that still
+// fails validation is refused unless -force is given.
+func main() {
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+
+	ctx := context.Background()
+	repo, err := database.New(ctx, os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	switch cmd {
+	case "list":
+		fs := flag.NewFlagSet("list", flag.ExitOnError)
+		limit := fs.Int("limit", 25, "max reviews to show")
+		fs.Parse(args)
+		reviews, err := repo.PendingParseReviews(ctx, *limit)
+		if err != nil {
+			log.Fatalf("failed to list reviews: %v", err)
+		}
+		for _, rv := range reviews {
+			fmt.Printf("%4d  %s  %d problems after %d attempts  %s\n", rv.ID, rv.RedditID, len(rv.Problems), rv.Attempts, rv.Post.URL)
+		}
+	case "show":
+		fs := flag.NewFlagSet("show", flag.ExitOnError)
+		id := fs.Int64("id", 0, "review id")
+		fs.Parse(args)
+		printJSON(get(ctx, repo, *id))
+	case "accept":
+		fs := flag.NewFlagSet("accept", flag.ExitOnError)
+		id := fs.Int64("id", 0, "review id")
+		file := fs.String("file", "", "corrected listing json, defaults to the last attempt")
+		force := fs.Bool("force", false, "store the listing even if it fails validation")
+		fs.Parse(args)
+		accept(ctx, repo, *id, *file, *force)
+	case "ignore":
+		fs := flag.NewFlagSet("ignore", flag.ExitOnError)
+		id := fs.Int64("id", 0, "review id")
+		fs.Parse(args)
+		if err := repo.IgnoreParseReview(ctx, *id); err != nil {
+			log.Fatalf("failed to ignore review %d: %v", *id, err)
+		}
+		log.Printf("Ignored review %d", *id)
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: review list|show|accept|ignore [flags]")
+	os.Exit(2)
+}
+
+func get(ctx context.Context, repo *database.Repository, id int64) *models.ParseReview {
+	review, err := repo.GetParseReview(ctx, id)
+	if err != nil {
+		log.Fatalf("failed to get review %d: %v", id, err)
+	}
+	if review == nil {
+		log.Fatalf("review %d not found", id)
+	}
+	return review
+}
+
+func accept(ctx context.Context, repo *database.Repository, id int64, file string, force bool) {
+	review := get(ctx, repo, id)
+	listing := review.Listing
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			log.Fatalf("failed to read %s: %v", file, err)
+		}
+		listing = models.FragranceListing{}
+		if err := json.Unmarshal(data, &listing); err != nil {
+			log.Fatalf("failed to decode %s: %v", file, err)
+		}
+	}
+	if problems := parser.Validate(&listing); len(problems) > 0 && !force {
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "  %s\n", p)
+		}
+		log.Fatalf("listing still has %d problems, fix them or pass -force", len(problems))
+	}
+
+	// same as the worker does after a successful parse
+	listing.ExtractedBy = models.ExtractedByLLM
+	if review.PromptVersion != nil {
+		listing.PromptVersion = *review.PromptVersion
+	}
+	if listing.Intent == "" {
+		listing.Intent = review.Post.TradeType
+	}
+	linker, err := catalog.NewLinker(ctx, repo)
+	if err != nil {
+		log.Fatalf("failed to load fragrance catalog: %v", err)
+	}
+	linker.Link(ctx, &listing)
+
+	if err := repo.ResolveParseReview(ctx, id, listing); err != nil {
+		log.Fatalf("failed to accept review %d: %v", id, err)
+	}
+	log.Printf("Accepted review %d, stored post %s", id, review.RedditID)
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatalf("failed to print: %v", err)
+	}
+}
@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
+	"frag-aggra/internal/models"
+	"frag-aggra/internal/pubsub"
+	"frag-aggra/internal/routing"
 	"frag-aggra/internal/scraper"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -14,11 +22,6 @@ func main() {
 
 	// all it does here is poll reddit, and publish each message to rabbitmq queue for worker to consume
 
-	// TODO: needs to connect to RabbitMQ, set up here
-
-	pollInterval := 5 * time.Minute
-	ticker := time.NewTicker(pollInterval)
-
 	_ = godotenv.Load()
 	limit := os.Getenv("REDDIT_FETCH_LIMIT")
 	limitInt, err := strconv.Atoi(limit)
@@ -31,22 +34,152 @@ func main() {
 		limitInt = 5
 	}
 
+	rmqUrl := os.Getenv("RABBITMQ_URL")
+	if rmqUrl == "" {
+		log.Fatal("RABBITMQ_URL not set")
+	}
+
+	sources := scraper.DefaultSources(limitInt)
+	if sourcesPath := os.Getenv("SOURCES_FILE"); sourcesPath != "" {
+		sources, err = scraper.LoadSources(sourcesPath, limitInt)
+		if err != nil {
+			log.Fatalf("Failed to load sources: %v", err)
+		}
+	}
+
+	// SCRAPER_CHECKPOINT_FILE is the single checkpoint from before sources,
+	// still read so an upgrade doesn't republish what was already seen
+	legacyPath := os.Getenv("SCRAPER_CHECKPOINT_FILE")
+	checkpointDir := os.Getenv("SCRAPER_CHECKPOINT_DIR")
+	if checkpointDir == "" {
+		checkpointDir = "data"
+		if legacyPath != "" {
+			checkpointDir = filepath.Dir(legacyPath)
+		}
+	}
+	if legacyPath == "" {
+		legacyPath = filepath.Join(checkpointDir, "scraper_checkpoint.json")
+	}
+
 	// init a scraper
-	scraper, err := scraper.New()
+	reddit, err := scraper.New()
 	if err != nil {
 		log.Fatalf("Failed to init reddit scraper: %v", err)
 	}
 
-	log.Println("Scraper service started. Polling every 5 minutes")
-	for {
-		<-ticker.C
+	//get the rabbitmq client
+	log.Print("Connecting to RabbitMQ...")
+	rmq, err := pubsub.New(rmqUrl)
+	if err != nil {
+		log.Fatalf("Failed to innit RabbitMQ Client: %v", err)
+	}
+	log.Print("RabbitMQ connection established")
+	defer rmq.Close()
+
+	exchange := routing.ExchangePostDirect
+	key := routing.PostKey
+	if _, err := rmq.DeclareAndBind(exchange, routing.PostQueue, key); err != nil {
+		log.Fatalf("Failed to declare post topology: %v", err)
+	}
 
-		log.Println("Polling latest reddit posts")
-		// Parse however much and input it into job_postings
-		job_postings, err := scraper.FetchPost("fragranceswap", limitInt)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// the channel isn't safe to publish on from several goroutines at once
+	var publishMu sync.Mutex
+	var wg sync.WaitGroup
+	for _, src := range sources {
+		// each source keeps its own mark so a slow subreddit can't hide posts from a fast one
+		checkpointPath := filepath.Join(checkpointDir, "scraper_checkpoint_"+src.Name+".json")
+		checkpoint, err := scraper.LoadCheckpoint(checkpointPath)
 		if err != nil {
-			log.Fatalf("Failed to fetch posts: %v", err)
+			log.Fatalf("Failed to load scraper checkpoint for %s: %v", src.Name, err)
+		}
+		if src.Subreddit == scraper.DefaultSubreddit && checkpoint.LastID == "" {
+			if err := seedFromLegacy(checkpoint, legacyPath); err != nil {
+				log.Fatalf("Failed to migrate legacy scraper checkpoint: %v", err)
+			}
 		}
+		log.Printf("Loaded %s checkpoint from %s (last id: %q)", src.Name, checkpointPath, checkpoint.LastID)
 
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pollSource(ctx, reddit, rmq, &publishMu, exchange, key, src, checkpoint)
+		}()
+	}
+
+	log.Printf("Scraper service started with %d sources", len(sources))
+	wg.Wait()
+	log.Println("Shutting down scraper...")
+}
+
+// starts a r/fragranceswap source's checkpoint from the old single checkpoint file,
+// if there is one, and saves it under the new name. the old file is left alone
+// so a rollback still finds it.
+func seedFromLegacy(checkpoint *scraper.Checkpoint, legacyPath string) error {
+	legacy, err := scraper.LoadCheckpoint(legacyPath)
+	if err != nil {
+		return err
+	}
+	if legacy.LastID == "" {
+		return nil
+	}
+	log.Printf("Found legacy scraper checkpoint %s (last id: %q), seeding from it", legacyPath, legacy.LastID)
+	checkpoint.Advance(legacy.LastID)
+	return checkpoint.Save()
+}
+
+// polls one source every src.Interval() until ctx is cancelled
+func pollSource(ctx context.Context, reddit *scraper.RedditScraper, rmq *pubsub.RabbitMQClient, publishMu *sync.Mutex,
+	exchange, key string, src scraper.Source, checkpoint *scraper.Checkpoint) {
+
+	ticker := time.NewTicker(src.Interval())
+	defer ticker.Stop()
+
+	log.Printf("Polling r/%s (%s) every %s", src.Subreddit, src.Name, src.Interval())
+	for {
+		job_postings, err := reddit.FetchPost(ctx, src)
+		if err != nil {
+			// reddit flakes out sometimes, just try again next tick
+			log.Printf("Failed to fetch %s posts, retrying next poll: %v", src.Name, err)
+		} else {
+			publishMu.Lock()
+			published := publishNew(ctx, rmq, exchange, key, job_postings, checkpoint)
+			publishMu.Unlock()
+			log.Printf("Published %d new %s posts (last id: %q)", published, src.Name, checkpoint.LastID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishes every post above the checkpoint oldest first, so the mark only
+// moves past a post once it is actually on the queue
+func publishNew(ctx context.Context, rmq *pubsub.RabbitMQClient, exchange, key string, posts []models.Post, checkpoint *scraper.Checkpoint) int {
+	published := 0
+	// reddit returns newest first
+	for i := len(posts) - 1; i >= 0; i-- {
+		post := posts[i]
+		if checkpoint.Seen(post.PostID) {
+			continue
+		}
+		if err := rmq.Publish2JSON(exchange, key, post, ctx); err != nil {
+			log.Printf("Error publishing post %s, will retry next poll: %v", post.PostID, err)
+			break
+		}
+		checkpoint.Advance(post.PostID)
+		published++
+	}
+
+	if published > 0 {
+		if err := checkpoint.Save(); err != nil {
+			log.Printf("Failed to save scraper checkpoint: %v", err)
+		}
 	}
+	return published
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"frag-aggra/internal/database"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// seller history from the command line
+//
+//	sellers show -user name[,name...]
+func main() {
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd := os.Args[1]
+
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	users := fs.String("user", "", "comma separated reddit usernames")
+	fs.Parse(os.Args[2:])
+
+	ctx := context.Background()
+	repo, err := database.New(ctx, os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	switch cmd {
+	case "show":
+		var usernames []string
+		for _, u := range strings.Split(*users, ",") {
+			if u = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(u), "u/")); u != "" {
+				usernames = append(usernames, u)
+			}
+		}
+		if len(usernames) == 0 {
+			log.Fatal("-user is required")
+		}
+		sellers, err := repo.Sellers(ctx, usernames)
+		if err != nil {
+			log.Fatalf("failed to get sellers: %v", err)
+		}
+		printJSON(sellers)
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: sellers show -user name[,name...]")
+	os.Exit(2)
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatalf("failed to print: %v", err)
+	}
+}
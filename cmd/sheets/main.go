@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"frag-aggra/internal/catalog"
+	"frag-aggra/internal/database"
+	"frag-aggra/internal/sheets"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// read the price lists "See Spreadsheet" posts link to
+//
+//	sheets run [-interval 10m]
+//	sheets fetch -post 1abcde
+//	sheets parse -file list.csv
+//
+// run reads due links until stopped, fetch reads every link of one post
+// again, parse prints what a csv would turn into without touching the
+// database. SHEETS_BASE_URL points the fetcher at a directory served over
+// http instead of google, see sheets.FileName for the file names it asks for.
+func main() {
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch cmd {
+	case "run":
+		fs := flag.NewFlagSet("run", flag.ExitOnError)
+		interval := fs.Duration("interval", envDuration("SHEETS_INTERVAL", 10*time.Minute), "time between runs")
+		fs.Parse(args)
+		repo := connect(ctx)
+		defer repo.Close()
+		run(ctx, ingester(ctx, repo), *interval)
+	case "fetch":
+		fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+		post := fs.String("post", "", "reddit id of the post")
+		fs.Parse(args)
+		repo := connect(ctx)
+		defer repo.Close()
+		fetch(ctx, repo, ingester(ctx, repo), *post)
+	case "parse":
+		fs := flag.NewFlagSet("parse", flag.ExitOnError)
+		file := fs.String("file", "", "csv file to read")
+		fs.Parse(args)
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			log.Fatalf("failed to read %s: %v", *file, err)
+		}
+		perfumes, err := sheets.ParseCSV(data)
+		if err != nil {
+			log.Fatalf("failed to parse %s: %v", *file, err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(perfumes); err != nil {
+			log.Fatalf("failed to print: %v", err)
+		}
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: sheets run|fetch|parse [flags]")
+	os.Exit(2)
+}
+
+func connect(ctx context.Context) *database.Repository {
+	repo, err := database.New(ctx, os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("failed to create repository: %v", err)
+	}
+	if err := repo.Ping(ctx); err != nil {
+		log.Fatalf("failed to ping database: %v", err)
+	}
+	return repo
+}
+
+func ingester(ctx context.Context, repo *database.Repository) *sheets.Ingester {
+	in := sheets.NewIngester(repo, sheets.NewHTTPFetcher(os.Getenv("SHEETS_BASE_URL")))
+	if n, err := strconv.Atoi(os.Getenv("SHEETS_MAX_ATTEMPTS")); err == nil && n > 0 {
+		in.MaxAttempts = n
+	}
+	var err error
+	in.Linker, err = catalog.NewLinker(ctx, repo)
+	if err != nil {
+		log.Fatalf("failed to load fragrance catalog: %v", err)
+	}
+	return in
+}
+
+func run(ctx context.Context, in *sheets.Ingester, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Sheets service started. Reading linked price lists every %s", interval)
+	for {
+		fetched, err := in.RunOnce(ctx)
+		if err != nil {
+			log.Printf("sheets run failed, retrying next tick: %v", err)
+		} else {
+			log.Printf("Sheets run done, %d price lists read", fetched)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Shutting down sheets...")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func fetch(ctx context.Context, repo *database.Repository, in *sheets.Ingester, redditID string) {
+	links, err := repo.PostSheetLinks(ctx, redditID)
+	if err != nil {
+		log.Fatalf("failed to get sheet links of post %s: %v", redditID, err)
+	}
+	if len(links) == 0 {
+		log.Fatalf("post %s has no sheet links", redditID)
+	}
+	for _, link := range links {
+		if err := in.Ingest(ctx, link); err != nil {
+			log.Printf("%s: %v", link.URL, err)
+			continue
+		}
+		log.Printf("%s: read", link.URL)
+	}
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
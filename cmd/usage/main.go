@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"frag-aggra/internal/database"
+	"frag-aggra/internal/parser"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// llm token and cost totals per day from parse_runs
+//
+//	usage [-days 7]
+func main() {
+	_ = godotenv.Load()
+
+	days := flag.Int("days", 7, "how many days back to sum")
+	flag.Parse()
+
+	ctx := context.Background()
+	repo, err := database.New(ctx, os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	since := parser.StartOfDay(time.Now()).AddDate(0, 0, -(*days - 1))
+	spend, err := repo.ParseSpendByDay(ctx, since)
+	if err != nil {
+		log.Fatalf("failed to get parse spend: %v", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(spend); err != nil {
+		log.Fatalf("failed to print: %v", err)
+	}
+}
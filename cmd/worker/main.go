@@ -3,17 +3,26 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"frag-aggra/internal/catalog"
 	"frag-aggra/internal/database"
+	"frag-aggra/internal/images"
 	"frag-aggra/internal/models"
 	"frag-aggra/internal/parser"
 	"frag-aggra/internal/pubsub"
 	"frag-aggra/internal/routing"
+	"frag-aggra/internal/sheets"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 func main() {
@@ -42,13 +51,72 @@ func main() {
 	log.Println("Database connection verified")
 	defer repo.Close()
 
-	log.Printf("Initializing the parser...")
-	p, err := parser.New()
+	llmCfg := parser.ConfigFromEnv()
+	log.Printf("Initializing the parser (provider: %s)...", llmCfg.Provider)
+	llm, err := parser.NewFromConfig(llmCfg)
 	if err != nil {
 		log.Fatalf("failed to create parser: %v", err)
 	}
+	promptID := parser.PromptOf(llm).ID()
+	log.Printf("Using prompt %s", promptID)
+	// rule based pre-parser runs first, llm only for posts it isn't sure about
+	minConfidence, err := strconv.ParseFloat(os.Getenv("RULES_MIN_CONFIDENCE"), 64)
+	if err != nil {
+		minConfidence = parser.DefaultRuleConfidence
+	}
+	pipeline := parser.NewPipeline(llm, minConfidence)
+	pipeline.Pricing = parser.PricingFromEnv()
+	// once the day's estimated spend reaches LLM_DAILY_BUDGET_USD posts wait in the queue until tomorrow
+	dailyBudget, err := strconv.ParseFloat(os.Getenv("LLM_DAILY_BUDGET_USD"), 64)
+	if err == nil && dailyBudget > 0 {
+		// an unpriced model costs nothing as far as the budget can tell, it would never run out
+		if model := parser.ModelOf(llm); !pipeline.Pricing.Known(llmCfg.Provider, model) {
+			log.Fatalf("LLM_DAILY_BUDGET_USD is set but model %q has no known price, set LLM_PRICE_INPUT and LLM_PRICE_OUTPUT", model)
+		}
+		// a response naming a snapshot we don't know still counts, at the highest list price
+		pipeline.Pricing.Fallback = &parser.ConservativePrice
+		pipeline.Budget = &parser.Budget{DailyUSD: dailyBudget, Spent: repo.ParseSpendSince}
+		log.Printf("LLM daily budget set to $%.2f", dailyBudget)
+	}
+	// identical post text parsed with the same prompt and model is served from postgres
+	if os.Getenv("PARSE_CACHE") != "off" {
+		pipeline.Cache = repo
+		// entries nobody made or hit in PARSE_CACHE_MAX_AGE are dropped on start
+		maxAge, err := time.ParseDuration(os.Getenv("PARSE_CACHE_MAX_AGE"))
+		if err != nil || maxAge <= 0 {
+			maxAge = 30 * 24 * time.Hour
+		}
+		pruned, err := repo.PruneParseCache(ctx, maxAge)
+		if err != nil {
+			log.Printf("failed to prune parse cache: %v", err)
+		} else if pruned > 0 {
+			log.Printf("Pruned %d parse cache entries unused for %s", pruned, maxAge)
+		}
+	}
+	// llm output that breaks the prompt's rules gets sent back this many times
+	// in total before the post goes to parse_reviews
+	if maxAttempts, err := strconv.Atoi(os.Getenv("PARSE_MAX_ATTEMPTS")); err == nil && maxAttempts > 0 {
+		pipeline.MaxAttempts = maxAttempts
+	}
+	// with a vision model the pictures in a post are sent along with its text
+	if os.Getenv("LLM_VISION") == "on" {
+		pipeline.Images = images.FetcherFromEnv()
+		if maxImages, err := strconv.Atoi(os.Getenv("LLM_MAX_IMAGES")); err == nil && maxImages > 0 {
+			pipeline.MaxImages = maxImages
+		}
+		if _, ok := llm.(parser.ImageExtractor); ok {
+			log.Printf("Sending up to %d pictures per post", pipeline.MaxImages)
+		}
+	}
+	var p parser.ListingExtractor = pipeline
 	log.Println("Parser created successfully")
 
+	// maps extracted names onto the fragrance catalog
+	linker, err := catalog.NewLinker(ctx, repo)
+	if err != nil {
+		log.Fatalf("failed to load fragrance catalog: %v", err)
+	}
+
 	// connect to the rabbitmq
 	log.Print("Connecting to RabbitMQ...")
 	rmq, err := pubsub.New(rmqUrl)
@@ -58,73 +126,303 @@ func main() {
 	log.Print("RabbitMQ connection established")
 	defer rmq.Close()
 
-	queue := routing.PostQueue
-	//declare the queue
-	q, err := rmq.Channel.QueueDeclare(
-		queue, //queue name
-		true,  //durable
-		false, //delete when unused
-		false, //exclusive
-		false, //nowait
-		nil,
-	)
+	//declare the exchange, queue and binding
+	q, err := rmq.DeclareAndBind(routing.ExchangePostDirect, routing.PostQueue, routing.PostKey)
 	if err != nil {
 		log.Fatalf("Failed to declare queue: %v", err)
 	}
 
-	log.Printf("Grabbing 1 post from queue in rabbitmq")
+	// declared here too so listing events queue up even before the matcher first runs
+	if _, err := rmq.DeclareAndBind(routing.ExchangePostDirect, routing.ListingAlertQueue, routing.ListingKey); err != nil {
+		log.Fatalf("Failed to declare listing alert queue: %v", err)
+	}
 
-	msgs, err := rmq.ConsumeFromClient(q.Name)
+	// failed posts back off exponentially through the retry queues and land
+	// in the dead-letter queue once they run out of retries
+	maxRetries, err := strconv.Atoi(os.Getenv("WORKER_MAX_RETRIES"))
+	if err != nil || maxRetries < 0 {
+		maxRetries = 5
+	}
+	baseDelay, err := time.ParseDuration(os.Getenv("WORKER_RETRY_BASE_DELAY"))
+	if err != nil || baseDelay <= 0 {
+		baseDelay = 30 * time.Second
+	}
+	retryPolicy := pubsub.PostRetryPolicy(maxRetries, baseDelay)
+	if err := rmq.DeclareRetryTopology(retryPolicy); err != nil {
+		log.Fatalf("Failed to declare retry topology: %v", err)
+	}
+	// on SIGINT/SIGTERM the worker stops taking posts and gives the ones it is
+	// already working on WORKER_SHUTDOWN_TIMEOUT to finish
+	shutdownTimeout, err := time.ParseDuration(os.Getenv("WORKER_SHUTDOWN_TIMEOUT"))
+	if err != nil || shutdownTimeout <= 0 {
+		shutdownTimeout = 30 * time.Second
+	}
+	sigCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// in-flight posts run on their own context so the signal doesn't cut an llm
+	// call or insert short, it is only cancelled once the shutdown timeout is up
+	workCtx, cancelWork := context.WithCancel(ctx)
+	defer cancelWork()
+	// a post whose work was cancelled goes back on the queue untouched, it didn't fail
+	abandoned := func(msg amqp.Delivery, postID string) bool {
+		if workCtx.Err() == nil {
+			return false
+		}
+		log.Printf("shutting down, requeueing post %s", postID)
+		msg.Nack(false, true)
+		return true
+	}
+
+	retry := func(msg amqp.Delivery, cause error) {
+		if _, err := rmq.Retry(workCtx, retryPolicy, msg, cause); err != nil {
+			log.Printf("failed to schedule retry, requeueing: %v", err)
+			msg.Nack(false, true)
+		}
+	}
+
+	// WORKER_CONCURRENCY posts are parsed at once, and the broker hands out
+	// WORKER_PREFETCH (by default as many) so the rest stay queued for other workers
+	concurrency, err := strconv.Atoi(os.Getenv("WORKER_CONCURRENCY"))
+	if err != nil || concurrency < 1 {
+		concurrency = 1
+	}
+	prefetch, err := strconv.Atoi(os.Getenv("WORKER_PREFETCH"))
+	if err != nil || prefetch < concurrency {
+		prefetch = concurrency
+	}
+	if err := rmq.SetPrefetch(prefetch); err != nil {
+		log.Fatalf("Failed to set prefetch count: %v", err)
+	}
+	log.Printf("Parsing %d posts at a time, prefetching %d from rabbitmq", concurrency, prefetch)
+
+	hostname, _ := os.Hostname()
+	consumerTag := fmt.Sprintf("worker-%s-%d", hostname, os.Getpid())
+	msgs, err := rmq.Consume(q.Name, consumerTag)
 	if err != nil {
 		log.Fatalf("Error consuming and getting channel")
 	}
 
-	// add signal handling
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	// handles one delivery, acking or nacking it before it returns
+	inFlight := newPostLocks()
+	handle := func(msg amqp.Delivery) {
+		// delivered before the consumer was cancelled, leave them for the next worker
+		if sigCtx.Err() != nil {
+			msg.Nack(false, true)
+			return
+		}
+		// msg := <-msgs
+		jsonStr := string(msg.Body)
+		var post models.Post
+		if err := json.Unmarshal([]byte(jsonStr), &post); err != nil {
+			log.Printf("bad json: %v", err)
+			// dont retry garbage, park it in the dlq for inspection
+			if err := rmq.DeadLetter(workCtx, retryPolicy, msg, fmt.Errorf("bad json: %w", err)); err != nil {
+				log.Printf("failed to dead-letter message: %v", err)
+				msg.Nack(false, false)
+			}
+			return
+		}
+		// for _, post := range job_postings {
+		// a second delivery of a post waits for the first one so the llm isn't paid twice
+		release := inFlight.claim(post.PostID)
+		defer release()
+		log.Printf("Post Title: %s\n", post.Title)
+		log.Printf("Post URL: %s\n", post.URL)
+		log.Printf("Post id: %s\n", post.PostID)
+		log.Printf("Parsing Reddit post content...")
 
-	go func() {
-		for msg := range msgs {
-			// msg := <-msgs
-			jsonStr := string(msg.Body)
-			var post models.Post
-			if err := json.Unmarshal([]byte(jsonStr), &post); err != nil {
-				log.Printf("bad json: %v", err)
-				msg.Nack(false, false) //drop, dont requeu garbage
-				continue
+		exists, err := repo.PostExists(workCtx, post.PostID)
+		if err != nil {
+			log.Printf("Error checking existence")
+		}
+		if !exists {
+			started := time.Now()
+			parsed_listing, err := parser.ParsePost(workCtx, p, post)
+			latency := time.Since(started)
+			if errors.Is(err, parser.ErrBudgetExceeded) {
+				// not the post's fault, put it back and stop pulling llm work until the budget resets
+				log.Printf("llm budget exceeded, leaving post %s queued", post.PostID)
+				msg.Nack(false, true)
+				waitForBudget(sigCtx, pipeline.Budget)
+				return
+			}
+			var invalid *parser.InvalidListingError
+			if errors.As(err, &invalid) {
+				// retrying won't fix it, a person has to
+				recordParseRun(workCtx, repo, post.PostID, invalid.Listing, latency, err)
+				log.Printf("post %s failed validation, sending it to review: %v", post.PostID, err)
+				problems := make([]string, len(invalid.Problems))
+				for i, problem := range invalid.Problems {
+					problems[i] = problem.String()
+				}
+				if err := repo.EnqueueParseReview(workCtx, post, invalid.Listing, problems, invalid.Attempts); err != nil {
+					log.Printf("failed to enqueue parse review for post %s: %v", post.PostID, err)
+					retry(msg, fmt.Errorf("enqueue review failed: %w", err))
+					return
+				}
+				msg.Ack(false)
+				return
+			}
+			if err != nil && abandoned(msg, post.PostID) {
+				return
 			}
-			// for _, post := range job_postings {
-			raw_input := post.Title + "\n" + post.Body
-			log.Printf("Post Title: %s\n", post.Title)
-			log.Printf("Post URL: %s\n", post.URL)
-			log.Printf("Post id: %s\n", post.PostID)
-			log.Printf("Parsing Reddit post content...")
-
-			exists, err := repo.PostExists(ctx, post.PostID)
 			if err != nil {
-				log.Printf("Error checking existence")
+				// a failed repair still used the tokens of the calls before it
+				var failed *models.FragranceListing
+				var repairErr *parser.RepairError
+				if errors.As(err, &repairErr) {
+					failed = &models.FragranceListing{ExtractedBy: models.ExtractedByLLM, Usage: repairErr.Usage}
+				}
+				recordParseRun(workCtx, repo, post.PostID, failed, latency, err)
+				log.Printf("failed to parse post content: %v", err)
+				retry(msg, fmt.Errorf("parse failed: %w", err))
+				return
+			}
+			if parsed_listing == nil {
+				log.Printf("parser returned nil for post %s", post.PostID)
+				retry(msg, fmt.Errorf("parser returned nil for post %s", post.PostID))
+				return
+			}
+			// fall back to what the post's tags said when the parser didn't decide
+			if parsed_listing.Intent == "" {
+				parsed_listing.Intent = post.TradeType
 			}
-			if !exists {
-				parsed_listing, err := p.ParsePostContent(context.Background(), raw_input)
-				if err != nil {
-					log.Printf("failed to parse post content: %v", err)
-					msg.Nack(false, true) // re-queue
-					continue
+			linker.Link(workCtx, parsed_listing)
+			err = repo.InsertItem(workCtx, post, *parsed_listing)
+			if errors.Is(err, database.ErrAlreadyStored) {
+				// another delivery got there first, the llm call still counts against the budget
+				recordParseRun(workCtx, repo, post.PostID, parsed_listing, latency, nil)
+				log.Printf("post %s was stored by another delivery, skipping", post.PostID)
+				msg.Ack(false)
+				return
+			}
+			if err != nil {
+				if abandoned(msg, post.PostID) {
+					return
 				}
-				if parsed_listing == nil {
-					log.Printf("parser returned nil for post %s", post.PostID)
-					msg.Nack(false, true)
-					continue
+				recordParseRun(workCtx, repo, post.PostID, parsed_listing, latency, nil)
+				log.Printf("failed to insert post %s: %v", post.PostID, err)
+				retry(msg, fmt.Errorf("insert failed: %w", err))
+				return
+			}
+			// after the insert so the run links to the stored post
+			recordParseRun(workCtx, repo, post.PostID, parsed_listing, latency, nil)
+			log.Printf("Finished parsing post %s", post.PostID)
+			// price lists the post links to are read later by cmd/sheets
+			if links := sheets.FindLinks(post.Body); len(links) > 0 {
+				if err := repo.AddSheetLinks(workCtx, post.PostID, links); err != nil {
+					log.Printf("failed to record sheet links for post %s: %v", post.PostID, err)
 				}
-				repo.InsertItem(ctx, post, *parsed_listing)
-				log.Printf("Finished parsing post %s", post.PostID)
-			} else {
-				log.Printf("Already seen, skipping")
 			}
-			msg.Ack(false)
+			// let the alert matcher know, it runs on its own so we dont block on delivery
+			event := models.ListingEvent{RedditID: post.PostID}
+			if err := rmq.Publish2JSON(routing.ExchangePostDirect, routing.ListingKey, event, workCtx); err != nil {
+				log.Printf("failed to publish listing event for post %s: %v", post.PostID, err)
+			}
+		} else {
+			log.Printf("Already seen, skipping")
 		}
+		msg.Ack(false)
+	}
+
+	// each goroutine of the pool takes posts off msgs until it's closed
+	consume := func(msgs <-chan amqp.Delivery) {
+		for msg := range msgs {
+			handle(msg)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			consume(msgs)
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
 	}()
-	<-sigChan
+	select {
+	case <-sigCtx.Done():
+	case <-done:
+		log.Println("RabbitMQ closed the delivery channel, shutting down")
+		return
+	}
+	// a second signal kills the worker straight away
+	stop()
 	log.Println("Shutting down gracefully...")
+	if err := rmq.StopConsuming(consumerTag); err != nil {
+		log.Printf("failed to cancel consumer: %v", err)
+	}
+	select {
+	case <-done:
+		log.Println("In-flight posts finished")
+	case <-time.After(shutdownTimeout):
+		log.Printf("posts still in flight after %s, cancelling and requeueing them", shutdownTimeout)
+		cancelWork()
+		<-done
+	}
+	// deferred: the rabbitmq channel and connection close, then the database pool
+}
+
+// postLocks lets one goroutine of the pool at a time work on a post
+type postLocks struct {
+	mu    sync.Mutex
+	posts map[string]chan struct{}
+}
+
+func newPostLocks() *postLocks {
+	return &postLocks{posts: map[string]chan struct{}{}}
+}
+
+// claim blocks while another goroutine holds the post, the returned func releases it
+func (l *postLocks) claim(redditID string) func() {
+	for {
+		l.mu.Lock()
+		held, busy := l.posts[redditID]
+		if !busy {
+			done := make(chan struct{})
+			l.posts[redditID] = done
+			l.mu.Unlock()
+			return func() {
+				l.mu.Lock()
+				delete(l.posts, redditID)
+				l.mu.Unlock()
+				close(done)
+			}
+		}
+		l.mu.Unlock()
+		<-held
+	}
+}
+
+// the run is bookkeeping, losing one shouldn't fail the post
+func recordParseRun(ctx context.Context, repo *database.Repository, redditID string, listing *models.FragranceListing, latency time.Duration, parseErr error) {
+	if err := repo.RecordParseRun(ctx, redditID, listing, latency, parseErr); err != nil {
+		log.Printf("failed to record parse run for post %s: %v", redditID, err)
+	}
+}
 
+// blocks until the llm budget has room again or ctx is done, checking every
+// minute so a raised budget or the midnight reset is picked up
+func waitForBudget(ctx context.Context, budget *parser.Budget) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Minute):
+		}
+		exceeded, err := budget.Exceeded(ctx)
+		if err != nil {
+			log.Printf("failed to check llm budget, trying again: %v", err)
+			continue
+		}
+		if !exceeded {
+			log.Println("llm budget available again, resuming")
+			return
+		}
+	}
 }
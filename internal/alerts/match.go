@@ -0,0 +1,53 @@
+package alerts
+
+import (
+	"frag-aggra/internal/models"
+	"strings"
+)
+
+// Matches reports whether listing l satisfies saved search s.
+// every word of the name pattern has to appear in the name, and a listing
+// missing a value a constraint needs (e.g. no parsed price) doesn't match.
+func Matches(s models.SavedSearch, l models.Listing) bool {
+	if l.Status != "" && l.Status != models.ListingAvailable {
+		return false
+	}
+
+	name := strings.ToLower(l.Name)
+	for _, word := range strings.Fields(strings.ToLower(s.NamePattern)) {
+		if !strings.Contains(name, word) {
+			return false
+		}
+	}
+
+	for _, seller := range s.SellerBlocklist {
+		if strings.EqualFold(seller, l.SellerUsername) {
+			return false
+		}
+	}
+
+	if s.MaxPriceCents != nil {
+		if l.PriceCents == nil || *l.PriceCents > *s.MaxPriceCents {
+			return false
+		}
+	}
+
+	if s.MinML != nil || s.MaxML != nil {
+		if l.RemainingML == nil {
+			return false
+		}
+		if s.MinML != nil && *l.RemainingML < *s.MinML {
+			return false
+		}
+		if s.MaxML != nil && *l.RemainingML > *s.MaxML {
+			return false
+		}
+	}
+
+	if s.BottleKind != nil {
+		if l.BottleKind == nil || !strings.EqualFold(*l.BottleKind, *s.BottleKind) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"frag-aggra/internal/database"
+	"frag-aggra/internal/models"
+	"log"
+)
+
+// Matcher checks newly stored listings against every active saved search
+type Matcher struct {
+	repo  *database.Repository
+	sinks Sinks
+}
+
+func NewMatcher(repo *database.Repository, sinks Sinks) *Matcher {
+	return &Matcher{repo: repo, sinks: sinks}
+}
+
+// HandleEvent matches every listing of the event's post. it is safe to call
+// more than once for the same post, ClaimNotification dedups the alerts.
+// failed sends are left for RetryDue.
+func (m *Matcher) HandleEvent(ctx context.Context, event models.ListingEvent) error {
+	post, err := m.repo.GetPost(ctx, event.RedditID)
+	if errors.Is(err, database.ErrNotFound) {
+		log.Printf("post %s from listing event not found, skipping", event.RedditID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	searches, err := m.repo.ListSavedSearches(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	for _, listing := range post.Listings {
+		for _, search := range searches {
+			if !Matches(search, listing) {
+				continue
+			}
+			if err := m.notify(ctx, search, listing); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RetryDue sends the notifications whose last attempt failed and whose
+// backoff has run out, it returns how many it tried
+func (m *Matcher) RetryDue(ctx context.Context) (int, error) {
+	due, err := m.repo.DueNotifications(ctx, database.MaxPageSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range due {
+		if err := m.notify(ctx, d.Search, d.Listing); err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// claims the search/listing pair and sends it, a failed send is recorded on
+// the notification rather than returned
+func (m *Matcher) notify(ctx context.Context, search models.SavedSearch, listing models.Listing) error {
+	id, claimed, err := m.repo.ClaimNotification(ctx, search.ID, listing.ID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	n := Notification{Search: search, Listing: listing}
+	sendErr := m.sinks.Send(ctx, search.NotifyKind, search.NotifyTarget, n)
+	if sendErr != nil {
+		log.Printf("failed to notify saved search %d about listing %d: %v", search.ID, listing.ID, sendErr)
+	} else {
+		log.Printf("notified: %s", n.Summary())
+	}
+	return m.repo.FinishNotification(ctx, id, sendErr)
+}
@@ -0,0 +1,58 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"frag-aggra/internal/models"
+	"net/http"
+	"time"
+)
+
+// Notification is one listing that matched one saved search
+type Notification struct {
+	Search  models.SavedSearch `json:"search"`
+	Listing models.Listing     `json:"listing"`
+}
+
+// Summary is a one line human readable description of the match
+func (n Notification) Summary() string {
+	return fmt.Sprintf("%s %s for %s by u/%s (saved search %q)",
+		n.Listing.Name, n.Listing.Size, n.Listing.Price, n.Listing.SellerUsername, n.Search.Name)
+}
+
+// Sink delivers a notification to target, which is whatever the saved
+// search stored for its kind (a url, an email address, ...)
+type Sink interface {
+	Send(ctx context.Context, target string, n Notification) error
+}
+
+// sink kinds a saved search can use
+const (
+	SinkWebhook = "webhook"
+	SinkDiscord = "discord"
+	SinkSMTP    = "smtp"
+)
+
+// Sinks maps a saved search's notify_kind to the sink that handles it
+type Sinks map[string]Sink
+
+// DefaultSinks returns the webhook and discord sinks, plus smtp if it's configured
+func DefaultSinks() Sinks {
+	client := &http.Client{Timeout: 10 * time.Second}
+	sinks := Sinks{
+		SinkWebhook: &WebhookSink{client: client},
+		SinkDiscord: &DiscordSink{client: client},
+	}
+	if smtp, err := SMTPSinkFromEnv(); err == nil {
+		sinks[SinkSMTP] = smtp
+	}
+	return sinks
+}
+
+func (s Sinks) Send(ctx context.Context, kind, target string, n Notification) error {
+	sink, ok := s[kind]
+	if !ok {
+		return fmt.Errorf("no sink configured for kind %q", kind)
+	}
+	return sink.Send(ctx, target, n)
+}
@@ -0,0 +1,60 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// SMTPSink emails the notification to the target address
+type SMTPSink struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// SMTPSinkFromEnv reads SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and SMTP_FROM
+func SMTPSinkFromEnv() (*SMTPSink, error) {
+	host := os.Getenv("SMTP_HOST")
+	from := os.Getenv("SMTP_FROM")
+	if host == "" || from == "" {
+		return nil, errors.New("SMTP_HOST and SMTP_FROM must be set")
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	return &SMTPSink{addr: host + ":" + port, auth: auth, from: from}, nil
+}
+
+func (s *SMTPSink) Send(ctx context.Context, target string, n Notification) error {
+	// net/smtp has no context support, bail early at least
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if strings.ContainsAny(target, "\r\n") {
+		return fmt.Errorf("invalid recipient %q", target)
+	}
+
+	l := n.Listing
+	subject := fmt.Sprintf("[frag-aggra] %s %s for %s", l.Name, l.Size, l.Price)
+	body := fmt.Sprintf("%s\r\n\r\n%s\r\n", n.Summary(), l.PostURL)
+	msg := "From: " + s.from + "\r\n" +
+		"To: " + target + "\r\n" +
+		"Subject: " + strings.NewReplacer("\r", " ", "\n", " ").Replace(subject) + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + body
+
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{target}, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// WebhookSink POSTs the notification as json to the target url
+type WebhookSink struct {
+	client *http.Client
+}
+
+func (w *WebhookSink) Send(ctx context.Context, target string, n Notification) error {
+	return postJSON(ctx, w.client, target, n)
+}
+
+// DiscordSink POSTs a discord-compatible webhook payload (content + embed),
+// which slack-style relays that accept discord webhooks also understand
+type DiscordSink struct {
+	client *http.Client
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbed struct {
+	Title  string              `json:"title"`
+	URL    string              `json:"url,omitempty"`
+	Fields []discordEmbedField `json:"fields"`
+}
+
+type discordPayload struct {
+	Content string         `json:"content"`
+	Embeds  []discordEmbed `json:"embeds"`
+}
+
+func (d *DiscordSink) Send(ctx context.Context, target string, n Notification) error {
+	l := n.Listing
+	payload := discordPayload{
+		Content: fmt.Sprintf("New match for saved search **%s**", n.Search.Name),
+		Embeds: []discordEmbed{{
+			Title: l.Name,
+			URL:   l.PostURL,
+			Fields: []discordEmbedField{
+				{Name: "Size", Value: l.Size, Inline: true},
+				{Name: "Price", Value: l.Price, Inline: true},
+				{Name: "Seller", Value: "u/" + l.SellerUsername, Inline: true},
+			},
+		}},
+	}
+	return postJSON(ctx, d.client, target, payload)
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
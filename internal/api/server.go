@@ -0,0 +1,436 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"frag-aggra/internal/database"
+	"frag-aggra/internal/models"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Server serves the read-only REST api over the repository
+type Server struct {
+	repo *database.Repository
+	mux  *http.ServeMux
+}
+
+func New(repo *database.Repository) *Server {
+	s := &Server{
+		repo: repo,
+		mux:  http.NewServeMux(),
+	}
+	s.routes()
+	return s
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	s.mux.HandleFunc("GET /listings", s.handleSearchListings)
+	s.mux.HandleFunc("GET /listings/recent", s.handleRecentListings)
+	s.mux.HandleFunc("GET /listings/search", s.handleFuzzySearch)
+	s.mux.HandleFunc("GET /posts/{redditID}", s.handleGetPost)
+	s.mux.HandleFunc("GET /posts/{redditID}/matches", s.handleWantMatches)
+	s.mux.HandleFunc("GET /fragrances/{id}/market", s.handleMarketStats)
+	s.mux.HandleFunc("GET /fragrances/{id}/history", s.handlePriceHistory)
+	s.mux.HandleFunc("GET /deals", s.handleDeals)
+	s.mux.HandleFunc("GET /sellers/{username}", s.handleGetSeller)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if err := s.repo.Ping(r.Context()); err != nil {
+		writeError(w, http.StatusServiceUnavailable, "database unavailable")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// GET /listings?name=&seller=&size=&min_price=&max_price=&status=&fragrance_id=&condition=&box=&batch_code=&min_fill=&cursor=&limit=
+// prices are in dollars, e.g. max_price=180
+func (s *Server) handleSearchListings(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	minPrice, err := parseDollars(q.Get("min_price"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid min_price")
+		return
+	}
+	maxPrice, err := parseDollars(q.Get("max_price"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid max_price")
+		return
+	}
+	limit, err := parseLimit(q.Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	var fragranceID int64
+	if v := q.Get("fragrance_id"); v != "" {
+		fragranceID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || fragranceID <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid fragrance_id")
+			return
+		}
+	}
+
+	switch q.Get("condition") {
+	case "", models.ConditionNew, models.ConditionTester, models.ConditionUsed:
+	default:
+		writeError(w, http.StatusBadRequest, "invalid condition")
+		return
+	}
+	switch q.Get("box") {
+	case "", models.BoxBoxed, models.BoxUnboxed, models.BoxDamaged:
+	default:
+		writeError(w, http.StatusBadRequest, "invalid box")
+		return
+	}
+	var minFill int
+	if v := q.Get("min_fill"); v != "" {
+		minFill, err = strconv.Atoi(v)
+		if err != nil || minFill < 0 || minFill > 100 {
+			writeError(w, http.StatusBadRequest, "invalid min_fill")
+			return
+		}
+	}
+
+	page, err := s.repo.FindListings(r.Context(), database.ListingFilter{
+		Name:          q.Get("name"),
+		Seller:        q.Get("seller"),
+		Size:          q.Get("size"),
+		MinPriceCents: minPrice,
+		MaxPriceCents: maxPrice,
+		Status:        q.Get("status"),
+		FragranceID:   fragranceID,
+		Condition:     q.Get("condition"),
+		Box:           q.Get("box"),
+		HasBatchCode:  q.Get("batch_code") == "true",
+		MinFill:       minFill,
+		Cursor:        q.Get("cursor"),
+		Limit:         limit,
+	})
+	if errors.Is(err, database.ErrInvalidCursor) {
+		writeError(w, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+	if err != nil {
+		log.Printf("search listings failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	s.attachSellers(r.Context(), listingPtrs(page.Listings)...)
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GET /listings/recent?limit=
+func (s *Server) handleRecentListings(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	listings, err := s.repo.RecentListings(r.Context(), limit)
+	if err != nil {
+		log.Printf("recent listings failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	s.attachSellers(r.Context(), listingPtrs(listings)...)
+	writeJSON(w, http.StatusOK, map[string]any{"listings": listings})
+}
+
+// GET /listings/search?q=&brand=&status=&min_similarity=&cursor=&limit=
+// ranked name search that tolerates typos, with brand counts to narrow it down
+func (s *Server) handleFuzzySearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	limit, err := parseLimit(q.Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	var minSimilarity float64
+	if v := q.Get("min_similarity"); v != "" {
+		minSimilarity, err = strconv.ParseFloat(v, 64)
+		if err != nil || minSimilarity <= 0 || minSimilarity > 1 {
+			writeError(w, http.StatusBadRequest, "invalid min_similarity")
+			return
+		}
+	}
+
+	page, err := s.repo.SearchListings(r.Context(), database.SearchFilter{
+		Query:         q.Get("q"),
+		Brand:         q.Get("brand"),
+		Status:        q.Get("status"),
+		MinSimilarity: minSimilarity,
+		Cursor:        q.Get("cursor"),
+		Limit:         limit,
+	})
+	if errors.Is(err, database.ErrEmptyQuery) {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	if errors.Is(err, database.ErrInvalidCursor) {
+		writeError(w, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+	if err != nil {
+		log.Printf("fuzzy search failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	listings := make([]*models.Listing, len(page.Results))
+	for i := range page.Results {
+		listings[i] = &page.Results[i].Listing
+	}
+	s.attachSellers(r.Context(), listings...)
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GET /posts/{redditID}
+func (s *Server) handleGetPost(w http.ResponseWriter, r *http.Request) {
+	post, err := s.repo.GetPost(r.Context(), r.PathValue("redditID"))
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "post not found")
+		return
+	}
+	if err != nil {
+		log.Printf("get post failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if post.SellerUsername != "" {
+		if seller, err := s.repo.GetSeller(r.Context(), post.SellerUsername); err == nil {
+			post.Seller = seller
+		} else if !errors.Is(err, database.ErrNotFound) {
+			log.Printf("get seller failed: %v", err)
+		}
+	}
+	writeJSON(w, http.StatusOK, post)
+}
+
+// GET /posts/{redditID}/matches?limit=
+// listings from other sellers that satisfy the post's wants
+func (s *Server) handleWantMatches(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	matches, err := s.repo.WantMatches(r.Context(), r.PathValue("redditID"), limit)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "post not found")
+		return
+	}
+	if err != nil {
+		log.Printf("want matches failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	listings := make([]*models.Listing, len(matches))
+	for i := range matches {
+		listings[i] = &matches[i].Listing
+	}
+	s.attachSellers(r.Context(), listings...)
+	writeJSON(w, http.StatusOK, map[string]any{"matches": matches})
+}
+
+// GET /fragrances/{id}/market?window_days=30
+func (s *Server) handleMarketStats(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid fragrance id")
+		return
+	}
+	window, err := parseDays(r.URL.Query().Get("window_days"), 30)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid window_days")
+		return
+	}
+	stats, err := s.repo.MarketStats(r.Context(), id, window)
+	if err != nil {
+		log.Printf("market stats failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"stats": stats})
+}
+
+// GET /fragrances/{id}/history?bucket=week&window_days=30&since_days=180
+func (s *Server) handlePriceHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid fragrance id")
+		return
+	}
+	bucket := q.Get("bucket")
+	if bucket == "" {
+		bucket = "week"
+	}
+	window, err := parseDays(q.Get("window_days"), 30)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid window_days")
+		return
+	}
+	since, err := parseDays(q.Get("since_days"), 180)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid since_days")
+		return
+	}
+	if bucket != "day" && bucket != "week" && bucket != "month" {
+		writeError(w, http.StatusBadRequest, "bucket must be day, week or month")
+		return
+	}
+
+	history, err := s.repo.PriceHistory(r.Context(), id, bucket, window, time.Now().Add(-since))
+	if err != nil {
+		log.Printf("price history failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"history": history})
+}
+
+// GET /deals?window_days=30&recent_days=3&max_ratio=0.8&min_samples=5&limit=
+func (s *Server) handleDeals(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	window, err := parseDays(q.Get("window_days"), 30)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid window_days")
+		return
+	}
+	recent, err := parseDays(q.Get("recent_days"), 3)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid recent_days")
+		return
+	}
+	maxRatio := 0.8
+	if v := q.Get("max_ratio"); v != "" {
+		maxRatio, err = strconv.ParseFloat(v, 64)
+		if err != nil || maxRatio <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid max_ratio")
+			return
+		}
+	}
+	minSamples := 5
+	if v := q.Get("min_samples"); v != "" {
+		minSamples, err = strconv.Atoi(v)
+		if err != nil || minSamples < 1 {
+			writeError(w, http.StatusBadRequest, "invalid min_samples")
+			return
+		}
+	}
+	limit, err := parseLimit(q.Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+
+	deals, err := s.repo.Deals(r.Context(), database.DealFilter{
+		Window:     window,
+		Recent:     recent,
+		MaxRatio:   maxRatio,
+		MinSamples: minSamples,
+		Limit:      limit,
+	})
+	if err != nil {
+		log.Printf("deals failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	listings := make([]*models.Listing, len(deals))
+	for i := range deals {
+		listings[i] = &deals[i].Listing
+	}
+	s.attachSellers(r.Context(), listings...)
+	writeJSON(w, http.StatusOK, map[string]any{"deals": deals})
+}
+
+// GET /sellers/{username}
+func (s *Server) handleGetSeller(w http.ResponseWriter, r *http.Request) {
+	seller, err := s.repo.GetSeller(r.Context(), r.PathValue("username"))
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "seller not found")
+		return
+	}
+	if err != nil {
+		log.Printf("get seller failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	writeJSON(w, http.StatusOK, seller)
+}
+
+// seller history is extra, the listings are still worth returning without it
+func (s *Server) attachSellers(ctx context.Context, listings ...*models.Listing) {
+	if err := s.repo.AttachSellers(ctx, listings...); err != nil {
+		log.Printf("attach sellers failed: %v", err)
+	}
+}
+
+func listingPtrs(listings []models.Listing) []*models.Listing {
+	out := make([]*models.Listing, len(listings))
+	for i := range listings {
+		out[i] = &listings[i]
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// "" -> 0, "180" or "179.99" -> cents
+func parseDollars(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, fmt.Errorf("invalid price %q", s)
+	}
+	return int64(math.Round(v * 100)), nil
+}
+
+func parseLimit(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid limit %q", s)
+	}
+	return n, nil
+}
+
+// whole days, "" -> def
+func parseDays(s string, def int) (time.Duration, error) {
+	days := def
+	if s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid days %q", s)
+		}
+		days = n
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
@@ -0,0 +1,83 @@
+package catalog
+
+import (
+	"context"
+	"frag-aggra/internal/database"
+	"frag-aggra/internal/models"
+	"log"
+	"sync"
+	"time"
+)
+
+// Linker resolves the perfumes of a parsed listing against the catalog stored
+// in postgres, queueing names it can't place for review. the catalog is
+// reloaded every TTL so resolved reviews and new entries get picked up.
+type Linker struct {
+	repo     *database.Repository
+	TTL      time.Duration
+	mu       sync.Mutex
+	resolver *Resolver
+	loadedAt time.Time
+}
+
+func NewLinker(ctx context.Context, repo *database.Repository) (*Linker, error) {
+	l := &Linker{repo: repo, TTL: 10 * time.Minute}
+	if err := l.reload(ctx); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Linker) reload(ctx context.Context) error {
+	fragrances, err := l.repo.LoadCatalog(ctx)
+	if err != nil {
+		return err
+	}
+	l.resolver = NewResolver(fragrances)
+	l.loadedAt = time.Now()
+	return nil
+}
+
+func (l *Linker) current(ctx context.Context) *Resolver {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Since(l.loadedAt) > l.TTL {
+		// a stale catalog still beats none, keep going on failure
+		if err := l.reload(ctx); err != nil {
+			log.Printf("failed to reload fragrance catalog: %v", err)
+		}
+	}
+	return l.resolver
+}
+
+// Link sets FragranceID and ResolveScore on every perfume and want it can resolve
+func (l *Linker) Link(ctx context.Context, listing *models.FragranceListing) {
+	resolver := l.current(ctx)
+	for i := range listing.Perfumes {
+		perfume := &listing.Perfumes[i]
+		perfume.FragranceID, perfume.ResolveScore = l.resolve(ctx, resolver, perfume.Name)
+	}
+	for i := range listing.Wants {
+		want := &listing.Wants[i]
+		want.FragranceID, want.ResolveScore = l.resolve(ctx, resolver, want.Name)
+	}
+}
+
+// resolve returns the catalog id and score for name, or queues it for review
+func (l *Linker) resolve(ctx context.Context, resolver *Resolver, name string) (*int64, float64) {
+	match, ok := resolver.Resolve(name)
+	if ok {
+		id := match.Fragrance.ID
+		return &id, match.Score
+	}
+
+	var suggested *int64
+	if match.Score > 0 {
+		id := match.Fragrance.ID
+		suggested = &id
+	}
+	if err := l.repo.EnqueueFragranceReview(ctx, name, suggested, match.Score); err != nil {
+		log.Printf("failed to queue '%s' for review: %v", name, err)
+	}
+	return nil, 0
+}
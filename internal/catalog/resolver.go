@@ -0,0 +1,139 @@
+package catalog
+
+import (
+	"frag-aggra/internal/models"
+	"strings"
+)
+
+// DefaultThreshold is the score a match needs before it's trusted without review
+const DefaultThreshold = 0.85
+
+// a brand-less candidate ("Layton") is a little less convincing than a full one
+const lineOnlyPenalty = 0.95
+
+// words that name a concentration, "eau" is just filler in "eau de parfum"
+var concentrationWords = map[string]string{
+	"edp":      "EDP",
+	"edt":      "EDT",
+	"edc":      "Cologne",
+	"cologne":  "Cologne",
+	"toilette": "EDT",
+	"parfum":   "Parfum",
+	"extrait":  "Extrait",
+	"elixir":   "Elixir",
+	"eau":      "",
+}
+
+// Match is the best catalog entry for a name and how close it was
+type Match struct {
+	Fragrance models.Fragrance
+	Score     float64
+}
+
+type candidate struct {
+	words    []string
+	lineOnly bool
+}
+
+type entry struct {
+	fragrance  models.Fragrance
+	candidates []candidate
+}
+
+// Resolver maps free-form perfume names to catalog entries
+type Resolver struct {
+	entries   []entry
+	Threshold float64
+}
+
+func NewResolver(fragrances []models.Fragrance) *Resolver {
+	r := &Resolver{Threshold: DefaultThreshold}
+	for _, f := range fragrances {
+		e := entry{fragrance: f}
+		lines := append([]string{f.Line}, f.Aliases...)
+		brands := append([]string{f.Brand}, f.BrandAliases...)
+		for _, line := range lines {
+			for _, brand := range brands {
+				e.candidates = append(e.candidates, candidate{words: tokens(brand + " " + line)})
+			}
+			e.candidates = append(e.candidates, candidate{words: tokens(line), lineOnly: true})
+		}
+		r.entries = append(r.entries, e)
+	}
+	return r
+}
+
+// Len is how many catalog entries the resolver knows about
+func (r *Resolver) Len() int {
+	return len(r.entries)
+}
+
+// Resolve returns the closest catalog entry to name, and whether it scored
+// at least Threshold. the best match is returned either way so callers can
+// suggest it for review.
+func (r *Resolver) Resolve(name string) (Match, bool) {
+	nameWords := tokens(name)
+	var best Match
+	for _, e := range r.entries {
+		for _, c := range e.candidates {
+			score := scoreCandidate(nameWords, c, e.fragrance.Concentration)
+			// ties go to the entry seen first, which is the lowest id
+			if score > best.Score {
+				best = Match{Fragrance: e.fragrance, Score: score}
+			}
+		}
+	}
+	return best, best.Score >= r.Threshold && best.Score > 0
+}
+
+func scoreCandidate(nameWords []string, c candidate, concentration string) float64 {
+	// concentration words only get set aside when they aren't part of the
+	// line itself, "Le Male Le Parfum" keeps its "parfum"
+	inCandidate := map[string]bool{}
+	for _, w := range c.words {
+		inCandidate[w] = true
+	}
+	var words []string
+	var named []string
+	for _, w := range nameWords {
+		if conc, ok := concentrationWords[w]; ok && !inCandidate[w] {
+			if conc != "" {
+				named = append(named, conc)
+			}
+			continue
+		}
+		words = append(words, w)
+	}
+	nameConc := pickConcentration(named, nameWords)
+
+	score := 0.5*trigramSimilarity(words, c.words) + 0.5*coverage(words, c.words)
+	if c.lineOnly {
+		score *= lineOnlyPenalty
+	}
+
+	switch {
+	case nameConc == "" || concentration == "":
+	case strings.EqualFold(nameConc, concentration):
+		score += 0.05
+	default:
+		score -= 0.15
+	}
+	return min(score, 1)
+}
+
+// "eau de parfum" is EDP, not Parfum
+func pickConcentration(named []string, nameWords []string) string {
+	if len(named) == 0 {
+		return ""
+	}
+	hasEau := false
+	for _, w := range nameWords {
+		if w == "eau" {
+			hasEau = true
+		}
+	}
+	if hasEau && named[0] == "Parfum" {
+		return "EDP"
+	}
+	return named[0]
+}
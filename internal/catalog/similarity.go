@@ -0,0 +1,136 @@
+package catalog
+
+import (
+	"strings"
+	"unicode"
+)
+
+// words that don't help tell fragrances apart
+var stopwords = map[string]bool{
+	"de": true, "by": true, "the": true, "and": true, "of": true, "pour": true, "for": true,
+}
+
+// tokens lowercases s, folds accents and apostrophes and splits it into words
+func tokens(s string) []string {
+	s = strings.ToLower(s)
+	s = strings.NewReplacer("'", "", "’", "", "&", " and ").Replace(s)
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(foldAccent(r))
+		default:
+			b.WriteRune(' ')
+		}
+	}
+
+	var out []string
+	for _, w := range strings.Fields(b.String()) {
+		if !stopwords[w] {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+func foldAccent(r rune) rune {
+	switch r {
+	case 'à', 'á', 'â', 'ä', 'ã', 'å':
+		return 'a'
+	case 'è', 'é', 'ê', 'ë':
+		return 'e'
+	case 'ì', 'í', 'î', 'ï':
+		return 'i'
+	case 'ò', 'ó', 'ô', 'ö', 'õ':
+		return 'o'
+	case 'ù', 'ú', 'û', 'ü':
+		return 'u'
+	case 'ç':
+		return 'c'
+	case 'ñ':
+		return 'n'
+	}
+	return r
+}
+
+// trigrams of the space-padded words, the same way pg_trgm builds them
+func trigrams(words []string) map[string]bool {
+	out := map[string]bool{}
+	for _, w := range words {
+		padded := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			out[string(padded[i:i+3])] = true
+		}
+	}
+	return out
+}
+
+// trigramSimilarity is |shared trigrams| / |all trigrams|, like pg_trgm's similarity()
+func trigramSimilarity(a, b []string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// coverage is how well the candidate's words are covered by the name's words,
+// each candidate word scoring its closest edit-distance match. typos like
+// "laytn" still mostly count, and extra words in the name don't hurt.
+func coverage(name, candidate []string) float64 {
+	if len(candidate) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, c := range candidate {
+		best := 0.0
+		for _, n := range name {
+			if s := wordSimilarity(n, c); s > best {
+				best = s
+			}
+		}
+		total += best
+	}
+	return total / float64(len(candidate))
+}
+
+// wordSimilarity is 1 - levenshtein/len, short words have to match exactly
+// since a one letter typo in "y" or "tf" is a different word entirely
+func wordSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if min(len(ra), len(rb)) < 4 {
+		return 0
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"frag-aggra/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// LoadCatalog returns every catalog entry with its brand's aliases
+func (r *Repository) LoadCatalog(ctx context.Context) ([]models.Fragrance, error) {
+	if r.dbpool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+	query := `
+		SELECT f.id, b.name, b.aliases, f.line, COALESCE(f.concentration, ''), f.aliases
+		FROM fragrances f
+		JOIN brands b ON b.id = f.brand_id
+		ORDER BY f.id
+	`
+	rows, err := r.dbpool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load catalog: %w", err)
+	}
+	fragrances, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Fragrance, error) {
+		var f models.Fragrance
+		err := row.Scan(&f.ID, &f.Brand, &f.BrandAliases, &f.Line, &f.Concentration, &f.Aliases)
+		return f, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan catalog: %w", err)
+	}
+	return fragrances, nil
+}
+
+// AddFragrance adds a catalog entry, creating the brand if it's new
+func (r *Repository) AddFragrance(ctx context.Context, f models.Fragrance) (int64, error) {
+	if r.dbpool == nil {
+		return 0, fmt.Errorf("database pool is not initialized")
+	}
+	if f.BrandAliases == nil {
+		f.BrandAliases = []string{}
+	}
+	if f.Aliases == nil {
+		f.Aliases = []string{}
+	}
+	tx, err := r.dbpool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var brandID int64
+	err = tx.QueryRow(ctx, `
+		INSERT INTO brands (name, aliases) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET
+			aliases = ARRAY(SELECT DISTINCT UNNEST(brands.aliases || EXCLUDED.aliases))
+		RETURNING id
+	`, f.Brand, f.BrandAliases).Scan(&brandID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to upsert brand: %w", err)
+	}
+
+	var id int64
+	err = tx.QueryRow(ctx, `
+		INSERT INTO fragrances (brand_id, line, concentration, aliases)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, brandID, f.Line, nullString(f.Concentration), f.Aliases).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert fragrance: %w", err)
+	}
+	return id, tx.Commit(ctx)
+}
+
+// EnqueueFragranceReview records a name the resolver wasn't sure about,
+// bumping its count if it's already waiting
+func (r *Repository) EnqueueFragranceReview(ctx context.Context, rawName string, suggestedID *int64, score float64) error {
+	if r.dbpool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+	_, err := r.dbpool.Exec(ctx, `
+		INSERT INTO fragrance_reviews (raw_name, suggested_fragrance_id, suggested_score)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (raw_name) DO UPDATE SET
+			seen_count = fragrance_reviews.seen_count + 1,
+			suggested_fragrance_id = EXCLUDED.suggested_fragrance_id,
+			suggested_score = EXCLUDED.suggested_score
+	`, rawName, suggestedID, score)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue fragrance review: %w", err)
+	}
+	return nil
+}
+
+// PendingFragranceReviews returns waiting reviews, most frequently seen first
+func (r *Repository) PendingFragranceReviews(ctx context.Context, limit int) ([]models.FragranceReview, error) {
+	if r.dbpool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+	rows, err := r.dbpool.Query(ctx, `
+		SELECT id, raw_name, suggested_fragrance_id, suggested_score, seen_count, status, created_at
+		FROM fragrance_reviews
+		WHERE status = $1
+		ORDER BY seen_count DESC, id
+		LIMIT $2
+	`, models.ReviewPending, clampLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fragrance reviews: %w", err)
+	}
+	reviews, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.FragranceReview, error) {
+		var rv models.FragranceReview
+		err := row.Scan(&rv.ID, &rv.RawName, &rv.SuggestedFragranceID, &rv.SuggestedScore,
+			&rv.SeenCount, &rv.Status, &rv.CreatedAt)
+		return rv, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan fragrance reviews: %w", err)
+	}
+	return reviews, nil
+}
+
+// ResolveFragranceReview maps a reviewed name to a catalog entry. the name is
+// added as an alias so it resolves on its own next time, and listings already
+// stored under it are linked. returns how many listings were updated.
+func (r *Repository) ResolveFragranceReview(ctx context.Context, reviewID, fragranceID int64) (int64, error) {
+	if r.dbpool == nil {
+		return 0, fmt.Errorf("database pool is not initialized")
+	}
+	tx, err := r.dbpool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var rawName string
+	err = tx.QueryRow(ctx, `
+		UPDATE fragrance_reviews
+		SET status = $2, resolved_fragrance_id = $3, resolved_at = NOW()
+		WHERE id = $1
+		RETURNING raw_name
+	`, reviewID, models.ReviewResolved, fragranceID).Scan(&rawName)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve review: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE fragrances SET aliases = array_append(aliases, $2)
+		WHERE id = $1 AND NOT ($2 = ANY(aliases))
+	`, fragranceID, rawName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add alias: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE listings SET fragrance_id = $1, resolve_score = 1
+		WHERE name = $2 AND fragrance_id IS NULL
+	`, fragranceID, rawName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to link listings: %w", err)
+	}
+	return tag.RowsAffected(), tx.Commit(ctx)
+}
+
+// IgnoreFragranceReview drops a name from the queue without resolving it
+func (r *Repository) IgnoreFragranceReview(ctx context.Context, reviewID int64) error {
+	if r.dbpool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+	tag, err := r.dbpool.Exec(ctx, `
+		UPDATE fragrance_reviews SET status = $2, resolved_at = NOW() WHERE id = $1
+	`, reviewID, models.ReviewIgnored)
+	if err != nil {
+		return fmt.Errorf("failed to ignore review: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
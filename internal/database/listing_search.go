@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"frag-aggra/internal/models"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultMinSimilarity is how close a misspelled name has to be to the query,
+// pg_trgm's word similarity from 0 to 1. "aventsu" is 0.63 to "Creed Aventus".
+const DefaultMinSimilarity = 0.5
+
+// how many brands a search lists in its facets
+const maxBrandFacets = 20
+
+// ErrEmptyQuery is returned by SearchListings for a blank query
+var ErrEmptyQuery = errors.New("search query is empty")
+
+// SearchFilter is a ranked name search, narrowed like a ListingFilter
+type SearchFilter struct {
+	Query         string  // what was typed, e.g. "mfk br540" or "creed aventsu"
+	Brand         string  // only listings resolved to this catalog brand, case-insensitive
+	Status        string  // available, sold or removed
+	MinSimilarity float64 // typo tolerance, 0 uses DefaultMinSimilarity
+	Cursor        string  // opaque, from a previous SearchPage.NextCursor
+	Limit         int
+}
+
+// SearchPage is one page of results, best first. Brands counts every match by
+// catalog brand, ignoring the filter's Brand so the other brands stay pickable.
+type SearchPage struct {
+	Results    []models.SearchResult `json:"results"`
+	Brands     []models.BrandFacet   `json:"brands"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+// the joins a search needs on top of listingSelect's, for the brand
+const searchFrom = `
+	FROM listings l
+	JOIN posts p ON p.id = l.post_id
+	LEFT JOIN fragrances f ON f.id = l.fragrance_id
+	LEFT JOIN brands b ON b.id = f.brand_id
+`
+
+// SearchListings finds listings whose name has every word of the query, or
+// is close to it with typos. every word matching ranks first, then how close
+// the name is to the query, shorter closer names ahead of longer ones.
+func (r *Repository) SearchListings(ctx context.Context, f SearchFilter) (SearchPage, error) {
+	if r.dbpool == nil {
+		return SearchPage{}, fmt.Errorf("database pool is not initialized")
+	}
+	query := strings.Join(strings.Fields(f.Query), " ")
+	if query == "" {
+		return SearchPage{}, ErrEmptyQuery
+	}
+	minSimilarity := f.MinSimilarity
+	if minSimilarity <= 0 || minSimilarity > 1 {
+		minSimilarity = DefaultMinSimilarity
+	}
+	var offset int64
+	if f.Cursor != "" {
+		var err error
+		if offset, err = decodeCursor(f.Cursor); err != nil {
+			return SearchPage{}, err
+		}
+	}
+
+	// $1 is the query everywhere
+	args := []any{query}
+	where := []string{`(l.name_tsv @@ websearch_to_tsquery('simple', $1) OR $1 <% l.name)`}
+	add := func(clause string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(clause, len(args)))
+	}
+	if f.Status != "" {
+		add("l.status = $%d", f.Status)
+	}
+	facetWhere, facetArgs := strings.Join(where, " AND "), append([]any(nil), args...)
+	if f.Brand != "" {
+		add("LOWER(b.name) = LOWER($%d)", f.Brand)
+	}
+
+	// the <% operator reads its threshold from a setting, local to this transaction
+	tx, err := r.dbpool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return SearchPage{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, `SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)`,
+		strconv.FormatFloat(minSimilarity, 'f', -1, 64))
+	if err != nil {
+		return SearchPage{}, fmt.Errorf("failed to set similarity threshold: %w", err)
+	}
+
+	limit := clampLimit(f.Limit)
+	rows, err := tx.Query(ctx, `SELECT `+listingFields+`, b.name,
+			(CASE WHEN l.name_tsv @@ websearch_to_tsquery('simple', $1) THEN 1 ELSE 0 END)
+				+ word_similarity($1, l.name) + similarity($1, l.name) AS score
+		`+searchFrom+`
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY score DESC, l.id DESC
+		`+fmt.Sprintf("LIMIT %d OFFSET %d", limit+1, offset), args...)
+	if err != nil {
+		return SearchPage{}, fmt.Errorf("failed to search listings: %w", err)
+	}
+	results, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.SearchResult, error) {
+		var s models.SearchResult
+		err := row.Scan(listingDest(&s.Listing, &s.Brand, &s.Score)...)
+		return s, err
+	})
+	if err != nil {
+		return SearchPage{}, fmt.Errorf("failed to scan search results: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, `SELECT b.name, COUNT(*)
+		`+searchFrom+`
+		WHERE `+facetWhere+` AND b.name IS NOT NULL
+		GROUP BY b.name
+		ORDER BY COUNT(*) DESC, b.name
+		`+fmt.Sprintf("LIMIT %d", maxBrandFacets), facetArgs...)
+	if err != nil {
+		return SearchPage{}, fmt.Errorf("failed to count brands: %w", err)
+	}
+	brands, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.BrandFacet, error) {
+		var b models.BrandFacet
+		err := row.Scan(&b.Brand, &b.Count)
+		return b, err
+	})
+	if err != nil {
+		return SearchPage{}, fmt.Errorf("failed to scan brand facets: %w", err)
+	}
+
+	page := SearchPage{Results: results, Brands: brands}
+	if len(results) > limit {
+		page.Results = results[:limit]
+		page.NextCursor = encodeCursor(offset + int64(limit))
+	}
+	return page, nil
+}
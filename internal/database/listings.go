@@ -0,0 +1,255 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"frag-aggra/internal/models"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	DefaultPageSize = 25
+	MaxPageSize     = 100
+)
+
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// ListingFilter narrows down FindListings, zero values are ignored
+type ListingFilter struct {
+	Name          string // case-insensitive substring of the perfume name
+	Seller        string // exact seller username, case-insensitive
+	Size          string // exact raw size (e.g. '100ml'), case-insensitive
+	MinPriceCents int64
+	MaxPriceCents int64
+	Status        string // available, sold or removed
+	FragranceID   int64  // catalog entry
+	Condition     string // new, tester or used
+	Box           string // boxed, unboxed or damaged
+	HasBatchCode  bool   // only listings that give a batch code
+	MinFill       int    // fill percent at least this, unknown fill levels are left out
+	Cursor        string // opaque, from a previous ListingPage.NextCursor
+	Limit         int
+}
+
+// ListingPage is one page of results, NextCursor is empty on the last page
+type ListingPage struct {
+	Listings   []models.Listing `json:"listings"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// every column listingDest scans, in order
+const listingFields = `
+	l.id, p.reddit_id, p.url, COALESCE(p.seller_username, ''),
+	l.name, COALESCE(l.size, ''), COALESCE(l.price, ''),
+	l.price_cents, l.currency, l.remaining_ml, l.capacity_ml,
+	l.bottle_kind, l.price_per_ml_cents, l.extracted_by, l.fragrance_id,
+	l.intent, l.condition, l.box, l.batch_code, l.fill_percent, l.quantity, l.notes,
+	l.status, l.status_changed_at, l.created_at
+`
+
+// selects every column collectListings expects, callers add the WHERE/ORDER BY
+const listingSelect = `SELECT ` + listingFields + `
+	FROM listings l
+	JOIN posts p ON p.id = l.post_id
+`
+
+// FindListings searches listings newest first, paging with an id keyset cursor
+func (r *Repository) FindListings(ctx context.Context, f ListingFilter) (ListingPage, error) {
+	if r.dbpool == nil {
+		return ListingPage{}, fmt.Errorf("database pool is not initialized")
+	}
+
+	var where []string
+	var args []any
+	add := func(clause string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(clause, len(args)))
+	}
+
+	if f.Name != "" {
+		add("l.name ILIKE '%%' || $%d || '%%'", escapeLike(f.Name))
+	}
+	if f.Seller != "" {
+		add("LOWER(p.seller_username) = LOWER($%d)", f.Seller)
+	}
+	if f.Size != "" {
+		add("LOWER(l.size) = LOWER($%d)", f.Size)
+	}
+	if f.MinPriceCents > 0 {
+		add("l.price_cents >= $%d", f.MinPriceCents)
+	}
+	if f.MaxPriceCents > 0 {
+		add("l.price_cents <= $%d", f.MaxPriceCents)
+	}
+	if f.FragranceID > 0 {
+		add("l.fragrance_id = $%d", f.FragranceID)
+	}
+	if f.Status != "" {
+		add("l.status = $%d", f.Status)
+	}
+	if f.Condition != "" {
+		add("l.condition = $%d", f.Condition)
+	}
+	if f.Box != "" {
+		add("l.box = $%d", f.Box)
+	}
+	if f.HasBatchCode {
+		where = append(where, "l.batch_code IS NOT NULL")
+	}
+	if f.MinFill > 0 {
+		add("l.fill_percent >= $%d", f.MinFill)
+	}
+	if f.Cursor != "" {
+		afterID, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return ListingPage{}, err
+		}
+		add("l.id < $%d", afterID)
+	}
+
+	limit := clampLimit(f.Limit)
+	query := listingSelect
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// grab one extra row to know if there is a next page
+	query += fmt.Sprintf(" ORDER BY l.id DESC LIMIT %d", limit+1)
+
+	rows, err := r.dbpool.Query(ctx, query, args...)
+	if err != nil {
+		return ListingPage{}, fmt.Errorf("failed to search listings: %w", err)
+	}
+	listings, err := collectListings(rows)
+	if err != nil {
+		return ListingPage{}, err
+	}
+
+	page := ListingPage{Listings: listings}
+	if len(listings) > limit {
+		page.Listings = listings[:limit]
+		page.NextCursor = encodeCursor(page.Listings[limit-1].ID)
+	}
+	return page, nil
+}
+
+// RecentListings returns the newest listings across every post
+func (r *Repository) RecentListings(ctx context.Context, limit int) ([]models.Listing, error) {
+	if r.dbpool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+	query := listingSelect + ` ORDER BY l.created_at DESC, l.id DESC LIMIT $1`
+	rows, err := r.dbpool.Query(ctx, query, clampLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recent listings: %w", err)
+	}
+	return collectListings(rows)
+}
+
+// GetPost returns the post with the given reddit id and all of its listings
+func (r *Repository) GetPost(ctx context.Context, redditID string) (*models.StoredPost, error) {
+	if r.dbpool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+
+	var post models.StoredPost
+	query := `
+		SELECT id, reddit_id, url, COALESCE(seller_username, ''), subreddit, source, trade_type, created_at
+		FROM posts WHERE reddit_id = $1
+	`
+	err := r.dbpool.QueryRow(ctx, query, redditID).Scan(
+		&post.ID, &post.RedditID, &post.URL, &post.SellerUsername,
+		&post.Subreddit, &post.Source, &post.TradeType, &post.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post: %w", err)
+	}
+
+	rows, err := r.dbpool.Query(ctx, listingSelect+` WHERE l.post_id = $1 ORDER BY l.id`, post.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query post listings: %w", err)
+	}
+	post.Listings, err = collectListings(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = r.dbpool.Query(ctx, wantSelect+` WHERE w.post_id = $1 ORDER BY w.id`, post.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query post wants: %w", err)
+	}
+	post.Wants, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.StoredWant, error) {
+		var w models.StoredWant
+		err := row.Scan(wantDest(&w)...)
+		return w, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan wants: %w", err)
+	}
+	return &post, nil
+}
+
+func collectListings(rows pgx.Rows) ([]models.Listing, error) {
+	listings, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Listing, error) {
+		var l models.Listing
+		err := row.Scan(listingDest(&l)...)
+		return l, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan listings: %w", err)
+	}
+	return listings, nil
+}
+
+// scan destinations matching listingFields, append any extra columns after
+func listingDest(l *models.Listing, extra ...any) []any {
+	return append([]any{
+		&l.ID, &l.RedditID, &l.PostURL, &l.SellerUsername,
+		&l.Name, &l.Size, &l.Price,
+		&l.PriceCents, &l.Currency, &l.RemainingML, &l.CapacityML,
+		&l.BottleKind, &l.PricePerMLCents, &l.ExtractedBy, &l.FragranceID,
+		&l.Intent, &l.Condition, &l.Box, &l.BatchCode, &l.FillPercent, &l.Quantity, &l.Notes,
+		&l.Status, &l.StatusChangedAt, &l.CreatedAt,
+	}, extra...)
+}
+
+func clampLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return limit
+}
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
+
+// so user input like "100%" doesn't turn into a wildcard
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"frag-aggra/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// buckets PriceHistory accepts, mapped to the interval each one spans
+var historyBuckets = map[string]string{
+	"day":   "1 day",
+	"week":  "1 week",
+	"month": "1 month",
+}
+
+// only listings with a usable per-ml price count towards market stats. prices
+// in other currencies would skew the percentiles so they're left out for now.
+const marketListingFilter = `
+	l.fragrance_id IS NOT NULL
+	AND l.bottle_kind IS NOT NULL
+	AND l.price_per_ml_cents IS NOT NULL
+	AND l.currency = 'USD'
+`
+
+// the bottle size a listing is priced against, by the full bottle's ml. a
+// 10ml travel spray and a 200ml flacon are both full bottles but go for very
+// different prices per ml, so the market is split by bottle kind and size band.
+const sizeBand = `
+	CASE
+		WHEN l.capacity_ml IS NULL THEN 'unknown'
+		WHEN l.capacity_ml <= 15 THEN '1-15ml'
+		WHEN l.capacity_ml <= 35 THEN '16-35ml'
+		WHEN l.capacity_ml <= 60 THEN '36-60ml'
+		WHEN l.capacity_ml <= 90 THEN '61-90ml'
+		WHEN l.capacity_ml <= 125 THEN '91-125ml'
+		ELSE '126ml+'
+	END
+`
+
+// median price per ml per fragrance, bottle kind and size band over the last $1 seconds,
+// shared by everything that compares a listing against the market
+const marketMedians = `
+	SELECT l.fragrance_id, l.bottle_kind, ` + sizeBand + ` AS size_band, COUNT(*) AS samples,
+		percentile_cont(0.5) WITHIN GROUP (ORDER BY l.price_per_ml_cents) AS median
+	FROM listings l
+	WHERE ` + marketListingFilter + `
+		AND l.created_at > NOW() - make_interval(secs => $1)
+	GROUP BY 1, 2, 3
+`
+
+// MarketStats returns p25/median/p75 price per ml for a fragrance, one row
+// per bottle kind and size band, over listings created in the last `window`
+func (r *Repository) MarketStats(ctx context.Context, fragranceID int64, window time.Duration) ([]models.MarketStats, error) {
+	if r.dbpool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+	query := `
+		SELECT l.fragrance_id, l.bottle_kind, ` + sizeBand + `, COUNT(*),
+			percentile_cont(0.25) WITHIN GROUP (ORDER BY l.price_per_ml_cents),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY l.price_per_ml_cents),
+			percentile_cont(0.75) WITHIN GROUP (ORDER BY l.price_per_ml_cents)
+		FROM listings l
+		WHERE ` + marketListingFilter + `
+			AND l.fragrance_id = $1
+			AND l.created_at > NOW() - make_interval(secs => $2)
+		GROUP BY 1, 2, 3
+		ORDER BY 2, MIN(l.capacity_ml) NULLS LAST
+	`
+	rows, err := r.dbpool.Query(ctx, query, fragranceID, window.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query market stats: %w", err)
+	}
+	stats, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.MarketStats, error) {
+		var s models.MarketStats
+		err := row.Scan(&s.FragranceID, &s.BottleKind, &s.SizeBand, &s.Samples, &s.P25, &s.Median, &s.P75)
+		return s, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan market stats: %w", err)
+	}
+	return stats, nil
+}
+
+// PriceHistory returns MarketStats per time bucket ("day", "week" or "month")
+// since `since`. each bucket's percentiles are over the rolling `window`
+// ending at that bucket's end, so short buckets don't get too few samples.
+func (r *Repository) PriceHistory(ctx context.Context, fragranceID int64, bucket string, window time.Duration, since time.Time) ([]models.PriceBucket, error) {
+	if r.dbpool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+	step, ok := historyBuckets[bucket]
+	if !ok {
+		return nil, fmt.Errorf("unknown bucket %q", bucket)
+	}
+	query := `
+		WITH buckets AS (
+			SELECT s AS bucket_start, s + $3::text::interval AS bucket_end
+			FROM generate_series(date_trunc($2, $4::timestamptz), date_trunc($2, NOW()), $3::text::interval) AS s
+		)
+		SELECT b.bucket_start, b.bucket_end, l.fragrance_id, l.bottle_kind, ` + sizeBand + `, COUNT(*),
+			percentile_cont(0.25) WITHIN GROUP (ORDER BY l.price_per_ml_cents),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY l.price_per_ml_cents),
+			percentile_cont(0.75) WITHIN GROUP (ORDER BY l.price_per_ml_cents)
+		FROM buckets b
+		JOIN listings l
+			ON l.created_at > b.bucket_end - make_interval(secs => $5)
+			AND l.created_at <= b.bucket_end
+		WHERE ` + marketListingFilter + `
+			AND l.fragrance_id = $1
+		GROUP BY 1, 2, 3, 4, 5
+		ORDER BY 1, 4, MIN(l.capacity_ml) NULLS LAST
+	`
+	rows, err := r.dbpool.Query(ctx, query, fragranceID, bucket, step, since, window.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price history: %w", err)
+	}
+	history, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.PriceBucket, error) {
+		var b models.PriceBucket
+		err := row.Scan(&b.BucketStart, &b.BucketEnd, &b.FragranceID, &b.BottleKind, &b.SizeBand, &b.Samples,
+			&b.P25, &b.Median, &b.P75)
+		return b, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan price history: %w", err)
+	}
+	return history, nil
+}
+
+// DealFilter tunes what counts as a deal
+type DealFilter struct {
+	Window     time.Duration // how far back the market median looks
+	Recent     time.Duration // only listings created this recently are candidates
+	MaxRatio   float64       // price per ml at most this fraction of the median, e.g. 0.8
+	MinSamples int           // medians from fewer listings than this aren't trusted
+	Limit      int
+}
+
+// Deals returns available listings priced at or below MaxRatio of their
+// fragrance, bottle kind and size band median, best deals first
+func (r *Repository) Deals(ctx context.Context, f DealFilter) ([]models.Deal, error) {
+	if r.dbpool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+	query := `
+		WITH market AS (` + marketMedians + `)
+		SELECT ` + listingFields + `, m.size_band, m.median, m.samples, l.price_per_ml_cents / m.median AS ratio
+		FROM listings l
+		JOIN posts p ON p.id = l.post_id
+		JOIN market m ON m.fragrance_id = l.fragrance_id AND m.bottle_kind = l.bottle_kind
+			AND m.size_band = ` + sizeBand + `
+		WHERE ` + marketListingFilter + `
+			AND l.status = 'available'
+			AND l.created_at > NOW() - make_interval(secs => $2)
+			AND m.samples >= $3
+			AND m.median > 0
+			AND l.price_per_ml_cents <= m.median * $4
+		ORDER BY ratio ASC, l.id DESC
+		LIMIT $5
+	`
+	rows, err := r.dbpool.Query(ctx, query, f.Window.Seconds(), f.Recent.Seconds(), f.MinSamples, f.MaxRatio, clampLimit(f.Limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deals: %w", err)
+	}
+	deals, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Deal, error) {
+		var d models.Deal
+		err := row.Scan(listingDest(&d.Listing, &d.SizeBand, &d.MarketMedian, &d.Samples, &d.Ratio)...)
+		return d, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan deals: %w", err)
+	}
+	return deals, nil
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"frag-aggra/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GetCachedParse returns the listing stored under key and counts the hit,
+// ok is false on a miss
+func (r *Repository) GetCachedParse(ctx context.Context, key string) (*models.FragranceListing, bool, error) {
+	if r.dbpool == nil {
+		return nil, false, fmt.Errorf("database pool is not initialized")
+	}
+	var raw []byte
+	err := r.dbpool.QueryRow(ctx, `
+		UPDATE parse_cache SET hits = hits + 1, last_hit_at = NOW()
+		WHERE key = $1
+		RETURNING listing
+	`, key).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get cached parse: %w", err)
+	}
+	var listing models.FragranceListing
+	if err := json.Unmarshal(raw, &listing); err != nil {
+		return nil, false, fmt.Errorf("failed to decode cached parse: %w", err)
+	}
+	return &listing, true, nil
+}
+
+// PutCachedParse stores a listing under key, keeping the first one on conflict
+func (r *Repository) PutCachedParse(ctx context.Context, key, promptVersion, model string, listing *models.FragranceListing) error {
+	if r.dbpool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+	raw, err := json.Marshal(listing)
+	if err != nil {
+		return fmt.Errorf("failed to encode parse: %w", err)
+	}
+	_, err = r.dbpool.Exec(ctx, `
+		INSERT INTO parse_cache (key, prompt_version, model, listing)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO NOTHING
+	`, key, promptVersion, model, raw)
+	if err != nil {
+		return fmt.Errorf("failed to cache parse: %w", err)
+	}
+	return nil
+}
+
+// PruneParseCache deletes entries that haven't been made or hit in maxAge.
+// entries from other prompt versions are left alone, workers on an older or
+// newer prompt still use theirs and they age out like any other.
+func (r *Repository) PruneParseCache(ctx context.Context, maxAge time.Duration) (int64, error) {
+	if r.dbpool == nil {
+		return 0, fmt.Errorf("database pool is not initialized")
+	}
+	tag, err := r.dbpool.Exec(ctx, `
+		DELETE FROM parse_cache WHERE COALESCE(last_hit_at, created_at) < $1
+	`, time.Now().Add(-maxAge))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune parse cache: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"frag-aggra/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const parseReviewSelect = `
+	SELECT id, reddit_id, post, listing, problems, attempts, prompt_version, seen_count, status, created_at
+	FROM parse_reviews`
+
+// EnqueueParseReview parks a post whose parse kept failing validation. a post
+// that lands here again replaces its last attempt and goes back to pending.
+func (r *Repository) EnqueueParseReview(ctx context.Context, post models.Post, listing *models.FragranceListing, problems []string, attempts int) error {
+	if r.dbpool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+	var promptVersion string
+	if listing != nil {
+		promptVersion = listing.PromptVersion
+	} else {
+		listing = &models.FragranceListing{}
+	}
+	_, err := r.dbpool.Exec(ctx, `
+		INSERT INTO parse_reviews (reddit_id, post, listing, problems, attempts, prompt_version)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (reddit_id) DO UPDATE SET
+			post = EXCLUDED.post,
+			listing = EXCLUDED.listing,
+			problems = EXCLUDED.problems,
+			attempts = EXCLUDED.attempts,
+			prompt_version = EXCLUDED.prompt_version,
+			seen_count = parse_reviews.seen_count + 1,
+			status = $7,
+			resolved_at = NULL
+	`, post.PostID, post, listing, problems, attempts, nullString(promptVersion), models.ReviewPending)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue parse review: %w", err)
+	}
+	return nil
+}
+
+// PendingParseReviews returns waiting reviews, oldest first
+func (r *Repository) PendingParseReviews(ctx context.Context, limit int) ([]models.ParseReview, error) {
+	if r.dbpool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+	rows, err := r.dbpool.Query(ctx, parseReviewSelect+` WHERE status = $1 ORDER BY created_at, id LIMIT $2`,
+		models.ReviewPending, clampLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query parse reviews: %w", err)
+	}
+	reviews, err := pgx.CollectRows(rows, scanParseReview)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan parse reviews: %w", err)
+	}
+	return reviews, nil
+}
+
+// GetParseReview returns one review, nil if there's no such id
+func (r *Repository) GetParseReview(ctx context.Context, id int64) (*models.ParseReview, error) {
+	if r.dbpool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+	rows, err := r.dbpool.Query(ctx, parseReviewSelect+` WHERE id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query parse review: %w", err)
+	}
+	review, err := pgx.CollectOneRow(rows, scanParseReview)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan parse review: %w", err)
+	}
+	return &review, nil
+}
+
+// ResolveParseReview stores the reviewed listing for the post and closes the
+// review. InsertItem refuses a post that already has listings, so accepting
+// twice can't duplicate them.
+func (r *Repository) ResolveParseReview(ctx context.Context, id int64, listing models.FragranceListing) error {
+	review, err := r.GetParseReview(ctx, id)
+	if err != nil {
+		return err
+	}
+	if review == nil || review.Status != models.ReviewPending {
+		return fmt.Errorf("no pending parse review %d", id)
+	}
+	if err := r.InsertItem(ctx, review.Post, listing); err != nil {
+		return err
+	}
+	return r.closeParseReview(ctx, id, models.ReviewResolved)
+}
+
+// IgnoreParseReview drops a post from the queue without storing anything
+func (r *Repository) IgnoreParseReview(ctx context.Context, id int64) error {
+	return r.closeParseReview(ctx, id, models.ReviewIgnored)
+}
+
+func (r *Repository) closeParseReview(ctx context.Context, id int64, status string) error {
+	if r.dbpool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+	tag, err := r.dbpool.Exec(ctx, `
+		UPDATE parse_reviews SET status = $2, resolved_at = NOW() WHERE id = $1 AND status = $3
+	`, id, status, models.ReviewPending)
+	if err != nil {
+		return fmt.Errorf("failed to close parse review: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("no pending parse review %d", id)
+	}
+	return nil
+}
+
+func scanParseReview(row pgx.CollectableRow) (models.ParseReview, error) {
+	var rv models.ParseReview
+	err := row.Scan(&rv.ID, &rv.RedditID, &rv.Post, &rv.Listing, &rv.Problems, &rv.Attempts,
+		&rv.PromptVersion, &rv.SeenCount, &rv.Status, &rv.CreatedAt)
+	return rv, err
+}
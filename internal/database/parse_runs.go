@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"frag-aggra/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RecordParseRun stores one parse of a post. parseErr is set when it failed,
+// listing is then nil or only carries the usage of the calls that were made.
+// the post is linked if it's been stored by then.
+func (r *Repository) RecordParseRun(ctx context.Context, redditID string, listing *models.FragranceListing, latency time.Duration, parseErr error) error {
+	if r.dbpool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+	var extractedBy, promptVersion, errMsg string
+	var usage models.ParseUsage
+	if listing != nil {
+		extractedBy, promptVersion = listing.ExtractedBy, listing.PromptVersion
+		if listing.Usage != nil {
+			usage = *listing.Usage
+		}
+	}
+	if parseErr != nil {
+		errMsg = parseErr.Error()
+	}
+
+	_, err := r.dbpool.Exec(ctx, `
+		INSERT INTO parse_runs (reddit_id, post_id, extracted_by, provider, model,
+			prompt_tokens, completion_tokens, latency_ms, cost_usd, error, cached, prompt_version)
+		VALUES ($1, (SELECT id FROM posts WHERE reddit_id = $1), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, redditID, nullString(extractedBy), nullString(usage.Provider), nullString(usage.Model),
+		usage.PromptTokens, usage.CompletionTokens, latency.Milliseconds(), usage.CostUSD, nullString(errMsg), usage.Cached,
+		nullString(promptVersion))
+	if err != nil {
+		return fmt.Errorf("failed to record parse run: %w", err)
+	}
+	return nil
+}
+
+// ParseSpendSince is the estimated llm spend in USD of every parse since `since`
+func (r *Repository) ParseSpendSince(ctx context.Context, since time.Time) (float64, error) {
+	if r.dbpool == nil {
+		return 0, fmt.Errorf("database pool is not initialized")
+	}
+	var spent float64
+	err := r.dbpool.QueryRow(ctx,
+		`SELECT COALESCE(SUM(cost_usd), 0)::float8 FROM parse_runs WHERE created_at >= $1`, since,
+	).Scan(&spent)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum parse spend: %w", err)
+	}
+	return spent, nil
+}
+
+// ParseSpendByDay sums parse runs per UTC day since `since`, newest first
+func (r *Repository) ParseSpendByDay(ctx context.Context, since time.Time) ([]models.ParseSpend, error) {
+	if r.dbpool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+	rows, err := r.dbpool.Query(ctx, `
+		SELECT date_trunc('day', created_at AT TIME ZONE 'UTC') AS day, COUNT(*),
+			COUNT(*) FILTER (WHERE model IS NOT NULL AND NOT cached),
+			COUNT(*) FILTER (WHERE cached),
+			COUNT(*) FILTER (WHERE error IS NOT NULL),
+			COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0),
+			COALESCE(SUM(cost_usd), 0)::float8
+		FROM parse_runs
+		WHERE created_at >= $1
+		GROUP BY day
+		ORDER BY day DESC
+	`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query parse spend: %w", err)
+	}
+	spend, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.ParseSpend, error) {
+		var s models.ParseSpend
+		err := row.Scan(&s.Day, &s.Runs, &s.LLMRuns, &s.CacheHits, &s.Failed, &s.PromptTokens, &s.CompletionTokens, &s.CostUSD)
+		return s, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan parse spend: %w", err)
+	}
+	return spend, nil
+}
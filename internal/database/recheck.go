@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"frag-aggra/internal/models"
+	"frag-aggra/internal/normalize"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RecheckCandidate is a post due for a look at whether it was edited
+type RecheckCandidate struct {
+	ID       int64
+	RedditID string
+	BodyHash string // empty for posts stored before hashes existed
+}
+
+// PostsForRecheck returns posts created since `since` that haven't been
+// checked since `checkedBefore`, least recently checked first
+func (r *Repository) PostsForRecheck(ctx context.Context, since, checkedBefore time.Time, limit int) ([]RecheckCandidate, error) {
+	if r.dbpool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+	query := `
+		SELECT id, reddit_id, COALESCE(body_hash, '')
+		FROM posts
+		WHERE created_at >= $1
+			AND (last_checked_at IS NULL OR last_checked_at < $2)
+		ORDER BY last_checked_at ASC NULLS FIRST, id
+		LIMIT $3
+	`
+	rows, err := r.dbpool.Query(ctx, query, since, checkedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query posts for recheck: %w", err)
+	}
+	candidates, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (RecheckCandidate, error) {
+		var c RecheckCandidate
+		err := row.Scan(&c.ID, &c.RedditID, &c.BodyHash)
+		return c, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan recheck candidates: %w", err)
+	}
+	return candidates, nil
+}
+
+// MarkPostChecked records that a post was looked at and what its content hash was
+func (r *Repository) MarkPostChecked(ctx context.Context, postID int64, bodyHash string) error {
+	if r.dbpool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+	_, err := r.dbpool.Exec(ctx,
+		`UPDATE posts SET body_hash = $2, last_checked_at = NOW() WHERE id = $1`,
+		postID, nullString(bodyHash))
+	if err != nil {
+		return fmt.Errorf("failed to mark post checked: %w", err)
+	}
+	return nil
+}
+
+// ApplyListingDiff writes the result of re-parsing an edited post in one
+// transaction: status and price changes on existing rows, new rows for new
+// items, and the post's new content hash.
+func (r *Repository) ApplyListingDiff(ctx context.Context, postID int64, bodyHash string, diff models.ListingDiff) error {
+	if r.dbpool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+	tx, err := r.dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for id, status := range diff.Status {
+		batch.Queue(`
+			UPDATE listings SET status = $3, status_changed_at = NOW()
+			WHERE id = $1 AND post_id = $2 AND status <> $3
+		`, id, postID, status)
+	}
+	for id, price := range diff.Repriced {
+		var size string
+		err := tx.QueryRow(ctx, `SELECT COALESCE(size, '') FROM listings WHERE id = $1 AND post_id = $2`, id, postID).Scan(&size)
+		if err != nil {
+			return fmt.Errorf("failed to load listing %d for reprice: %w", id, err)
+		}
+		// recompute the normalized price columns from the new price
+		n := normalize.Normalize(size, price)
+		batch.Queue(`
+			UPDATE listings SET price = $3, price_cents = $4, currency = $5,
+				price_per_ml_cents = $6, normalize_error = $7
+			WHERE id = $1 AND post_id = $2
+		`, id, postID, price, n.PriceCents, nullString(n.Currency), n.PricePerMLCents, nullString(n.Error))
+	}
+	if batch.Len() > 0 {
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return fmt.Errorf("failed to update listings: %w", err)
+		}
+	}
+
+	if rows := listingRows(postID, diff.Added); len(rows) > 0 {
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"listings"}, listingColumns, pgx.CopyFromRows(rows))
+		if err != nil {
+			return fmt.Errorf("failed to copy new listings: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE posts SET body_hash = $2, last_checked_at = NOW() WHERE id = $1`,
+		postID, nullString(bodyHash))
+	if err != nil {
+		return fmt.Errorf("failed to update post hash: %w", err)
+	}
+	return tx.Commit(ctx)
+}
@@ -2,9 +2,12 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"frag-aggra/internal/models"
+	"frag-aggra/internal/normalize"
 	"log"
+	"math"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -49,6 +52,17 @@ func (r *Repository) PostExists(ctx context.Context, redditID string) (bool, err
 	return exists, nil
 }
 
+// DeletePost removes a post and, through the cascades, everything parsed from it
+func (r *Repository) DeletePost(ctx context.Context, redditID string) error {
+	if r.dbpool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+	if _, err := r.dbpool.Exec(ctx, `DELETE FROM posts WHERE reddit_id = $1`, redditID); err != nil {
+		return fmt.Errorf("failed to delete post: %w", err)
+	}
+	return nil
+}
+
 // querying example
 func (r *Repository) QueryRows(ctx context.Context, query string, args ...any) (pgx.Rows, error) {
 	if r.dbpool == nil {
@@ -61,6 +75,13 @@ func (r *Repository) QueryRows(ctx context.Context, query string, args ...any) (
 	return rows, nil
 }
 
+// ErrAlreadyStored is returned by InsertItem for a post whose listings were
+// stored already, e.g. by a second delivery of the same post
+var ErrAlreadyStored = errors.New("post already has listings stored")
+
+// InsertItem stores a post and what was parsed out of it. inserts of the same
+// post are serialized on an advisory lock and only the first one copies its
+// listings and wants, the others get ErrAlreadyStored.
 func (r *Repository) InsertItem(ctx context.Context, post models.Post, listing models.FragranceListing) error {
 	if r.dbpool == nil {
 		return fmt.Errorf("database pool is not initialized")
@@ -71,50 +92,67 @@ func (r *Repository) InsertItem(ctx context.Context, post models.Post, listing m
 	}
 	//if not committed, rollback
 	defer tx.Rollback(ctx)
+	// held until commit, a concurrent insert of the same post waits here and then sees our rows
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, post.PostID); err != nil {
+		return fmt.Errorf("failed to lock post %s: %w", post.PostID, err)
+	}
 	// get the unique postID to act as foreign key
 	var postID int64
 
 	postInsertQuery := `
-		INSERT INTO posts (reddit_id, url, seller_username)
-		VALUES ($1, $2, $3)
+		INSERT INTO posts (reddit_id, url, seller_username, body_hash, last_checked_at, subreddit, source, trade_type)
+		VALUES ($1, $2, $3, $4, NOW(), $5, $6, $7)
 		ON CONFLICT (reddit_id) DO UPDATE SET
 			url = EXCLUDED.url,
-			seller_username = EXCLUDED.seller_username
+			seller_username = EXCLUDED.seller_username,
+			subreddit = COALESCE(EXCLUDED.subreddit, posts.subreddit),
+			source = COALESCE(EXCLUDED.source, posts.source),
+			trade_type = COALESCE(EXCLUDED.trade_type, posts.trade_type),
+			body_hash = EXCLUDED.body_hash,
+			last_checked_at = EXCLUDED.last_checked_at
 		RETURNING id
 	`
-	err = tx.QueryRow(ctx, postInsertQuery, post.PostID, post.URL, post.SellerUsername).Scan(&postID)
+	err = tx.QueryRow(ctx, postInsertQuery, post.PostID, post.URL, post.SellerUsername, post.ContentHash(),
+		nullString(post.Subreddit), nullString(post.Source), nullString(post.TradeType)).Scan(&postID)
 	if err != nil {
 		return fmt.Errorf("failed to insert post: %w", err)
 	}
 
-	rows := [][]any{}
-	for _, perfume := range listing.Perfumes {
-		//iterate through the sizes
-		for i, size := range perfume.Sizes {
-			if i >= len(perfume.Prices) {
-				// Log the inconsistency and break the inner loop for this perfume.
-				// This prevents the panic and safely skips the corrupted data.
-				log.Printf("warning: mismatched sizes and prices for perfume '%s'. Sizes: %d, Prices: %d. Skipping remaining items.", perfume.Name, len(perfume.Sizes), len(perfume.Prices))
-				break
-			}
-			price := perfume.Prices[i]
-			rows = append(rows, []any{postID, perfume.Name, size, price})
+	var stored bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM listings WHERE post_id = $1) OR EXISTS(SELECT 1 FROM wants WHERE post_id = $1)
+	`, postID).Scan(&stored)
+	if err != nil {
+		return fmt.Errorf("failed to check stored listings: %w", err)
+	}
+	if stored {
+		return ErrAlreadyStored
+	}
+
+	if post.SellerUsername != "" {
+		if err := upsertSeller(ctx, tx, post); err != nil {
+			return err
 		}
 	}
 
-	if len(rows) == 0 {
+	rows := listingRows(postID, listing)
+	wants := wantRows(postID, listing.Wants)
+	if len(rows) == 0 && len(wants) == 0 {
 		log.Printf("No valid listing found to insert %s", post.URL)
 		return tx.Commit(ctx)
 	}
 
-	_, err = tx.CopyFrom(
-		ctx,
-		pgx.Identifier{"listings"},
-		[]string{"post_id", "name", "size", "price"},
-		pgx.CopyFromRows(rows),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to copy from rows: %w", err)
+	if len(rows) > 0 {
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"listings"}, listingColumns, pgx.CopyFromRows(rows))
+		if err != nil {
+			return fmt.Errorf("failed to copy from rows: %w", err)
+		}
+	}
+	if len(wants) > 0 {
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"wants"}, wantColumns, pgx.CopyFromRows(wants))
+		if err != nil {
+			return fmt.Errorf("failed to copy wants: %w", err)
+		}
 	}
 
 	return tx.Commit(ctx)
@@ -127,3 +165,136 @@ func (r *Repository) Ping(ctx context.Context) error {
 	}
 	return r.dbpool.Ping(ctx)
 }
+
+// columns listingRows fills, in order
+var listingColumns = []string{
+	"post_id", "name", "size", "price",
+	"price_cents", "currency", "remaining_ml", "capacity_ml",
+	"bottle_kind", "price_per_ml_cents", "normalize_error",
+	"extracted_by", "fragrance_id", "resolve_score", "intent", "prompt_version",
+	"condition", "quantity", "notes", "box", "batch_code", "fill_percent",
+}
+
+// flattens every perfume's offers into rows for CopyFrom
+func listingRows(postID int64, listing models.FragranceListing) [][]any {
+	rows := [][]any{}
+	for _, perfume := range listing.Perfumes {
+		for _, offer := range perfume.Offers {
+			rows = append(rows, listingRow(postID, perfume, offer, listing))
+		}
+	}
+	return rows
+}
+
+func listingRow(postID int64, perfume models.Perfume, offer models.Offer, listing models.FragranceListing) []any {
+	// keep rows that fail to normalize, they just get flagged
+	n := normalize.Normalize(offer.Size, offer.Price)
+	if !n.OK() {
+		log.Printf("warning: could not normalize '%s' (%s, %s): %s", perfume.Name, offer.Size, offer.Price, n.Error)
+	}
+	var resolveScore *float64
+	if perfume.FragranceID != nil {
+		resolveScore = &perfume.ResolveScore
+	}
+	return []any{
+		postID, perfume.Name, offer.Size, offer.Price,
+		n.PriceCents, nullString(n.Currency), n.RemainingML, n.CapacityML,
+		nullString(string(n.Kind)), n.PricePerMLCents, nullString(n.Error),
+		nullString(listing.ExtractedBy), perfume.FragranceID, resolveScore, intent(listing),
+		nullString(listing.PromptVersion),
+		known(offer.Condition), quantity(offer), nullString(offer.Notes),
+		known(offer.Box), nullString(offer.BatchCode), fillPercent(offer, n),
+	}
+}
+
+// "unknown" is stored as NULL like anything else the post didn't say
+func known(s string) *string {
+	if s == models.ConditionUnknown {
+		return nil
+	}
+	return nullString(s)
+}
+
+// the fill level the post gave, or the one a partial's "80/100ml" implies
+func fillPercent(offer models.Offer, n normalize.Listing) *int {
+	if offer.FillPercent > 0 && offer.FillPercent <= 100 {
+		return &offer.FillPercent
+	}
+	if n.Kind == normalize.KindPartial && n.RemainingML != nil && n.CapacityML != nil && *n.CapacityML > 0 && *n.RemainingML <= *n.CapacityML {
+		fill := int(math.Round(*n.RemainingML / *n.CapacityML * 100))
+		return &fill
+	}
+	return nil
+}
+
+// an offer nobody counted is one bottle
+func quantity(offer models.Offer) int {
+	return max(offer.Quantity, 1)
+}
+
+// listings parsed before intent existed, or that nothing gave one, are sales
+func intent(listing models.FragranceListing) string {
+	if listing.Intent == "" {
+		return models.IntentSell
+	}
+	return listing.Intent
+}
+
+// columns wantRows fills, in order
+var wantColumns = []string{
+	"post_id", "name", "size", "capacity_ml",
+	"max_price", "max_price_cents", "currency", "fragrance_id", "resolve_score",
+}
+
+// one row per wanted size, or a single size-less row when any size will do
+func wantRows(postID int64, wants []models.Want) [][]any {
+	rows := [][]any{}
+	for _, want := range wants {
+		if want.Name == "" {
+			continue
+		}
+		var maxPriceCents *int64
+		var currency string
+		if want.MaxPrice != "" {
+			cents, cur, err := normalize.ParsePrice(want.MaxPrice)
+			if err != nil {
+				log.Printf("warning: could not parse max price '%s' for want '%s': %v", want.MaxPrice, want.Name, err)
+			} else {
+				maxPriceCents, currency = &cents, cur
+			}
+		}
+		var resolveScore *float64
+		if want.FragranceID != nil {
+			resolveScore = &want.ResolveScore
+		}
+
+		sizes := []string{""}
+		if len(want.Sizes) > 0 {
+			sizes = want.Sizes
+		}
+		for _, size := range sizes {
+			var capacity *float64
+			if size != "" {
+				_, c, err := normalize.ParseSize(size)
+				if err != nil {
+					log.Printf("warning: could not parse size '%s' for want '%s': %v", size, want.Name, err)
+				} else {
+					capacity = &c
+				}
+			}
+			rows = append(rows, []any{
+				postID, want.Name, nullString(size), capacity,
+				nullString(want.MaxPrice), maxPriceCents, nullString(currency), want.FragranceID, resolveScore,
+			})
+		}
+	}
+	return rows
+}
+
+// empty strings go in as NULL
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,108 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"frag-aggra/internal/database"
+	"frag-aggra/internal/evaluate"
+	"os"
+	"testing"
+)
+
+// runs against TEST_DATABASE_URL, a migrated database the test may write to
+func testRepo(t *testing.T) *database.Repository {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	repo, err := database.New(context.Background(), dbURL)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(repo.Close)
+	return repo
+}
+
+// every fixture's labels stored through InsertItem come back as the rows the
+// fixture pins down, or the ones normalize produces when it doesn't
+func TestInsertItemRoundTrip(t *testing.T) {
+	repo := testRepo(t)
+	ctx := context.Background()
+	fixtures, err := evaluate.LoadFixtures("../../testdata/eval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range fixtures {
+		t.Run(f.Name, func(t *testing.T) {
+			post := f.Post
+			post.PostID = "test_" + post.PostID
+			post.URL += "#test"
+			_ = repo.DeletePost(ctx, post.PostID)
+			t.Cleanup(func() { _ = repo.DeletePost(ctx, post.PostID) })
+
+			if err := repo.InsertItem(ctx, post, f.Expected); err != nil {
+				t.Fatalf("InsertItem: %v", err)
+			}
+			stored, err := repo.GetPost(ctx, post.PostID)
+			if err != nil {
+				t.Fatalf("GetPost: %v", err)
+			}
+			expected := f.Stored
+			if len(expected) == 0 {
+				expected = evaluate.NormalizedRows(&f.Expected)
+			}
+			for _, d := range evaluate.CompareRows(expected, stored.Listings) {
+				t.Error(d)
+			}
+			wants := 0
+			for _, w := range f.Expected.Wants {
+				wants += max(len(w.Sizes), 1)
+			}
+			if len(stored.Wants) != wants {
+				t.Errorf("stored %d wants, expected %d", len(stored.Wants), wants)
+			}
+		})
+	}
+}
+
+// two deliveries of the same post racing each other store its listings once
+func TestInsertItemConcurrentDeliveries(t *testing.T) {
+	repo := testRepo(t)
+	ctx := context.Background()
+	fixtures, err := evaluate.LoadFixtures("../../testdata/eval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := fixtures[0]
+	post := f.Post
+	post.PostID = "test_concurrent_" + post.PostID
+	post.URL += "#concurrent"
+	_ = repo.DeletePost(ctx, post.PostID)
+	t.Cleanup(func() { _ = repo.DeletePost(ctx, post.PostID) })
+
+	errs := make(chan error, 4)
+	for range cap(errs) {
+		go func() { errs <- repo.InsertItem(ctx, post, f.Expected) }()
+	}
+	inserted := 0
+	for range cap(errs) {
+		err := <-errs
+		switch {
+		case err == nil:
+			inserted++
+		case !errors.Is(err, database.ErrAlreadyStored):
+			t.Errorf("InsertItem: %v", err)
+		}
+	}
+	if inserted != 1 {
+		t.Errorf("%d inserts stored listings, want 1", inserted)
+	}
+	stored, err := repo.GetPost(ctx, post.PostID)
+	if err != nil {
+		t.Fatalf("GetPost: %v", err)
+	}
+	if want := len(evaluate.NormalizedRows(&f.Expected)); len(stored.Listings) != want {
+		t.Errorf("stored %d listings, want %d", len(stored.Listings), want)
+	}
+}
@@ -0,0 +1,215 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"frag-aggra/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateSavedSearch stores s and returns its id
+func (r *Repository) CreateSavedSearch(ctx context.Context, s models.SavedSearch) (int64, error) {
+	if r.dbpool == nil {
+		return 0, fmt.Errorf("database pool is not initialized")
+	}
+	if s.SellerBlocklist == nil {
+		s.SellerBlocklist = []string{}
+	}
+	query := `
+		INSERT INTO saved_searches (name, name_pattern, min_ml, max_ml, bottle_kind,
+			max_price_cents, seller_blocklist, notify_kind, notify_target, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, TRUE)
+		RETURNING id
+	`
+	var id int64
+	err := r.dbpool.QueryRow(ctx, query, s.Name, s.NamePattern, s.MinML, s.MaxML, s.BottleKind,
+		s.MaxPriceCents, s.SellerBlocklist, s.NotifyKind, s.NotifyTarget).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create saved search: %w", err)
+	}
+	return id, nil
+}
+
+// ListSavedSearches returns every saved search, or only the active ones
+func (r *Repository) ListSavedSearches(ctx context.Context, activeOnly bool) ([]models.SavedSearch, error) {
+	if r.dbpool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+	query := `
+		SELECT id, name, name_pattern, min_ml, max_ml, bottle_kind, max_price_cents,
+			seller_blocklist, notify_kind, notify_target, active, created_at
+		FROM saved_searches
+		WHERE active OR NOT $1
+		ORDER BY id
+	`
+	rows, err := r.dbpool.Query(ctx, query, activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list saved searches: %w", err)
+	}
+	searches, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.SavedSearch, error) {
+		var s models.SavedSearch
+		err := row.Scan(&s.ID, &s.Name, &s.NamePattern, &s.MinML, &s.MaxML, &s.BottleKind,
+			&s.MaxPriceCents, &s.SellerBlocklist, &s.NotifyKind, &s.NotifyTarget, &s.Active, &s.CreatedAt)
+		return s, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan saved searches: %w", err)
+	}
+	return searches, nil
+}
+
+// DeleteSavedSearch removes a saved search and its notification history
+func (r *Repository) DeleteSavedSearch(ctx context.Context, id int64) error {
+	if r.dbpool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+	tag, err := r.dbpool.Exec(ctx, `DELETE FROM saved_searches WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete saved search: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// a notification is tried this many times before it's given up on
+const NotificationMaxAttempts = 5
+
+// how long a claim holds a notification before another matcher may retry it,
+// in case the one that claimed it died before finishing
+const notificationLease = `INTERVAL '10 minutes'`
+
+// ClaimNotification records that listingID matched searchID. it returns false
+// when that pair was already sent, is being sent, or isn't due for a retry
+// yet, so each listing alerts at most once per search.
+func (r *Repository) ClaimNotification(ctx context.Context, searchID, listingID int64) (int64, bool, error) {
+	if r.dbpool == nil {
+		return 0, false, fmt.Errorf("database pool is not initialized")
+	}
+	query := `
+		INSERT INTO notifications (saved_search_id, listing_id, next_attempt_at)
+		VALUES ($1, $2, NOW() + ` + notificationLease + `)
+		ON CONFLICT (saved_search_id, listing_id) DO UPDATE
+		SET attempts = notifications.attempts + 1, next_attempt_at = NOW() + ` + notificationLease + `
+		WHERE notifications.sent_at IS NULL
+			AND notifications.next_attempt_at <= NOW()
+			AND notifications.attempts < $3
+		RETURNING id
+	`
+	var id int64
+	err := r.dbpool.QueryRow(ctx, query, searchID, listingID, NotificationMaxAttempts).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to claim notification: %w", err)
+	}
+	return id, true, nil
+}
+
+// FinishNotification marks a claimed notification as sent, or stores why it
+// wasn't and when to try again: 1, 4, 16 then 64 minutes later, never after
+// the last attempt
+func (r *Repository) FinishNotification(ctx context.Context, id int64, sendErr error) error {
+	if r.dbpool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+	var err error
+	if sendErr != nil {
+		query := `
+			UPDATE notifications SET error = $2,
+				next_attempt_at = CASE WHEN attempts < $3
+					THEN NOW() + INTERVAL '1 minute' * POWER(4, attempts - 1) END
+			WHERE id = $1
+		`
+		_, err = r.dbpool.Exec(ctx, query, id, sendErr.Error(), NotificationMaxAttempts)
+	} else {
+		_, err = r.dbpool.Exec(ctx, `UPDATE notifications SET sent_at = NOW(), error = NULL, next_attempt_at = NULL WHERE id = $1`, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update notification: %w", err)
+	}
+	return nil
+}
+
+// DueNotification is a failed notification whose retry is due
+type DueNotification struct {
+	Search  models.SavedSearch
+	Listing models.Listing
+}
+
+// DueNotifications returns up to limit failed notifications that are due for
+// another attempt, for active searches and listings still available
+func (r *Repository) DueNotifications(ctx context.Context, limit int) ([]DueNotification, error) {
+	if r.dbpool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+	query := `
+		SELECT n.saved_search_id, n.listing_id
+		FROM notifications n
+		JOIN saved_searches s ON s.id = n.saved_search_id
+		JOIN listings l ON l.id = n.listing_id
+		WHERE n.sent_at IS NULL AND n.next_attempt_at <= NOW() AND n.attempts < $1
+			AND s.active AND l.status = $2
+		ORDER BY n.next_attempt_at
+		LIMIT $3
+	`
+	rows, err := r.dbpool.Query(ctx, query, NotificationMaxAttempts, models.ListingAvailable, clampLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due notifications: %w", err)
+	}
+	type pair struct{ searchID, listingID int64 }
+	pairs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (pair, error) {
+		var p pair
+		err := row.Scan(&p.searchID, &p.listingID)
+		return p, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan due notifications: %w", err)
+	}
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	searches, err := r.ListSavedSearches(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]models.SavedSearch, len(searches))
+	for _, s := range searches {
+		byID[s.ID] = s
+	}
+	ids := make([]int64, 0, len(pairs))
+	for _, p := range pairs {
+		ids = append(ids, p.listingID)
+	}
+	rows, err = r.dbpool.Query(ctx, listingSelect+` WHERE l.id = ANY($1)`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due listings: %w", err)
+	}
+	listings, err := collectListings(rows)
+	if err != nil {
+		return nil, err
+	}
+	listingByID := make(map[int64]models.Listing, len(listings))
+	for _, l := range listings {
+		listingByID[l.ID] = l
+	}
+
+	due := make([]DueNotification, 0, len(pairs))
+	for _, p := range pairs {
+		search, ok := byID[p.searchID]
+		if !ok {
+			continue
+		}
+		listing, ok := listingByID[p.listingID]
+		if !ok {
+			continue
+		}
+		due = append(due, DueNotification{Search: search, Listing: listing})
+	}
+	return due, nil
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"frag-aggra/internal/models"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SellerMarketWindow is how far back the market medians a seller's prices are
+// compared against look
+const SellerMarketWindow = 90 * 24 * time.Hour
+
+// keeps the flair from the newest post that had one
+func upsertSeller(ctx context.Context, tx pgx.Tx, post models.Post) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO sellers (username, flair, confirmed_trades, flair_updated_at)
+		VALUES ($1, $2, $3, CASE WHEN $2::text IS NULL THEN NULL ELSE NOW() END)
+		ON CONFLICT (username) DO UPDATE SET
+			flair = COALESCE(EXCLUDED.flair, sellers.flair),
+			confirmed_trades = CASE WHEN EXCLUDED.flair IS NULL THEN sellers.confirmed_trades
+				ELSE EXCLUDED.confirmed_trades END,
+			flair_updated_at = COALESCE(EXCLUDED.flair_updated_at, sellers.flair_updated_at)
+	`, post.SellerUsername, nullString(post.SellerFlair), post.SellerTrades)
+	if err != nil {
+		return fmt.Errorf("failed to upsert seller: %w", err)
+	}
+	return nil
+}
+
+// GetSeller returns one seller's history, ErrNotFound if they never posted
+func (r *Repository) GetSeller(ctx context.Context, username string) (*models.Seller, error) {
+	sellers, err := r.Sellers(ctx, []string{username})
+	if err != nil {
+		return nil, err
+	}
+	if len(sellers) == 0 {
+		return nil, ErrNotFound
+	}
+	return &sellers[0], nil
+}
+
+// Sellers aggregates post counts, first/last seen, price level against the
+// market and sell-through time for each username, case-insensitively.
+// usernames with no posts are left out.
+func (r *Repository) Sellers(ctx context.Context, usernames []string) ([]models.Seller, error) {
+	if r.dbpool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+	// $1 is the market window, marketMedians expects it there
+	query := `
+		WITH wanted AS (
+			SELECT DISTINCT LOWER(u) AS username FROM unnest($2::text[]) AS u
+		),
+		activity AS (
+			SELECT MIN(p.seller_username) AS username, LOWER(p.seller_username) AS key,
+				COUNT(*) AS posts, MIN(p.created_at) AS first_seen, MAX(p.created_at) AS last_seen
+			FROM posts p
+			JOIN wanted w ON w.username = LOWER(p.seller_username)
+			GROUP BY LOWER(p.seller_username)
+		),
+		market AS (` + marketMedians + `),
+		pricing AS (
+			SELECT LOWER(p.seller_username) AS key, COUNT(*) AS priced,
+				AVG(l.price_per_ml_cents / m.median) AS price_ratio
+			FROM listings l
+			JOIN posts p ON p.id = l.post_id
+			JOIN wanted w ON w.username = LOWER(p.seller_username)
+			JOIN market m ON m.fragrance_id = l.fragrance_id AND m.bottle_kind = l.bottle_kind
+				AND m.size_band = ` + sizeBand + `
+			WHERE ` + marketListingFilter + `
+				AND m.median > 0
+			GROUP BY LOWER(p.seller_username)
+		),
+		selling AS (
+			SELECT LOWER(p.seller_username) AS key, COUNT(*) AS listings,
+				COUNT(*) FILTER (WHERE l.status = 'sold') AS sold,
+				percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM l.status_changed_at - l.created_at))
+					FILTER (WHERE l.status = 'sold') AS sell_secs
+			FROM listings l
+			JOIN posts p ON p.id = l.post_id
+			JOIN wanted w ON w.username = LOWER(p.seller_username)
+			GROUP BY LOWER(p.seller_username)
+		)
+		SELECT a.username, a.posts, a.first_seen, a.last_seen,
+			COALESCE(sl.listings, 0), COALESCE(sl.sold, 0), sl.sell_secs,
+			pr.price_ratio, COALESCE(pr.priced, 0),
+			s.flair, s.confirmed_trades, s.flair_updated_at
+		FROM activity a
+		LEFT JOIN sellers s ON LOWER(s.username) = a.key
+		LEFT JOIN pricing pr ON pr.key = a.key
+		LEFT JOIN selling sl ON sl.key = a.key
+		ORDER BY a.username
+	`
+	rows, err := r.dbpool.Query(ctx, query, SellerMarketWindow.Seconds(), usernames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sellers: %w", err)
+	}
+	sellers, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Seller, error) {
+		var s models.Seller
+		var sellSecs *float64
+		err := row.Scan(&s.Username, &s.Posts, &s.FirstSeen, &s.LastSeen,
+			&s.Listings, &s.Sold, &sellSecs,
+			&s.PriceRatio, &s.PricedListings,
+			&s.Flair, &s.ConfirmedTrades, &s.FlairUpdatedAt)
+		if sellSecs != nil {
+			hours := *sellSecs / 3600
+			s.MedianHoursToSell = &hours
+		}
+		return s, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan sellers: %w", err)
+	}
+	return sellers, nil
+}
+
+// AttachSellers sets Seller on each listing with one query for all of them
+func (r *Repository) AttachSellers(ctx context.Context, listings ...*models.Listing) error {
+	var usernames []string
+	for _, l := range listings {
+		if l.SellerUsername != "" {
+			usernames = append(usernames, l.SellerUsername)
+		}
+	}
+	sellers, err := r.Sellers(ctx, usernames)
+	if err != nil {
+		return err
+	}
+	byName := map[string]*models.Seller{}
+	for i := range sellers {
+		byName[strings.ToLower(sellers[i].Username)] = &sellers[i]
+	}
+	for _, l := range listings {
+		l.Seller = byName[strings.ToLower(l.SellerUsername)]
+	}
+	return nil
+}
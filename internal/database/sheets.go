@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"frag-aggra/internal/models"
+	"frag-aggra/internal/normalize"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const sheetLinkSelect = `
+	SELECT s.id, p.reddit_id, s.url, s.kind, s.status, s.offers, s.error, s.fetched_at, s.created_at
+	FROM sheet_links s
+	JOIN posts p ON p.id = s.post_id`
+
+// AddSheetLinks records the price list links found in a stored post, links
+// it already has are left as they are
+func (r *Repository) AddSheetLinks(ctx context.Context, redditID string, links []models.SheetLink) error {
+	if r.dbpool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+	batch := &pgx.Batch{}
+	for _, link := range links {
+		status := link.Status
+		if status == "" {
+			status = models.SheetPending
+		}
+		batch.Queue(`
+			INSERT INTO sheet_links (post_id, url, kind, status)
+			SELECT id, $2, $3, $4 FROM posts WHERE reddit_id = $1
+			ON CONFLICT (post_id, url) DO NOTHING
+		`, redditID, link.URL, link.Kind, status)
+	}
+	if err := r.dbpool.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to add sheet links: %w", err)
+	}
+	return nil
+}
+
+// DueSheetLinks returns links that haven't been read yet, and failed ones
+// with attempts left, oldest first
+func (r *Repository) DueSheetLinks(ctx context.Context, maxAttempts, limit int) ([]models.SheetLink, error) {
+	return r.querySheetLinks(ctx, sheetLinkSelect+`
+		WHERE s.status = $1 OR (s.status = $2 AND s.attempts < $3)
+		ORDER BY s.created_at, s.id
+		LIMIT $4
+	`, models.SheetPending, models.SheetFailed, maxAttempts, clampLimit(limit))
+}
+
+// PostSheetLinks returns every link recorded for a post
+func (r *Repository) PostSheetLinks(ctx context.Context, redditID string) ([]models.SheetLink, error) {
+	return r.querySheetLinks(ctx, sheetLinkSelect+` WHERE p.reddit_id = $1 ORDER BY s.id`, redditID)
+}
+
+func (r *Repository) querySheetLinks(ctx context.Context, query string, args ...any) ([]models.SheetLink, error) {
+	if r.dbpool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+	rows, err := r.dbpool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sheet links: %w", err)
+	}
+	links, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.SheetLink, error) {
+		var l models.SheetLink
+		err := row.Scan(&l.ID, &l.RedditID, &l.URL, &l.Kind, &l.Status, &l.Offers, &l.Error, &l.FetchedAt, &l.CreatedAt)
+		return l, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan sheet links: %w", err)
+	}
+	return links, nil
+}
+
+// MarkSheetLink records the outcome of fetching a link
+func (r *Repository) MarkSheetLink(ctx context.Context, id int64, status string, offers int, fetchErr error) error {
+	if r.dbpool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+	var errMsg string
+	if fetchErr != nil {
+		errMsg = fetchErr.Error()
+	}
+	_, err := r.dbpool.Exec(ctx, `
+		UPDATE sheet_links SET status = $2, offers = $3, error = $4, attempts = attempts + 1, fetched_at = NOW()
+		WHERE id = $1
+	`, id, status, offers, nullString(errMsg))
+	if err != nil {
+		return fmt.Errorf("failed to mark sheet link: %w", err)
+	}
+	return nil
+}
+
+// ApplySheetOffers fills in a post's listings from its price list in one
+// transaction: updates are offers for existing rows (the "See Spreadsheet"
+// ones) by listing id, added are items the post's text didn't mention
+func (r *Repository) ApplySheetOffers(ctx context.Context, redditID string, updates map[int64]models.Offer, added models.FragranceListing) error {
+	if r.dbpool == nil {
+		return fmt.Errorf("database pool is not initialized")
+	}
+	tx, err := r.dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var postID int64
+	if err := tx.QueryRow(ctx, `SELECT id FROM posts WHERE reddit_id = $1`, redditID).Scan(&postID); err != nil {
+		return fmt.Errorf("failed to find post %s: %w", redditID, err)
+	}
+
+	batch := &pgx.Batch{}
+	for id, offer := range updates {
+		var size string
+		err := tx.QueryRow(ctx, `SELECT COALESCE(size, '') FROM listings WHERE id = $1 AND post_id = $2`, id, postID).Scan(&size)
+		if err != nil {
+			return fmt.Errorf("failed to load listing %d: %w", id, err)
+		}
+		// the sheet usually knows the size better than "see spreadsheet" did
+		if offer.Size != "" {
+			size = offer.Size
+		}
+		n := normalize.Normalize(size, offer.Price)
+		batch.Queue(`
+			UPDATE listings SET size = $3, price = $4, price_cents = $5, currency = $6,
+				remaining_ml = $7, capacity_ml = $8, bottle_kind = $9, price_per_ml_cents = $10, normalize_error = $11,
+				condition = COALESCE($12, condition), box = COALESCE($13, box), batch_code = COALESCE($14, batch_code),
+				fill_percent = COALESCE($15, fill_percent), quantity = $16, notes = COALESCE($17, notes),
+				extracted_by = $18
+			WHERE id = $1 AND post_id = $2
+		`, id, postID, size, offer.Price, n.PriceCents, nullString(n.Currency),
+			n.RemainingML, n.CapacityML, nullString(string(n.Kind)), n.PricePerMLCents, nullString(n.Error),
+			known(offer.Condition), known(offer.Box), nullString(offer.BatchCode),
+			fillPercent(offer, n), quantity(offer), nullString(offer.Notes), models.ExtractedBySheet)
+	}
+	if batch.Len() > 0 {
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return fmt.Errorf("failed to update listings: %w", err)
+		}
+	}
+
+	if rows := listingRows(postID, added); len(rows) > 0 {
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"listings"}, listingColumns, pgx.CopyFromRows(rows))
+		if err != nil {
+			return fmt.Errorf("failed to copy sheet listings: %w", err)
+		}
+	}
+	return tx.Commit(ctx)
+}
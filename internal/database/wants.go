@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"frag-aggra/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// every column wantDest scans, in order
+const wantFields = `
+	w.id, w.name, w.size, w.capacity_ml,
+	w.max_price, w.max_price_cents, w.currency, w.fragrance_id, w.created_at
+`
+
+const wantSelect = `SELECT ` + wantFields + ` FROM wants w`
+
+// WantMatches returns available sale and trade listings from other sellers
+// that satisfy the wants of a post, cheapest per ml first. a want only matches
+// once its name resolved to a catalog entry. sizes match on bottle capacity
+// and a budget only rules out listings priced in the same currency above it.
+// limit applies to each want.
+func (r *Repository) WantMatches(ctx context.Context, redditID string, limit int) ([]models.WantMatch, error) {
+	if r.dbpool == nil {
+		return nil, fmt.Errorf("database pool is not initialized")
+	}
+
+	var postID int64
+	var seller *string
+	err := r.dbpool.QueryRow(ctx, `SELECT id, seller_username FROM posts WHERE reddit_id = $1`, redditID).Scan(&postID, &seller)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post: %w", err)
+	}
+
+	// ranked per want so one popular want can't use up the limit for the rest
+	query := `
+		WITH ranked AS (
+			SELECT w.id AS want_id, l.id AS listing_id,
+				ROW_NUMBER() OVER (PARTITION BY w.id
+					ORDER BY l.price_per_ml_cents ASC NULLS LAST, l.id DESC) AS rank
+			FROM wants w
+			JOIN listings l ON l.fragrance_id = w.fragrance_id
+			JOIN posts p ON p.id = l.post_id
+			WHERE w.post_id = $1
+				AND l.post_id <> w.post_id
+				AND l.status = 'available'
+				AND l.intent IN ('sell', 'trade')
+				AND ($2::text IS NULL OR p.seller_username IS DISTINCT FROM $2)
+				AND (w.capacity_ml IS NULL OR l.capacity_ml = w.capacity_ml)
+				AND (w.max_price_cents IS NULL OR l.currency IS DISTINCT FROM w.currency
+					OR l.price_cents <= w.max_price_cents)
+		)
+		SELECT ` + wantFields + `, ` + listingFields + `
+		FROM ranked r
+		JOIN wants w ON w.id = r.want_id
+		JOIN listings l ON l.id = r.listing_id
+		JOIN posts p ON p.id = l.post_id
+		WHERE r.rank <= $3
+		ORDER BY w.id, r.rank
+	`
+	rows, err := r.dbpool.Query(ctx, query, postID, seller, clampLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query want matches: %w", err)
+	}
+	matches, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.WantMatch, error) {
+		var m models.WantMatch
+		err := row.Scan(append(wantDest(&m.Want), listingDest(&m.Listing)...)...)
+		return m, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan want matches: %w", err)
+	}
+	return matches, nil
+}
+
+// scan destinations matching wantFields
+func wantDest(w *models.StoredWant) []any {
+	return []any{
+		&w.ID, &w.Name, &w.Size, &w.CapacityML,
+		&w.MaxPrice, &w.MaxPriceCents, &w.Currency, &w.FragranceID, &w.CreatedAt,
+	}
+}
@@ -0,0 +1,52 @@
+package evaluate
+
+import (
+	"encoding/json"
+	"fmt"
+	"frag-aggra/internal/models"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Fixture is a post and the listing a correct parse of it produces. Stored
+// optionally pins down the normalized rows that parse should be saved as.
+type Fixture struct {
+	Name     string                  `json:"-"` // file name without .json
+	Post     models.Post             `json:"post"`
+	Expected models.FragranceListing `json:"expected"`
+	Stored   []models.Listing        `json:"stored,omitempty"`
+}
+
+// Input is the text the worker would send the parser for this post
+func (f Fixture) Input() string {
+	return f.Post.Title + "\n" + f.Post.Body
+}
+
+// LoadFixtures reads every *.json fixture in dir, sorted by name
+func LoadFixtures(dir string) ([]Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no fixtures in %s", dir)
+	}
+
+	fixtures := make([]Fixture, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture %s: %w", path, err)
+		}
+		var f Fixture
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("failed to decode fixture %s: %w", path, err)
+		}
+		f.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+		fixtures = append(fixtures, f)
+	}
+	return fixtures, nil
+}
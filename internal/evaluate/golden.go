@@ -0,0 +1,149 @@
+package evaluate
+
+import (
+	"encoding/json"
+	"fmt"
+	"frag-aggra/internal/models"
+	"frag-aggra/internal/normalize"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Golden is what parsing a fixture produced the last time someone looked at it
+// and accepted it, mistakes of the model included. the labels in the fixture
+// say what a perfect parse is, the golden file pins down what the recorded
+// responses actually give so a change in how they're decoded, validated or
+// repaired shows up.
+type Golden struct {
+	Listing *models.FragranceListing `json:"listing"`
+	Error   string                   `json:"error,omitempty"`
+}
+
+// NewGolden is the golden file for a parse result
+func NewGolden(listing *models.FragranceListing, err error) Golden {
+	g := Golden{Listing: listing}
+	if err != nil {
+		g.Error = err.Error()
+	}
+	return g
+}
+
+// LoadGolden reads dir/<fixture name>.json
+func LoadGolden(dir, name string) (Golden, error) {
+	var g Golden
+	data, err := os.ReadFile(filepath.Join(dir, name+".json"))
+	if err != nil {
+		return g, fmt.Errorf("failed to read golden file for %s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, &g); err != nil {
+		return g, fmt.Errorf("failed to decode golden file for %s: %w", name, err)
+	}
+	return g, nil
+}
+
+// SaveGolden writes dir/<fixture name>.json, creating dir if needed
+func SaveGolden(dir, name string, g Golden) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create golden dir: %w", err)
+	}
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name+".json"), append(data, '\n'), 0o644)
+}
+
+// Diff lists how got differs from the golden file, the first differing line
+// of the listing's json is enough to go on
+func (g Golden) Diff(got Golden) []string {
+	var diffs []string
+	if g.Error != got.Error {
+		diffs = append(diffs, fmt.Sprintf("error: expected %q, got %q", g.Error, got.Error))
+	}
+	want, _ := json.MarshalIndent(g.Listing, "", "  ")
+	have, _ := json.MarshalIndent(got.Listing, "", "  ")
+	wantLines, haveLines := strings.Split(string(want), "\n"), strings.Split(string(have), "\n")
+	for i := range max(len(wantLines), len(haveLines)) {
+		var w, h string
+		if i < len(wantLines) {
+			w = strings.TrimSpace(wantLines[i])
+		}
+		if i < len(haveLines) {
+			h = strings.TrimSpace(haveLines[i])
+		}
+		if w != h {
+			diffs = append(diffs, fmt.Sprintf("listing line %d: expected %s, got %s", i+1, w, h))
+			break
+		}
+	}
+	return diffs
+}
+
+// NormalizedRows is the listing rows InsertItem would store for a parse,
+// without the database
+func NormalizedRows(listing *models.FragranceListing) []models.Listing {
+	var rows []models.Listing
+	if listing == nil {
+		return rows
+	}
+	for _, p := range listing.Perfumes {
+		for _, o := range p.Offers {
+			n := normalize.Normalize(o.Size, o.Price)
+			row := models.Listing{
+				Name: p.Name, Size: o.Size, Price: o.Price,
+				PriceCents: n.PriceCents, RemainingML: n.RemainingML, CapacityML: n.CapacityML,
+				PricePerMLCents: n.PricePerMLCents,
+			}
+			if n.Currency != "" {
+				row.Currency = &n.Currency
+			}
+			if n.Kind != "" {
+				kind := string(n.Kind)
+				row.BottleKind = &kind
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// CompareRows lists every difference between the expected stored rows and
+// what was produced, in order. only the parsed and normalized columns are
+// compared, ids and timestamps never match.
+func CompareRows(expected, got []models.Listing) []string {
+	var diffs []string
+	if len(expected) != len(got) {
+		diffs = append(diffs, fmt.Sprintf("expected %d rows, got %d", len(expected), len(got)))
+	}
+	for i := range min(len(expected), len(got)) {
+		e, g := expected[i], got[i]
+		check := func(field, want, have string) {
+			if want != have {
+				diffs = append(diffs, fmt.Sprintf("row %d (%s) %s: expected %s, got %s", i, e.Name, field, want, have))
+			}
+		}
+		check("name", e.Name, g.Name)
+		check("size", e.Size, g.Size)
+		check("price", e.Price, g.Price)
+		check("price_cents", show(e.PriceCents), show(g.PriceCents))
+		check("currency", show(e.Currency), show(g.Currency))
+		check("remaining_ml", show(e.RemainingML), show(g.RemainingML))
+		check("capacity_ml", show(e.CapacityML), show(g.CapacityML))
+		check("bottle_kind", show(e.BottleKind), show(g.BottleKind))
+		check("price_per_ml_cents", show(e.PricePerMLCents), show(g.PricePerMLCents))
+	}
+	return diffs
+}
+
+// floats to the 2 places the numeric columns keep, so a stored row compares
+// equal to the one normalize produced
+func show[T any](v *T) string {
+	if v == nil {
+		return "null"
+	}
+	if f, ok := any(*v).(float64); ok {
+		return fmt.Sprintf("%.2f", f)
+	}
+	return fmt.Sprint(*v)
+}
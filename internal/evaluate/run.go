@@ -0,0 +1,57 @@
+package evaluate
+
+import (
+	"context"
+	"frag-aggra/internal/models"
+	"frag-aggra/internal/parser"
+	"time"
+)
+
+// Result is how one parse of a fixture went
+type Result struct {
+	Fixture string                   `json:"fixture"`
+	Score   Score                    `json:"score"`
+	Got     *models.FragranceListing `json:"got,omitempty"`
+	Err     string                   `json:"error,omitempty"`
+	Latency time.Duration            `json:"latency"`
+}
+
+// Report is a whole corpus run of one extractor
+type Report struct {
+	Label            string        `json:"label"`
+	Score            Score         `json:"score"`
+	Errors           int           `json:"errors"`
+	PromptTokens     int64         `json:"prompt_tokens"`
+	CompletionTokens int64         `json:"completion_tokens"`
+	CostUSD          float64       `json:"cost_usd"`
+	Latency          time.Duration `json:"latency"`
+	Results          []Result      `json:"results"`
+}
+
+// Run parses every fixture with e and scores it. a failed parse scores as
+// predicting nothing, so it costs recall but not precision.
+func Run(ctx context.Context, label string, e parser.ListingExtractor, pricing parser.Pricing, fixtures []Fixture) Report {
+	report := Report{Label: label}
+	for _, f := range fixtures {
+		started := time.Now()
+		got, err := e.ParsePostContent(ctx, f.Input())
+		r := Result{Fixture: f.Name, Got: got, Latency: time.Since(started)}
+		if err != nil {
+			r.Err = err.Error()
+			report.Errors++
+		}
+		r.Score = Compare(&f.Expected, got)
+
+		report.Score.Add(r.Score)
+		report.Latency += r.Latency
+		if got != nil && got.Usage != nil {
+			report.PromptTokens += got.Usage.PromptTokens
+			report.CompletionTokens += got.Usage.CompletionTokens
+			if cost := pricing.Cost(got.Usage); cost != nil {
+				report.CostUSD += *cost
+			}
+		}
+		report.Results = append(report.Results, r)
+	}
+	return report
+}
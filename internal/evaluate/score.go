@@ -0,0 +1,131 @@
+package evaluate
+
+import (
+	"fmt"
+	"frag-aggra/internal/models"
+	"frag-aggra/internal/normalize"
+	"regexp"
+	"strings"
+)
+
+var nonWordRe = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// Counts is true positives against what was predicted and expected
+type Counts struct {
+	Matched   int `json:"matched"`
+	Predicted int `json:"predicted"`
+	Expected  int `json:"expected"`
+}
+
+// Precision is 1 when nothing was predicted, there was nothing to get wrong
+func (c Counts) Precision() float64 {
+	if c.Predicted == 0 {
+		return 1
+	}
+	return float64(c.Matched) / float64(c.Predicted)
+}
+
+// Recall is 1 when nothing was expected
+func (c Counts) Recall() float64 {
+	if c.Expected == 0 {
+		return 1
+	}
+	return float64(c.Matched) / float64(c.Expected)
+}
+
+func (c Counts) String() string {
+	return fmt.Sprintf("%.2f/%.2f", c.Precision(), c.Recall())
+}
+
+func (c *Counts) add(o Counts) {
+	c.Matched += o.Matched
+	c.Predicted += o.Predicted
+	c.Expected += o.Expected
+}
+
+// Score is field level counts for one parse, or summed over many. a size only
+// counts when its perfume's name matched too, and a price when its size did.
+type Score struct {
+	Names  Counts `json:"names"`
+	Sizes  Counts `json:"sizes"`
+	Prices Counts `json:"prices"`
+}
+
+func (s *Score) Add(o Score) {
+	s.Names.add(o.Names)
+	s.Sizes.add(o.Sizes)
+	s.Prices.add(o.Prices)
+}
+
+// Perfect is true when every field matched exactly
+func (s Score) Perfect() bool {
+	for _, c := range []Counts{s.Names, s.Sizes, s.Prices} {
+		if c.Matched != c.Predicted || c.Matched != c.Expected {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare scores a parse against the expected listing. names, sizes and
+// prices are normalized first so "80/100 ml" matches "80/100ml" and "$150.00"
+// matches "$150".
+func Compare(expected, got *models.FragranceListing) Score {
+	if got == nil {
+		got = &models.FragranceListing{}
+	}
+	names, sizes, prices := keys(expected)
+	gotNames, gotSizes, gotPrices := keys(got)
+	return Score{
+		Names:  count(names, gotNames),
+		Sizes:  count(sizes, gotSizes),
+		Prices: count(prices, gotPrices),
+	}
+}
+
+// multisets of name, name+size and name+size+price keys
+func keys(listing *models.FragranceListing) (names, sizes, prices map[string]int) {
+	names, sizes, prices = map[string]int{}, map[string]int{}, map[string]int{}
+	for _, p := range listing.Perfumes {
+		name := nameKey(p.Name)
+		names[name]++
+		for _, o := range p.Offers {
+			sk := name + "|" + sizeKey(o.Size)
+			sizes[sk]++
+			prices[sk+"|"+priceKey(o.Price)]++
+		}
+	}
+	return names, sizes, prices
+}
+
+func count(expected, got map[string]int) Counts {
+	var c Counts
+	for k, n := range expected {
+		c.Expected += n
+		c.Matched += min(n, got[k])
+	}
+	for _, n := range got {
+		c.Predicted += n
+	}
+	return c
+}
+
+func nameKey(name string) string {
+	return strings.TrimSpace(nonWordRe.ReplaceAllString(strings.ToLower(name), " "))
+}
+
+func sizeKey(size string) string {
+	remaining, capacity, err := normalize.ParseSize(size)
+	if err != nil {
+		return strings.ToLower(strings.Join(strings.Fields(size), ""))
+	}
+	return fmt.Sprintf("%g/%g", remaining, capacity)
+}
+
+func priceKey(price string) string {
+	cents, currency, err := normalize.ParsePrice(price)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(price))
+	}
+	return fmt.Sprintf("%d %s", cents, currency)
+}
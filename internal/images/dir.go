@@ -0,0 +1,60 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DirFetcher reads pictures from a directory instead of the network, named
+// like FileName and AlbumFileName, for fixtures and offline runs
+type DirFetcher struct {
+	Dir string
+}
+
+func (f DirFetcher) Fetch(ctx context.Context, link string) ([]Image, error) {
+	if id, ok := imgurAlbum(link); ok {
+		data, err := os.ReadFile(filepath.Join(f.Dir, AlbumFileName(id)))
+		if err != nil {
+			return nil, err
+		}
+		var album struct {
+			Data []struct {
+				Link string `json:"link"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(data, &album); err != nil {
+			return nil, fmt.Errorf("failed to decode imgur album %s: %w", id, err)
+		}
+		var out []Image
+		for _, item := range album.Data {
+			img, err := f.image(item.Link)
+			if err != nil {
+				return out, err
+			}
+			out = append(out, img)
+		}
+		return out, nil
+	}
+	img, err := f.image(link)
+	if err != nil {
+		return nil, err
+	}
+	return []Image{img}, nil
+}
+
+func (f DirFetcher) image(link string) (Image, error) {
+	data, err := os.ReadFile(filepath.Join(f.Dir, FileName(link)))
+	if err != nil {
+		return Image{}, err
+	}
+	mediaType := http.DetectContentType(data)
+	if !strings.HasPrefix(mediaType, "image/") {
+		return Image{}, fmt.Errorf("%s: %w (%s)", link, ErrNotImage, mediaType)
+	}
+	return Image{URL: link, MediaType: mediaType, Data: data}, nil
+}
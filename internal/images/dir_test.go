@@ -0,0 +1,45 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+const imagesDir = "../../testdata/images"
+
+func TestDirFetcher(t *testing.T) {
+	f := DirFetcher{Dir: imagesDir}
+	ctx := context.Background()
+
+	imgs, err := f.Fetch(ctx, "https://preview.redd.it/q8v2x1ab3c.png?width=640")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imgs) != 1 || imgs[0].MediaType != "image/png" || imgs[0].URL != "https://preview.redd.it/q8v2x1ab3c.png?width=640" {
+		t.Errorf("picture = %+v, want one png under its link", imgs)
+	}
+
+	// an album is every picture in it, in order
+	imgs, err = f.Fetch(ctx, "https://imgur.com/a/Hk3Pq9s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imgs) != 2 || imgs[0].URL != "https://i.imgur.com/Xq7LmPa.jpg" || imgs[1].URL != "https://i.imgur.com/Rt5KwZb.jpg" {
+		t.Fatalf("album = %+v, want its two pictures in order", imgs)
+	}
+	for _, img := range imgs {
+		if img.MediaType != "image/jpeg" {
+			t.Errorf("%s media type %s, want image/jpeg", img.URL, img.MediaType)
+		}
+	}
+
+	// imgur answers a removed picture with a page
+	if _, err := f.Fetch(ctx, "https://i.imgur.com/gone4Ab.jpg"); !errors.Is(err, ErrNotImage) {
+		t.Errorf("removed picture err = %v, want ErrNotImage", err)
+	}
+	if _, err := f.Fetch(ctx, "https://i.imgur.com/missing.jpg"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing picture err = %v, want not exist", err)
+	}
+}
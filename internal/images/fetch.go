@@ -0,0 +1,193 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MaxImageBytes caps a download, vision apis refuse anything much bigger
+const MaxImageBytes = 8 << 20
+
+// ErrNotImage is returned when a link answers with something other than a picture
+var ErrNotImage = errors.New("not an image")
+
+// imgur.com/a/<id> and imgur.com/gallery/<id>, optionally with a slug in front of the id
+var imgurAlbumRe = regexp.MustCompile(`^/(?:a|gallery)/(?:[^/]*-)?([A-Za-z0-9]+)/?$`)
+
+// Image is a downloaded picture from a post
+type Image struct {
+	URL       string
+	MediaType string // image/jpeg, image/png, ...
+	Data      []byte
+}
+
+// Fetcher downloads the pictures behind a link, an album link gives several
+type Fetcher interface {
+	Fetch(ctx context.Context, link string) ([]Image, error)
+}
+
+// HTTPFetcher downloads images over http. imgur albums are expanded through
+// the imgur api and need ImgurClientID. with BaseURL set it asks
+// BaseURL/FileName(link) instead, so a static file server over a directory of
+// pictures can stand in for reddit and imgur.
+type HTTPFetcher struct {
+	Client        *http.Client
+	BaseURL       string
+	ImgurClientID string
+}
+
+// FetcherFromEnv reads IMAGES_BASE_URL and IMGUR_CLIENT_ID
+func FetcherFromEnv() *HTTPFetcher {
+	return NewHTTPFetcher(os.Getenv("IMAGES_BASE_URL"), os.Getenv("IMGUR_CLIENT_ID"))
+}
+
+func NewHTTPFetcher(baseURL, imgurClientID string) *HTTPFetcher {
+	return &HTTPFetcher{
+		Client:        &http.Client{Timeout: 30 * time.Second},
+		BaseURL:       strings.TrimRight(baseURL, "/"),
+		ImgurClientID: imgurClientID,
+	}
+}
+
+func (f *HTTPFetcher) Fetch(ctx context.Context, link string) ([]Image, error) {
+	if id, ok := imgurAlbum(link); ok {
+		return f.album(ctx, id)
+	}
+	img, err := f.image(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	return []Image{img}, nil
+}
+
+func (f *HTTPFetcher) image(ctx context.Context, link string) (Image, error) {
+	target := link
+	if f.BaseURL != "" {
+		target = f.BaseURL + "/" + FileName(link)
+	}
+	resp, err := f.get(ctx, target, nil)
+	if err != nil {
+		return Image{}, err
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mediaType, "image/") {
+		return Image{}, fmt.Errorf("%s: %w (%s)", target, ErrNotImage, mediaType)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxImageBytes+1))
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to read %s: %w", target, err)
+	}
+	if len(data) > MaxImageBytes {
+		return Image{}, fmt.Errorf("%s is over %d bytes", target, MaxImageBytes)
+	}
+	return Image{URL: link, MediaType: mediaType, Data: data}, nil
+}
+
+// the pictures of an imgur album, in album order
+func (f *HTTPFetcher) album(ctx context.Context, id string) ([]Image, error) {
+	target := "https://api.imgur.com/3/album/" + id + "/images"
+	header := http.Header{}
+	switch {
+	case f.BaseURL != "":
+		target = f.BaseURL + "/" + AlbumFileName(id)
+	case f.ImgurClientID == "":
+		return nil, fmt.Errorf("imgur album %s: IMGUR_CLIENT_ID is not set", id)
+	default:
+		header.Set("Authorization", "Client-ID "+f.ImgurClientID)
+	}
+	resp, err := f.get(ctx, target, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var album struct {
+		Data []struct {
+			Link string `json:"link"`
+			Type string `json:"type"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, MaxImageBytes)).Decode(&album); err != nil {
+		return nil, fmt.Errorf("failed to decode imgur album %s: %w", id, err)
+	}
+	var out []Image
+	for _, item := range album.Data {
+		if !strings.HasPrefix(item.Type, "image/") || item.Type == "image/gif" {
+			continue
+		}
+		img, err := f.image(ctx, item.Link)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, img)
+	}
+	return out, nil
+}
+
+func (f *HTTPFetcher) get(ctx context.Context, target string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", target, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch %s: status %d", target, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// FileName names a picture when it's served from a directory instead, the
+// last part of its path ("abc123.jpg")
+func FileName(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return path.Base(u.Path)
+}
+
+// AlbumFileName names an imgur album's api response when it's served from a
+// directory instead, "imgur-album-<id>.json"
+func AlbumFileName(id string) string {
+	return "imgur-album-" + id + ".json"
+}
+
+func imgurAlbum(link string) (string, bool) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if host != "imgur.com" && host != "m.imgur.com" {
+		return "", false
+	}
+	m := imgurAlbumRe.FindStringSubmatch(u.Path)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
@@ -0,0 +1,59 @@
+package images
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// bare and markdown links, reddit escapes underscores in raw_json=0 bodies
+	urlRe = regexp.MustCompile(`https?://[^\s)\]>"'<]+`)
+
+	imageExtRe = regexp.MustCompile(`(?i)\.(?:png|jpe?g|webp)$`)
+	// imgur.com/<id>, a single picture's page
+	imgurPageRe = regexp.MustCompile(`^/([A-Za-z0-9]{5,8})$`)
+)
+
+// hosts sellers post pictures of their collection to
+var imageHosts = map[string]bool{
+	"i.imgur.com": true, "i.redd.it": true, "preview.redd.it": true,
+}
+
+// FindLinks returns the picture and imgur album links in a post body, each
+// once. gifs are left out, the vision apis don't read them reliably.
+func FindLinks(body string) []string {
+	var links []string
+	seen := map[string]bool{}
+	for _, raw := range urlRe.FindAllString(body, -1) {
+		raw = strings.TrimRight(strings.ReplaceAll(raw, `\_`, "_"), ".,;:!?")
+		link, ok := imageLink(raw)
+		if !ok || seen[link] {
+			continue
+		}
+		seen[link] = true
+		links = append(links, link)
+	}
+	return links
+}
+
+func imageLink(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	switch {
+	case imageHosts[host] && imageExtRe.MatchString(u.Path):
+		return raw, true
+	case host == "imgur.com" || host == "m.imgur.com":
+		if _, ok := imgurAlbum(raw); ok {
+			return raw, true
+		}
+		// i.imgur.com serves any picture as .jpg
+		if m := imgurPageRe.FindStringSubmatch(u.Path); m != nil {
+			return "https://i.imgur.com/" + m[1] + ".jpg", true
+		}
+	}
+	return "", false
+}
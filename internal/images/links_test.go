@@ -0,0 +1,31 @@
+package images
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"direct pictures", "collection: https://i.imgur.com/Xq7LmPa.jpg and https://i.redd.it/q8v2x1ab3c.png.",
+			[]string{"https://i.imgur.com/Xq7LmPa.jpg", "https://i.redd.it/q8v2x1ab3c.png"}},
+		{"markdown link", "[pics](https://preview.redd.it/q8v2x1ab3c.png?width=640&format=png)",
+			[]string{"https://preview.redd.it/q8v2x1ab3c.png?width=640&format=png"}},
+		{"escaped underscore", `https://i.imgur.com/ab\_cd12.jpeg`, []string{"https://i.imgur.com/ab_cd12.jpeg"}},
+		{"album kept as is", "https://imgur.com/a/Hk3Pq9s", []string{"https://imgur.com/a/Hk3Pq9s"}},
+		{"named gallery", "https://imgur.com/gallery/my-bottles-Hk3Pq9s", []string{"https://imgur.com/gallery/my-bottles-Hk3Pq9s"}},
+		{"picture page", "https://www.imgur.com/Rt5KwZb", []string{"https://i.imgur.com/Rt5KwZb.jpg"}},
+		{"each once", "https://i.imgur.com/Xq7LmPa.jpg https://i.imgur.com/Xq7LmPa.jpg", []string{"https://i.imgur.com/Xq7LmPa.jpg"}},
+		{"gifs left out", "https://i.imgur.com/Xq7LmPa.gif", nil},
+		{"other hosts left out", "https://example.com/bottle.jpg https://www.fragrantica.com/perfume/x.html", nil},
+	}
+	for _, tt := range tests {
+		if got := FindLinks(tt.body); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: FindLinks = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
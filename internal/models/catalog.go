@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Fragrance is one canonical catalog entry, a brand's line in one concentration
+type Fragrance struct {
+	ID            int64    `json:"id"`
+	Brand         string   `json:"brand"`
+	BrandAliases  []string `json:"brand_aliases"`
+	Line          string   `json:"line"`
+	Concentration string   `json:"concentration,omitempty"`
+	Aliases       []string `json:"aliases"`
+}
+
+// FullName is how the fragrance should be displayed, e.g. "Tom Ford Oud Wood EDP"
+func (f Fragrance) FullName() string {
+	name := f.Brand + " " + f.Line
+	if f.Concentration != "" {
+		name += " " + f.Concentration
+	}
+	return name
+}
+
+// FragranceReview is an extracted name the resolver couldn't place confidently
+type FragranceReview struct {
+	ID                   int64     `json:"id"`
+	RawName              string    `json:"raw_name"`
+	SuggestedFragranceID *int64    `json:"suggested_fragrance_id,omitempty"`
+	SuggestedScore       *float64  `json:"suggested_score,omitempty"`
+	SeenCount            int       `json:"seen_count"`
+	Status               string    `json:"status"`
+	CreatedAt            time.Time `json:"created_at"`
+}
+
+// review statuses
+const (
+	ReviewPending  = "pending"
+	ReviewResolved = "resolved"
+	ReviewIgnored  = "ignored"
+)
@@ -1,17 +1,120 @@
 package models
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
 // Perfume represents a single fragrance item for sale.
 type Perfume struct {
-	Name   string   `json:"name" jsonschema_description:"The standardized full brand and perfume name (e.g., 'Tom Ford Tobacco Vanille'). Apply all standardization rules."`
-	Sizes  []string `json:"sizes" jsonschema_description:"An array of available sizes in ml. For partials, use 'X/Yml' format (e.g., '80/100ml')."`
-	Prices []string `json:"prices" jsonschema_description:"An array of prices with '$' symbol, corresponding to each size in the sizes array."`
+	Name   string  `json:"name" jsonschema_description:"The standardized full brand and perfume name (e.g., 'Tom Ford Tobacco Vanille'). Apply all standardization rules."`
+	Offers []Offer `json:"offers" jsonschema_description:"One entry per size this perfume is offered in, each with its own price."`
+
+	// catalog entry the name resolved to, filled in after extraction
+	FragranceID  *int64  `json:"-"`
+	ResolveScore float64 `json:"-"`
+}
+
+// Offer is one size of a perfume and what it goes for
+type Offer struct {
+	Size        string `json:"size" jsonschema_description:"The size in ml. For partials, use 'X/Yml' format (e.g., '80/100ml')."`
+	Price       string `json:"price" jsonschema_description:"The price with '$' symbol (e.g., '$150')."`
+	Condition   string `json:"condition" jsonschema:"enum=new,enum=tester,enum=used,enum=unknown" jsonschema_description:"'new' for unused bottles (BNIB, sealed, new unboxed), 'tester' for testers, 'used' for sprayed or partial bottles, 'unknown' if the post doesn't say."`
+	Box         string `json:"box" jsonschema:"enum=boxed,enum=unboxed,enum=damaged,enum=unknown" jsonschema_description:"'boxed' if it comes with its box (BNIB counts), 'unboxed' if not, 'damaged' if the box is damaged, 'unknown' if the post doesn't say."`
+	BatchCode   string `json:"batch_code" jsonschema_description:"The batch code as written (e.g., 'A42' or '21D01'), or an empty string if none is given."`
+	FillPercent int    `json:"fill_percent" jsonschema_description:"How full the bottle is in percent when the post says (e.g., 80 for '~80%' or '80/100ml'), 0 if it doesn't."`
+	Quantity    int    `json:"quantity" jsonschema_description:"How many of this size are available, 1 unless the post says otherwise."`
+	Notes       string `json:"notes" jsonschema_description:"Anything else said about this offer that isn't part of the price or the fields above (e.g., 'OBO', 'shipped', 'no cap'), or an empty string."`
+}
+
+// values for Offer.Condition
+const (
+	ConditionNew     = "new"
+	ConditionTester  = "tester"
+	ConditionUsed    = "used"
+	ConditionUnknown = "unknown"
+)
+
+// values for Offer.Box
+const (
+	BoxBoxed   = "boxed"
+	BoxUnboxed = "unboxed"
+	BoxDamaged = "damaged"
+	BoxUnknown = "unknown"
+)
+
+// UnmarshalJSON also reads the old shape with parallel "sizes" and "prices"
+// arrays, so listings serialized before offers existed (cached parses,
+// reviews, fixtures, queued messages) still decode. a size without a price,
+// or the other way around, becomes an offer with the missing half empty.
+func (p *Perfume) UnmarshalJSON(data []byte) error {
+	type perfume Perfume
+	var v struct {
+		perfume
+		Sizes  []string `json:"sizes"`
+		Prices []string `json:"prices"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*p = Perfume(v.perfume)
+	if p.Offers == nil && (v.Sizes != nil || v.Prices != nil) {
+		p.Offers = []Offer{}
+		for i := range max(len(v.Sizes), len(v.Prices)) {
+			offer := Offer{Condition: ConditionUnknown, Box: BoxUnknown, Quantity: 1}
+			if i < len(v.Sizes) {
+				offer.Size = v.Sizes[i]
+			}
+			if i < len(v.Prices) {
+				offer.Price = v.Prices[i]
+			}
+			p.Offers = append(p.Offers, offer)
+		}
+	}
+	return nil
+}
+
+// Want is a fragrance the poster is looking for, either in exchange for what
+// they're trading away or to buy outright.
+type Want struct {
+	Name     string   `json:"name" jsonschema_description:"The standardized full brand and perfume name the poster is looking for. Apply all standardization rules."`
+	Sizes    []string `json:"sizes" jsonschema_description:"Sizes the poster would take, in the same format as perfume sizes. Empty if any size is fine."`
+	MaxPrice string   `json:"max_price" jsonschema_description:"The most the poster will pay with '$' symbol (e.g., '$150'), or an empty string if no budget is given."`
+
+	// catalog entry the name resolved to, filled in after extraction
+	FragranceID  *int64  `json:"-"`
+	ResolveScore float64 `json:"-"`
 }
 
 // FragranceListing represents all perfumes found in a single Reddit post.
 type FragranceListing struct {
+	Intent   string    `json:"intent" jsonschema:"enum=sell,enum=trade,enum=buy" jsonschema_description:"'sell' if the items are offered for money, 'trade' if they are only offered in exchange for other fragrances, 'buy' if the poster only wants to buy."`
 	Perfumes []Perfume `json:"perfumes" jsonschema_description:"A list of all perfumes found in the sale listing."`
+	Wants    []Want    `json:"wants" jsonschema_description:"Fragrances the poster wants in exchange or wants to buy (WTT/WTB/ISO lists). Empty if none are mentioned."`
+
+	// which extraction path produced this listing, not part of the llm schema
+	ExtractedBy string `json:"-"`
+
+	// tokens the llm call took and the prompt it was sent, unset when the rules handled it
+	Usage         *ParseUsage `json:"-"`
+	PromptVersion string      `json:"-"`
 }
 
+// values for FragranceListing.ExtractedBy
+const (
+	ExtractedByRules = "rules"
+	ExtractedByLLM   = "llm"
+	ExtractedBySheet = "sheet" // read from a price list the post links to
+)
+
+// values for FragranceListing.Intent
+const (
+	IntentSell  = "sell"
+	IntentTrade = "trade"
+	IntentBuy   = "buy"
+)
+
 // post raw data to pass into parser
 
 type Post struct {
@@ -20,4 +123,19 @@ type Post struct {
 	Title          string `json:"title"`
 	Body           string `json:"body"` // The raw text to be sent to the LLM
 	SellerUsername string `json:"seller_username"`
+	SellerFlair    string `json:"seller_flair,omitempty"`  // the seller's user flair in the subreddit, when reddit sent it
+	SellerTrades   *int   `json:"seller_trades,omitempty"` // confirmed trade count from the flair
+	Subreddit      string `json:"subreddit,omitempty"`
+	Source         string `json:"source,omitempty"`     // name of the configured source that found it
+	TradeType      string `json:"trade_type,omitempty"` // sell, trade or buy
+
+	// pictures in the post (gallery, image post, links in the body), read by vision models
+	Images []string `json:"images,omitempty"`
+}
+
+// ContentHash is the sha256 of the title and body the parser sees, used to
+// tell whether a seller edited the post since we last parsed it
+func (p Post) ContentHash() string {
+	sum := sha256.Sum256([]byte(p.Title + "\n" + p.Body))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// Listing is a single stored size/price row joined with the post it came from
+type Listing struct {
+	ID              int64     `json:"id"`
+	RedditID        string    `json:"reddit_id"`
+	PostURL         string    `json:"post_url"`
+	SellerUsername  string    `json:"seller_username"`
+	Name            string    `json:"name"`
+	Size            string    `json:"size,omitempty"`
+	Price           string    `json:"price"`
+	PriceCents      *int64    `json:"price_cents,omitempty"`
+	Currency        *string   `json:"currency,omitempty"`
+	RemainingML     *float64  `json:"remaining_ml,omitempty"`
+	CapacityML      *float64  `json:"capacity_ml,omitempty"`
+	BottleKind      *string   `json:"bottle_kind,omitempty"`
+	PricePerMLCents *float64  `json:"price_per_ml_cents,omitempty"`
+	ExtractedBy     *string   `json:"extracted_by,omitempty"`
+	FragranceID     *int64    `json:"fragrance_id,omitempty"`
+	Intent          string    `json:"intent"`
+	Condition       *string   `json:"condition,omitempty"`
+	Box             *string   `json:"box,omitempty"`
+	BatchCode       *string   `json:"batch_code,omitempty"`
+	FillPercent     *int      `json:"fill_percent,omitempty"`
+	Quantity        int       `json:"quantity"`
+	Notes           *string   `json:"notes,omitempty"`
+	Status          string    `json:"status"`
+	StatusChangedAt time.Time `json:"status_changed_at"`
+	CreatedAt       time.Time `json:"created_at"`
+
+	// the seller's history, only filled in where it's shown
+	Seller *Seller `json:"seller,omitempty"`
+}
+
+// listing statuses, a listing starts available and moves to sold or removed
+// when a re-check of its post no longer finds it
+const (
+	ListingAvailable = "available"
+	ListingSold      = "sold"
+	ListingRemoved   = "removed"
+)
+
+// StoredPost is a post row along with every listing parsed out of it
+type StoredPost struct {
+	ID             int64        `json:"id"`
+	RedditID       string       `json:"reddit_id"`
+	URL            string       `json:"url"`
+	SellerUsername string       `json:"seller_username"`
+	Subreddit      *string      `json:"subreddit"`
+	Source         *string      `json:"source"`
+	TradeType      *string      `json:"trade_type"`
+	Seller         *Seller      `json:"seller,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+	Listings       []Listing    `json:"listings"`
+	Wants          []StoredWant `json:"wants"`
+}
+
+// StoredWant is a want row, one per size when the poster named sizes
+type StoredWant struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	Size          *string   `json:"size,omitempty"`
+	CapacityML    *float64  `json:"capacity_ml,omitempty"`
+	MaxPrice      *string   `json:"max_price,omitempty"`
+	MaxPriceCents *int64    `json:"max_price_cents,omitempty"`
+	Currency      *string   `json:"currency,omitempty"`
+	FragranceID   *int64    `json:"fragrance_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// WantMatch is a listing from another seller that satisfies one of a post's wants
+type WantMatch struct {
+	Want    StoredWant `json:"want"`
+	Listing Listing    `json:"listing"`
+}
+
+// ListingDiff is what changed between the stored listings of a post and a fresh parse of it
+type ListingDiff struct {
+	Added    FragranceListing // items that weren't stored before
+	Status   map[int64]string // listing id -> new status
+	Repriced map[int64]string // listing id -> new raw price
+}
+
+// Empty is true when applying the diff would change nothing
+func (d ListingDiff) Empty() bool {
+	return len(d.Added.Perfumes) == 0 && len(d.Status) == 0 && len(d.Repriced) == 0
+}
@@ -0,0 +1,15 @@
+package models
+
+// SearchResult is a listing found by a name search, Score is how well it
+// matched (higher is better, word matches beat typo matches)
+type SearchResult struct {
+	Listing
+	Brand *string `json:"brand,omitempty"` // catalog brand, when the name resolved
+	Score float64 `json:"score"`
+}
+
+// BrandFacet is how many of a search's matches belong to a catalog brand
+type BrandFacet struct {
+	Brand string `json:"brand"`
+	Count int    `json:"count"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// MarketStats summarizes what a fragrance goes for in one bottle kind and
+// size band, prices are in cents per ml and only USD listings are counted
+type MarketStats struct {
+	FragranceID int64   `json:"fragrance_id"`
+	BottleKind  string  `json:"bottle_kind"`
+	SizeBand    string  `json:"size_band"` // full bottle ml range, e.g. "91-125ml"
+	Samples     int     `json:"samples"`
+	P25         float64 `json:"p25_per_ml_cents"`
+	Median      float64 `json:"median_per_ml_cents"`
+	P75         float64 `json:"p75_per_ml_cents"`
+}
+
+// PriceBucket is MarketStats over the rolling window ending at BucketEnd
+type PriceBucket struct {
+	BucketStart time.Time `json:"bucket_start"`
+	BucketEnd   time.Time `json:"bucket_end"`
+	MarketStats
+}
+
+// Deal is a listing priced well below its fragrance's recent median for
+// the same bottle kind and size band
+type Deal struct {
+	Listing
+	SizeBand     string  `json:"size_band"`
+	MarketMedian float64 `json:"market_median_per_ml_cents"`
+	Samples      int     `json:"market_samples"`
+	Ratio        float64 `json:"ratio_to_median"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// ParseUsage is what a single llm call used, filled in by the provider
+type ParseUsage struct {
+	Provider         string
+	Model            string
+	PromptTokens     int64
+	CompletionTokens int64
+	CostUSD          *float64 // nil when the model's price isn't known
+	Cached           bool     // served from the parse cache, no tokens were spent
+}
+
+// ParseSpend sums up the parse runs of one day
+type ParseSpend struct {
+	Day              time.Time `json:"day"`
+	Runs             int       `json:"runs"`
+	LLMRuns          int       `json:"llm_runs"`
+	CacheHits        int       `json:"cache_hits"`
+	Failed           int       `json:"failed"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	CostUSD          float64   `json:"cost_usd"`
+}
+
+// ParseReview is a post whose llm output kept failing validation, parked for
+// a person to fix or accept
+type ParseReview struct {
+	ID            int64            `json:"id"`
+	RedditID      string           `json:"reddit_id"`
+	Post          Post             `json:"post"`
+	Listing       FragranceListing `json:"listing"`
+	Problems      []string         `json:"problems"`
+	Attempts      int              `json:"attempts"`
+	PromptVersion *string          `json:"prompt_version,omitempty"`
+	SeenCount     int              `json:"seen_count"`
+	Status        string           `json:"status"`
+	CreatedAt     time.Time        `json:"created_at"`
+}
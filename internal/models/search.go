@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// SavedSearch is a standing query, new listings that match it trigger a notification
+type SavedSearch struct {
+	ID              int64     `json:"id"`
+	Name            string    `json:"name"`
+	NamePattern     string    `json:"name_pattern"`
+	MinML           *float64  `json:"min_ml,omitempty"`
+	MaxML           *float64  `json:"max_ml,omitempty"`
+	BottleKind      *string   `json:"bottle_kind,omitempty"`
+	MaxPriceCents   *int64    `json:"max_price_cents,omitempty"`
+	SellerBlocklist []string  `json:"seller_blocklist"`
+	NotifyKind      string    `json:"notify_kind"`
+	NotifyTarget    string    `json:"notify_target"`
+	Active          bool      `json:"active"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// ListingEvent is published after a post's listings are stored
+type ListingEvent struct {
+	RedditID string `json:"reddit_id"`
+}
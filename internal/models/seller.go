@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Seller is everything we know about a reddit user from their posts
+type Seller struct {
+	Username  string    `json:"username"`
+	Posts     int       `json:"posts"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Listings  int       `json:"listings"`
+	Sold      int       `json:"sold"`
+
+	// median hours between a listing going up and a re-check finding it sold,
+	// so it's only as precise as the re-check interval
+	MedianHoursToSell *float64 `json:"median_hours_to_sell,omitempty"`
+
+	// average price per ml against the fragrance's market median, 1.0 is
+	// market price, 0.8 is 20% under. PricedListings is how many it's over.
+	PriceRatio     *float64 `json:"price_ratio,omitempty"`
+	PricedListings int      `json:"priced_listings"`
+
+	// from the seller's user flair, where the swap subs track confirmed trades
+	Flair           *string    `json:"flair,omitempty"`
+	ConfirmedTrades *int       `json:"confirmed_trades,omitempty"`
+	FlairUpdatedAt  *time.Time `json:"flair_updated_at,omitempty"`
+}
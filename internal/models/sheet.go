@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// SheetLink is a link in a post to a price list kept somewhere else, a google
+// sheet or a csv file. pictures are read with the post itself, see Post.Images
+type SheetLink struct {
+	ID        int64      `json:"id"`
+	RedditID  string     `json:"reddit_id"`
+	URL       string     `json:"url"`
+	Kind      string     `json:"kind"`
+	Status    string     `json:"status"`
+	Offers    int        `json:"offers"` // read from it on the last fetch
+	Error     *string    `json:"error,omitempty"`
+	FetchedAt *time.Time `json:"fetched_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// values for SheetLink.Kind
+const (
+	SheetGoogle = "google_sheet"
+	SheetCSV    = "csv"
+)
+
+// values for SheetLink.Status
+const (
+	SheetPending = "pending"
+	SheetFetched = "fetched"
+	SheetFailed  = "failed"
+)
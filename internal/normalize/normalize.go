@@ -0,0 +1,191 @@
+package normalize
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Kind is what sort of bottle the listing is for
+type Kind string
+
+const (
+	KindFull    Kind = "full"
+	KindPartial Kind = "partial"
+	KindDecant  Kind = "decant"
+)
+
+// anything at or under this is treated as a decant when the seller only gave a single size
+const DecantMaxML = 20.0
+
+const mlPerOz = 29.5735
+
+var (
+	ErrNoPrice = errors.New("no numeric price")
+	ErrNoSize  = errors.New("no numeric size")
+)
+
+// Listing holds the structured values derived from a free-form size and price.
+// Pointer fields are nil when the value couldn't be derived, Error says why.
+type Listing struct {
+	PriceCents      *int64
+	Currency        string
+	RemainingML     *float64
+	CapacityML      *float64
+	Kind            Kind
+	PricePerMLCents *float64
+	Error           string
+}
+
+// OK is true when both price and size normalized cleanly
+func (l Listing) OK() bool {
+	return l.Error == ""
+}
+
+// Normalize turns strings like "80/100ml" and "$150" into a Listing.
+// it never fails outright, rows that can't be parsed come back flagged
+// so callers can still store the raw values.
+func Normalize(size, price string) Listing {
+	var l Listing
+	var errs []string
+
+	cents, currency, err := ParsePrice(price)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("price %q: %v", price, err))
+	} else {
+		l.PriceCents = &cents
+		l.Currency = currency
+	}
+
+	remaining, capacity, err := ParseSize(size)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("size %q: %v", size, err))
+	} else {
+		l.RemainingML = &remaining
+		l.CapacityML = &capacity
+		l.Kind = ClassifyKind(remaining, capacity)
+	}
+
+	if l.PriceCents != nil && l.RemainingML != nil && *l.RemainingML > 0 {
+		perML := math.Round(float64(*l.PriceCents)/(*l.RemainingML)*100) / 100
+		l.PricePerMLCents = &perML
+	}
+
+	l.Error = strings.Join(errs, "; ")
+	return l
+}
+
+var (
+	// first number in the string, commas allowed as thousands separators
+	priceNumRe = regexp.MustCompile(`\d{1,3}(?:,\d{3})+(?:\.\d+)?|\d+(?:\.\d+)?`)
+
+	currencyMarkers = []struct {
+		marker   string
+		currency string
+	}{
+		// longer markers first so "CA$" wins over "$"
+		{"ca$", "CAD"},
+		{"c$", "CAD"},
+		{"cad", "CAD"},
+		{"au$", "AUD"},
+		{"aud", "AUD"},
+		{"€", "EUR"},
+		{"eur", "EUR"},
+		{"£", "GBP"},
+		{"gbp", "GBP"},
+		{"usd", "USD"},
+		{"$", "USD"},
+	}
+)
+
+// ParsePrice returns the price in minor units and an ISO currency code.
+// ranges like "$120-130" use the lower bound, same as the parser prompt.
+func ParsePrice(price string) (int64, string, error) {
+	s := strings.ToLower(strings.TrimSpace(price))
+	match := priceNumRe.FindString(s)
+	if match == "" {
+		return 0, "", ErrNoPrice
+	}
+	value, err := strconv.ParseFloat(strings.ReplaceAll(match, ",", ""), 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %v", ErrNoPrice, err)
+	}
+	if value <= 0 {
+		return 0, "", fmt.Errorf("%w: price must be positive", ErrNoPrice)
+	}
+
+	// no marker at all means the sub's default
+	currency := "USD"
+	for _, m := range currencyMarkers {
+		if strings.Contains(s, m.marker) {
+			currency = m.currency
+			break
+		}
+	}
+	return int64(math.Round(value * 100)), currency, nil
+}
+
+var (
+	// "80/100ml", "80 / 100 ml", "80%/100ml"
+	partialRe = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(%)?\s*(?:ml)?\s*/\s*(\d+(?:\.\d+)?)\s*(ml|oz)`)
+	// "100ml", "3.4 oz"
+	singleRe = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(ml|oz)`)
+)
+
+// ParseSize returns the ml left in the bottle and the bottle's full capacity
+func ParseSize(size string) (remaining, capacity float64, err error) {
+	s := strings.TrimSpace(size)
+
+	if m := partialRe.FindStringSubmatch(s); m != nil {
+		left, _ := strconv.ParseFloat(m[1], 64)
+		capacity, _ = strconv.ParseFloat(m[3], 64)
+		capacity = toML(capacity, m[4])
+		switch {
+		case m[2] == "%":
+			left = capacity * left / 100
+		default:
+			left = toML(left, m[4])
+		}
+		if capacity <= 0 || left <= 0 || left > capacity {
+			return 0, 0, fmt.Errorf("%w: remaining %.1f out of %.1f", ErrNoSize, left, capacity)
+		}
+		return round1(left), round1(capacity), nil
+	}
+
+	if m := singleRe.FindStringSubmatch(s); m != nil {
+		v, _ := strconv.ParseFloat(m[1], 64)
+		v = toML(v, m[2])
+		if v <= 0 {
+			return 0, 0, ErrNoSize
+		}
+		return round1(v), round1(v), nil
+	}
+
+	return 0, 0, ErrNoSize
+}
+
+// ClassifyKind decides between full, partial and decant from the ml values
+func ClassifyKind(remaining, capacity float64) Kind {
+	switch {
+	case remaining < capacity:
+		return KindPartial
+	case capacity <= DecantMaxML:
+		return KindDecant
+	default:
+		return KindFull
+	}
+}
+
+func toML(v float64, unit string) float64 {
+	if strings.EqualFold(unit, "oz") {
+		return v * mlPerOz
+	}
+	return v
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,124 @@
+package normalize
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		price    string
+		cents    int64
+		currency string
+		err      error
+	}{
+		{"$150", 15000, "USD", nil},
+		{"$12.50", 1250, "USD", nil},
+		{"$1,200", 120000, "USD", nil},
+		{"$120-130", 12000, "USD", nil},
+		{"150", 15000, "USD", nil},
+		{"CA$200", 20000, "CAD", nil},
+		{"€85", 8500, "EUR", nil},
+		{"£60 shipped", 6000, "GBP", nil},
+		{"90 usd", 9000, "USD", nil},
+		{"See Spreadsheet", 0, "", ErrNoPrice},
+		{"Trade", 0, "", ErrNoPrice},
+		{"$0", 0, "", ErrNoPrice},
+		{"", 0, "", ErrNoPrice},
+	}
+	for _, tt := range tests {
+		cents, currency, err := ParsePrice(tt.price)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParsePrice(%q) error = %v, want %v", tt.price, err, tt.err)
+			continue
+		}
+		if cents != tt.cents || currency != tt.currency {
+			t.Errorf("ParsePrice(%q) = %d %s, want %d %s", tt.price, cents, currency, tt.cents, tt.currency)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size      string
+		remaining float64
+		capacity  float64
+		err       error
+	}{
+		{"100ml", 100, 100, nil},
+		{"7.5ml", 7.5, 7.5, nil},
+		{"80/100ml", 80, 100, nil},
+		{"80 / 100 ml", 80, 100, nil},
+		{"80%/100ml", 80, 100, nil},
+		{"90/100 ml", 90, 100, nil},
+		{"3.4oz", 100.5, 100.5, nil},
+		{"3/3.4 oz", 88.7, 100.5, nil},
+		{"120/100ml", 0, 0, ErrNoSize},
+		{"0ml", 0, 0, ErrNoSize},
+		{"full bottle", 0, 0, ErrNoSize},
+		{"", 0, 0, ErrNoSize},
+	}
+	for _, tt := range tests {
+		remaining, capacity, err := ParseSize(tt.size)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseSize(%q) error = %v, want %v", tt.size, err, tt.err)
+			continue
+		}
+		if remaining != tt.remaining || capacity != tt.capacity {
+			t.Errorf("ParseSize(%q) = %v/%v, want %v/%v", tt.size, remaining, capacity, tt.remaining, tt.capacity)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		size, price string
+		kind        Kind
+		perML       float64 // 0 when it can't be worked out
+		ok          bool
+	}{
+		{"100ml", "$160", KindFull, 160, true},
+		{"80/100ml", "$120", KindPartial, 150, true},
+		{"85/90ml", "$190", KindPartial, 223.53, true},
+		{"5ml", "$18", KindDecant, 360, true},
+		{"20ml", "$40", KindDecant, 200, true},
+		{"30ml", "$60", KindFull, 200, true},
+		{"100ml", "See Spreadsheet", KindFull, 0, false},
+		{"", "$70", "", 0, false},
+	}
+	for _, tt := range tests {
+		n := Normalize(tt.size, tt.price)
+		if n.OK() != tt.ok {
+			t.Errorf("Normalize(%q, %q) ok = %v (%s), want %v", tt.size, tt.price, n.OK(), n.Error, tt.ok)
+		}
+		if n.Kind != tt.kind {
+			t.Errorf("Normalize(%q, %q) kind = %q, want %q", tt.size, tt.price, n.Kind, tt.kind)
+		}
+		var perML float64
+		if n.PricePerMLCents != nil {
+			perML = *n.PricePerMLCents
+		}
+		if perML != tt.perML {
+			t.Errorf("Normalize(%q, %q) price per ml = %v cents, want %v", tt.size, tt.price, perML, tt.perML)
+		}
+	}
+}
+
+func TestClassifyKind(t *testing.T) {
+	tests := []struct {
+		remaining, capacity float64
+		want                Kind
+	}{
+		{100, 100, KindFull},
+		{50, 100, KindPartial},
+		{5, 10, KindPartial},
+		{10, 10, KindDecant},
+		{DecantMaxML, DecantMaxML, KindDecant},
+		{DecantMaxML + 1, DecantMaxML + 1, KindFull},
+	}
+	for _, tt := range tests {
+		if got := ClassifyKind(tt.remaining, tt.capacity); got != tt.want {
+			t.Errorf("ClassifyKind(%v, %v) = %q, want %q", tt.remaining, tt.capacity, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"frag-aggra/internal/images"
+	"frag-aggra/internal/models"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	anthropicBaseURL   = "https://api.anthropic.com"
+	anthropicVersion   = "2023-06-01"
+	anthropicMaxTokens = 4096
+)
+
+// AnthropicExtractor talks to the anthropic messages api. it forces a single
+// tool call whose input schema is the listing schema, which is how anthropic
+// does structured output.
+type AnthropicExtractor struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	model      string
+	prompt     Prompt
+	limiter    *rateLimiter // nil sends without waiting
+}
+
+func NewAnthropic(apiKey, model string) (*AnthropicExtractor, error) {
+	if apiKey == "" {
+		return nil, errors.New("anthropic api key not set")
+	}
+	if model == "" {
+		return nil, errors.New("model not set for anthropic provider")
+	}
+	return &AnthropicExtractor{
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+		baseURL:    anthropicBaseURL,
+		apiKey:     apiKey,
+		model:      model,
+		prompt:     defaultPrompt(),
+	}, nil
+}
+
+type anthropicTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	InputSchema any    `json:"input_schema"`
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+// a content block of a message that is more than text
+type anthropicBlock struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicImageSource `json:"source,omitempty"`
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicRequest struct {
+	Model      string             `json:"model"`
+	MaxTokens  int                `json:"max_tokens"`
+	System     string             `json:"system"`
+	Messages   []anthropicMessage `json:"messages"`
+	Tools      []anthropicTool    `json:"tools"`
+	ToolChoice map[string]string  `json:"tool_choice"`
+}
+
+type anthropicResponse struct {
+	Model string `json:"model"`
+	Usage struct {
+		InputTokens  int64 `json:"input_tokens"`
+		OutputTokens int64 `json:"output_tokens"`
+	} `json:"usage"`
+	Content []struct {
+		Type  string          `json:"type"`
+		Name  string          `json:"name"`
+		Input json.RawMessage `json:"input"`
+	} `json:"content"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *AnthropicExtractor) Model() string {
+	return p.model
+}
+
+func (p *AnthropicExtractor) Prompt() Prompt {
+	return p.prompt
+}
+
+func (p *AnthropicExtractor) setPrompt(prompt Prompt) {
+	p.prompt = prompt
+}
+
+func (p *AnthropicExtractor) ParsePostContent(ctx context.Context, postContent string) (*models.FragranceListing, error) {
+	return p.ParsePostImages(ctx, postContent, nil)
+}
+
+// ParsePostImages sends the pictures as base64 image blocks after the text
+func (p *AnthropicExtractor) ParsePostImages(ctx context.Context, postContent string, imgs []images.Image) (*models.FragranceListing, error) {
+	return p.extract(ctx, anthropicPostMessage(postContent, imgs))
+}
+
+// RepairPostContent continues the conversation with the previous answer and
+// what was wrong with it
+func (p *AnthropicExtractor) RepairPostContent(ctx context.Context, postContent string, previous *models.FragranceListing, problems []Problem) (*models.FragranceListing, error) {
+	return p.RepairPostImages(ctx, postContent, nil, previous, problems)
+}
+
+func (p *AnthropicExtractor) RepairPostImages(ctx context.Context, postContent string, imgs []images.Image, previous *models.FragranceListing, problems []Problem) (*models.FragranceListing, error) {
+	answer, err := json.Marshal(previous)
+	if err != nil {
+		return nil, err
+	}
+	return p.extract(ctx,
+		anthropicPostMessage(postContent, imgs),
+		anthropicMessage{Role: "assistant", Content: string(answer)},
+		anthropicMessage{Role: "user", Content: repairMessage(problems)},
+	)
+}
+
+func anthropicPostMessage(postContent string, imgs []images.Image) anthropicMessage {
+	if len(imgs) == 0 {
+		return anthropicMessage{Role: "user", Content: postContent}
+	}
+	blocks := []anthropicBlock{{Type: "text", Text: postContent + "\n\n" + imagesNote}}
+	for _, img := range imgs {
+		blocks = append(blocks, anthropicBlock{Type: "image", Source: &anthropicImageSource{
+			Type:      "base64",
+			MediaType: img.MediaType,
+			Data:      base64.StdEncoding.EncodeToString(img.Data),
+		}})
+	}
+	return anthropicMessage{Role: "user", Content: blocks}
+}
+
+func (p *AnthropicExtractor) extract(ctx context.Context, messages ...anthropicMessage) (*models.FragranceListing, error) {
+	reqBody := anthropicRequest{
+		Model:     p.model,
+		MaxTokens: anthropicMaxTokens,
+		System:    p.prompt.Text,
+		Messages:  messages,
+		Tools: []anthropicTool{{
+			Name:        schemaName,
+			Description: schemaDescription,
+			InputSchema: generateSchema[models.FragranceListing](),
+		}},
+		ToolChoice: map[string]string{"type": "tool", "name": schemaName},
+	}
+
+	reserved, err := p.limiter.wait(ctx, estimateTokens(p.prompt.Text))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.send(ctx, reqBody)
+	if err != nil {
+		p.limiter.spend(reserved, nil)
+		return nil, err
+	}
+	p.limiter.spend(reserved, &models.ParseUsage{PromptTokens: resp.Usage.InputTokens, CompletionTokens: resp.Usage.OutputTokens})
+
+	for _, block := range resp.Content {
+		if block.Type != "tool_use" || block.Name != schemaName {
+			continue
+		}
+		var listing models.FragranceListing
+		if err := json.Unmarshal(block.Input, &listing); err != nil {
+			return nil, fmt.Errorf("failed to decode anthropic tool input: %w", err)
+		}
+		listing.Usage = &models.ParseUsage{
+			Provider:         ProviderAnthropic,
+			Model:            resp.Model,
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+		}
+		return &listing, nil
+	}
+	return nil, errors.New("anthropic response had no tool_use block")
+}
+
+func (p *AnthropicExtractor) send(ctx context.Context, body anthropicRequest) (*anthropicResponse, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/messages", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("x-api-key", p.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	httpResp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	raw, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read anthropic response: %w", err)
+	}
+
+	var resp anthropicResponse
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode anthropic response (status %d): %w", httpResp.StatusCode, err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		if resp.Error != nil {
+			return nil, fmt.Errorf("anthropic api error (status %d): %s: %s", httpResp.StatusCode, resp.Error.Type, resp.Error.Message)
+		}
+		return nil, fmt.Errorf("anthropic api error (status %d)", httpResp.StatusCode)
+	}
+	return &resp, nil
+}
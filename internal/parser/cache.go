@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"frag-aggra/internal/models"
+	"regexp"
+	"strings"
+)
+
+// ParseCache stores llm results by content, the repository implements it
+type ParseCache interface {
+	GetCachedParse(ctx context.Context, key string) (*models.FragranceListing, bool, error)
+	PutCachedParse(ctx context.Context, key, promptVersion, model string, listing *models.FragranceListing) error
+}
+
+var (
+	spaceRunRe = regexp.MustCompile(`[ \t\p{Zs}]+`)
+	blankRunRe = regexp.MustCompile(`\n{3,}`)
+)
+
+// CacheKey is the sha256 of the prompt id, model and normalized post text.
+// the prompt id changes with the prompt text or schema, so editing either
+// misses every older entry.
+func CacheKey(promptID, model, postContent string) string {
+	sum := sha256.Sum256([]byte(promptID + "\x00" + model + "\x00" + normalizeContent(postContent)))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeContent drops whitespace differences reposts and crossposts pick up
+// (line endings, trailing spaces, runs of blank lines) but keeps everything the
+// llm would read differently
+func normalizeContent(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spaceRunRe.ReplaceAllString(line, " "))
+	}
+	s = strings.Join(lines, "\n")
+	s = blankRunRe.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
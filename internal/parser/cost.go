@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"frag-aggra/internal/models"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrBudgetExceeded is returned instead of calling the llm once the day's spend
+// is over the budget
+var ErrBudgetExceeded = errors.New("daily llm budget exceeded")
+
+// Price is what a model charges in USD per million tokens
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// list prices at the time of writing, LLM_PRICE_INPUT/LLM_PRICE_OUTPUT
+// override them for anything else (or when they change)
+var modelPrices = map[string]Price{
+	"gpt-4o":                     {Input: 2.50, Output: 10.00},
+	"gpt-4o-2024-08-06":          {Input: 2.50, Output: 10.00},
+	"gpt-4o-mini":                {Input: 0.15, Output: 0.60},
+	"gpt-4o-mini-2024-07-18":     {Input: 0.15, Output: 0.60},
+	"gpt-4.1":                    {Input: 2.00, Output: 8.00},
+	"gpt-4.1-mini":               {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano":               {Input: 0.10, Output: 0.40},
+	"claude-3-5-haiku-latest":    {Input: 0.80, Output: 4.00},
+	"claude-3-5-haiku-20241022":  {Input: 0.80, Output: 4.00},
+	"claude-3-5-sonnet-latest":   {Input: 3.00, Output: 15.00},
+	"claude-3-5-sonnet-20241022": {Input: 3.00, Output: 15.00},
+	"claude-3-7-sonnet-latest":   {Input: 3.00, Output: 15.00},
+	"claude-sonnet-4-0":          {Input: 3.00, Output: 15.00},
+	"claude-sonnet-4":            {Input: 3.00, Output: 15.00},
+	"claude-sonnet-4-5":          {Input: 3.00, Output: 15.00},
+	"claude-opus-4":              {Input: 15.00, Output: 75.00},
+	"claude-opus-4-1":            {Input: 15.00, Output: 75.00},
+	"claude-haiku-4-5":           {Input: 1.00, Output: 5.00},
+}
+
+// ConservativePrice is at least what any listed model charges, for counting
+// calls to models without a known price against a budget
+var ConservativePrice = Price{Input: 15.00, Output: 75.00}
+
+// Pricing estimates what llm calls cost
+type Pricing struct {
+	Override *Price // used for every model when set
+	Fallback *Price // used for models without a known price when set
+}
+
+// PricingFromEnv reads LLM_PRICE_INPUT and LLM_PRICE_OUTPUT (USD per million tokens)
+func PricingFromEnv() Pricing {
+	in, inErr := strconv.ParseFloat(os.Getenv("LLM_PRICE_INPUT"), 64)
+	out, outErr := strconv.ParseFloat(os.Getenv("LLM_PRICE_OUTPUT"), 64)
+	if inErr != nil || outErr != nil {
+		return Pricing{}
+	}
+	return Pricing{Override: &Price{Input: in, Output: out}}
+}
+
+// Known reports whether calls to model are priced by the list or the override
+func (p Pricing) Known(provider, model string) bool {
+	_, ok := p.price(&models.ParseUsage{Provider: provider, Model: model})
+	return ok
+}
+
+// Cost is the estimated USD cost of usage, nil if the model's price isn't
+// known and there is no fallback. self hosted models without an override are
+// free as far as we're concerned.
+func (p Pricing) Cost(usage *models.ParseUsage) *float64 {
+	price, ok := p.price(usage)
+	if !ok && p.Fallback != nil {
+		price, ok = *p.Fallback, true
+	}
+	if !ok {
+		return nil
+	}
+	cost := (float64(usage.PromptTokens)*price.Input + float64(usage.CompletionTokens)*price.Output) / 1_000_000
+	return &cost
+}
+
+func (p Pricing) price(usage *models.ParseUsage) (Price, bool) {
+	if p.Override != nil {
+		return *p.Override, true
+	}
+	if usage.Provider == ProviderOpenAICompatible {
+		return Price{}, true
+	}
+	if price, ok := modelPrices[usage.Model]; ok {
+		return price, true
+	}
+	// responses name the dated snapshot, "gpt-4o-2024-11-20" is still gpt-4o.
+	// the longest match wins so "gpt-4.1-mini-2025-04-14" isn't priced as gpt-4.1
+	best, found := "", false
+	for model := range modelPrices {
+		if strings.HasPrefix(usage.Model, model+"-") && len(model) > len(best) {
+			best, found = model, true
+		}
+	}
+	return modelPrices[best], found
+}
+
+// Budget caps the estimated llm spend per UTC day
+type Budget struct {
+	DailyUSD float64
+
+	// the spend recorded since a time, the repository's ParseSpendSince
+	Spent func(ctx context.Context, since time.Time) (float64, error)
+}
+
+// Exceeded reports whether today's spend has reached the budget. a zero
+// budget never runs out.
+func (b *Budget) Exceeded(ctx context.Context) (bool, error) {
+	if b == nil || b.DailyUSD <= 0 {
+		return false, nil
+	}
+	spent, err := b.Spent(ctx, StartOfDay(time.Now()))
+	if err != nil {
+		return false, fmt.Errorf("failed to check llm budget: %w", err)
+	}
+	if spent >= b.DailyUSD {
+		log.Printf("llm spend today $%.4f is over the $%.2f budget", spent, b.DailyUSD)
+		return true, nil
+	}
+	return false, nil
+}
+
+// StartOfDay is midnight UTC of t's day, when the budget resets
+func StartOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
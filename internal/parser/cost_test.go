@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"frag-aggra/internal/models"
+	"testing"
+)
+
+func TestPricingDatedSnapshots(t *testing.T) {
+	tests := []struct {
+		model string
+		want  Price
+		known bool
+	}{
+		{"gpt-4o", modelPrices["gpt-4o"], true},
+		{"gpt-4o-2024-11-20", modelPrices["gpt-4o"], true},
+		{"gpt-4o-mini-2024-07-18", modelPrices["gpt-4o-mini"], true},
+		{"gpt-4.1-2025-04-14", modelPrices["gpt-4.1"], true},
+		{"gpt-4.1-mini-2025-04-14", modelPrices["gpt-4.1-mini"], true},
+		{"gpt-4.1-nano-2025-04-14", modelPrices["gpt-4.1-nano"], true},
+		{"claude-sonnet-4-20250514", modelPrices["claude-sonnet-4"], true},
+		{"claude-sonnet-4-5-20250929", modelPrices["claude-sonnet-4-5"], true},
+		{"claude-opus-4-1-20250805", modelPrices["claude-opus-4-1"], true},
+		{"claude-haiku-4-5-20251001", modelPrices["claude-haiku-4-5"], true},
+		{"some-local-model", Price{}, false},
+	}
+	for _, tt := range tests {
+		// map order is random, a wrong match would only show up some of the time
+		for range 20 {
+			got, ok := Pricing{}.price(&models.ParseUsage{Provider: ProviderOpenAI, Model: tt.model})
+			if ok != tt.known || got != tt.want {
+				t.Fatalf("price(%q) = %+v %v, want %+v %v", tt.model, got, ok, tt.want, tt.known)
+			}
+		}
+	}
+}
+
+// with a budget an unknown model has to cost something, or the budget never runs out
+func TestCostFallback(t *testing.T) {
+	usage := &models.ParseUsage{Provider: ProviderOpenAI, Model: "gpt-9-2031-01-01", PromptTokens: 1_000_000}
+	if cost := (Pricing{}).Cost(usage); cost != nil {
+		t.Errorf("cost without a fallback = %v, want nil", *cost)
+	}
+	cost := Pricing{Fallback: &ConservativePrice}.Cost(usage)
+	if cost == nil || *cost != ConservativePrice.Input {
+		t.Errorf("cost with the fallback = %v, want %v", cost, ConservativePrice.Input)
+	}
+	for model, price := range modelPrices {
+		if price.Input > ConservativePrice.Input || price.Output > ConservativePrice.Output {
+			t.Errorf("%s is priced above ConservativePrice", model)
+		}
+	}
+}
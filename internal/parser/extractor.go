@@ -0,0 +1,185 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"frag-aggra/internal/images"
+	"frag-aggra/internal/models"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/openai/openai-go/v2/option"
+)
+
+// ListingExtractor turns raw post text into a structured listing.
+// the worker only depends on this so the llm vendor can be swapped by config.
+type ListingExtractor interface {
+	ParsePostContent(ctx context.Context, postContent string) (*models.FragranceListing, error)
+}
+
+// modelNamer is implemented by extractors that know which model they call
+type modelNamer interface {
+	Model() string
+}
+
+// ModelOf is the model an extractor calls, empty if it doesn't say
+func ModelOf(e ListingExtractor) string {
+	if m, ok := e.(modelNamer); ok {
+		return m.Model()
+	}
+	return ""
+}
+
+// prompted is implemented by the llm extractors
+type prompted interface {
+	Prompt() Prompt
+	setPrompt(Prompt)
+}
+
+// Repairer is implemented by extractors that can take a second look at their
+// own output, given the post, the previous answer and what was wrong with it
+type Repairer interface {
+	RepairPostContent(ctx context.Context, postContent string, previous *models.FragranceListing, problems []Problem) (*models.FragranceListing, error)
+}
+
+// ImageExtractor is implemented by extractors that can send a vision model the
+// pictures of a post along with its text. items read off the pictures end up
+// in the same listing as the ones in the text.
+type ImageExtractor interface {
+	ParsePostImages(ctx context.Context, postContent string, imgs []images.Image) (*models.FragranceListing, error)
+	RepairPostImages(ctx context.Context, postContent string, imgs []images.Image, previous *models.FragranceListing, problems []Problem) (*models.FragranceListing, error)
+}
+
+// postParser is implemented by extractors that want the whole post, not just its text
+type postParser interface {
+	ParsePost(ctx context.Context, post models.Post) (*models.FragranceListing, error)
+}
+
+// ParsePost parses a post with e, its pictures included when e can read them
+func ParsePost(ctx context.Context, e ListingExtractor, post models.Post) (*models.FragranceListing, error) {
+	if pp, ok := e.(postParser); ok {
+		return pp.ParsePost(ctx, post)
+	}
+	return e.ParsePostContent(ctx, post.Title+"\n"+post.Body)
+}
+
+// PromptOf is the prompt an extractor sends, the zero Prompt if it has none
+func PromptOf(e ListingExtractor) Prompt {
+	if p, ok := e.(prompted); ok {
+		return p.Prompt()
+	}
+	return Prompt{}
+}
+
+const (
+	ProviderOpenAI           = "openai"
+	ProviderOpenAICompatible = "openai-compatible"
+	ProviderAnthropic        = "anthropic"
+)
+
+// Config picks the provider and model for an extractor
+type Config struct {
+	Provider string
+	Model    string
+	APIKey   string
+	BaseURL  string // only used by openai-compatible
+
+	PromptVersion string // a built in prompt, defaults to DefaultPromptVersion
+	PromptFile    string // a prompt on disk, wins over PromptVersion
+
+	HTTPClient *http.Client // nil uses the provider's default client
+
+	RateLimit RateLimit // shared by every extractor for the same provider
+}
+
+// ConfigFromEnv reads LLM_PROVIDER, LLM_MODEL, LLM_BASE_URL, LLM_API_KEY,
+// LLM_PROMPT_VERSION, LLM_PROMPT_FILE and the rate limit (RateLimitFromEnv).
+// the provider defaults to openai and the key falls back to the vendor's
+// usual variable (OPENAI_API_KEY / ANTHROPIC_API_KEY) so old .env files keep working.
+func ConfigFromEnv() Config {
+	return ConfigFromEnvFor(os.Getenv("LLM_PROVIDER"))
+}
+
+// ConfigFromEnvFor is ConfigFromEnv with the provider picked by the caller
+func ConfigFromEnvFor(provider string) Config {
+	cfg := Config{
+		Provider: strings.ToLower(strings.TrimSpace(provider)),
+		Model:    os.Getenv("LLM_MODEL"),
+		APIKey:   os.Getenv("LLM_API_KEY"),
+		BaseURL:  os.Getenv("LLM_BASE_URL"),
+
+		PromptVersion: os.Getenv("LLM_PROMPT_VERSION"),
+		PromptFile:    os.Getenv("LLM_PROMPT_FILE"),
+
+		RateLimit: RateLimitFromEnv(),
+	}
+	if cfg.Provider == "" {
+		cfg.Provider = ProviderOpenAI
+	}
+	if cfg.APIKey == "" {
+		switch cfg.Provider {
+		case ProviderOpenAI:
+			cfg.APIKey = os.Getenv("OPENAI_API_KEY")
+		case ProviderAnthropic:
+			cfg.APIKey = os.Getenv("ANTHROPIC_API_KEY")
+		}
+	}
+	return cfg
+}
+
+// New builds the extractor configured in the environment
+func New() (ListingExtractor, error) {
+	return NewFromConfig(ConfigFromEnv())
+}
+
+func NewFromConfig(cfg Config) (ListingExtractor, error) {
+	prompt, err := cfg.prompt()
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []option.RequestOption
+	if cfg.HTTPClient != nil {
+		opts = append(opts, option.WithHTTPClient(cfg.HTTPClient))
+	}
+
+	var e ListingExtractor
+	switch cfg.Provider {
+	case ProviderOpenAI:
+		e, err = NewOpenAI(cfg.APIKey, cfg.Model, opts...)
+	case ProviderOpenAICompatible:
+		e, err = NewOpenAICompatible(cfg.BaseURL, cfg.APIKey, cfg.Model, opts...)
+	case ProviderAnthropic:
+		var a *AnthropicExtractor
+		a, err = NewAnthropic(cfg.APIKey, cfg.Model)
+		if err == nil && cfg.HTTPClient != nil {
+			a.httpClient = cfg.HTTPClient
+		}
+		e = a
+	default:
+		return nil, fmt.Errorf("unknown llm provider %q", cfg.Provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.(prompted).setPrompt(prompt)
+	limiter := limiterFor(cfg.Provider, cfg.BaseURL, cfg.RateLimit)
+	switch x := e.(type) {
+	case *OpenAIExtractor:
+		x.limiter = limiter
+	case *AnthropicExtractor:
+		x.limiter = limiter
+	}
+	return e, nil
+}
+
+func (cfg Config) prompt() (Prompt, error) {
+	if cfg.PromptFile != "" {
+		return LoadPromptFile(cfg.PromptFile)
+	}
+	if cfg.PromptVersion != "" {
+		return LoadPrompt(cfg.PromptVersion)
+	}
+	return LoadPrompt(DefaultPromptVersion)
+}
@@ -0,0 +1,140 @@
+package parser_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"frag-aggra/internal/evaluate"
+	"frag-aggra/internal/models"
+	"frag-aggra/internal/parser"
+	"frag-aggra/internal/replay"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/eval/golden")
+
+const evalDir = "../../testdata/eval"
+
+// an openai extractor whose responses come from testdata/eval/recordings
+func replayed(t *testing.T) parser.ListingExtractor {
+	t.Helper()
+	transport := &replay.Transport{Dir: filepath.Join(evalDir, "recordings"), Mode: replay.Replay}
+	llm, err := parser.NewFromConfig(parser.Config{
+		Provider:      parser.ProviderOpenAI,
+		APIKey:        "replay",
+		PromptVersion: parser.DefaultPromptVersion,
+		HTTPClient:    transport.Client(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create parser: %v", err)
+	}
+	return llm
+}
+
+func TestGoldenLLM(t *testing.T) {
+	runGolden(t, "llm", replayed(t))
+}
+
+func TestGoldenRules(t *testing.T) {
+	runGolden(t, "rules", parser.NewPipeline(replayed(t), parser.DefaultRuleConfidence))
+}
+
+func runGolden(t *testing.T, kind string, extractor parser.ListingExtractor) {
+	fixtures, err := evaluate.LoadFixtures(evalDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(evalDir, "golden", kind)
+	for _, f := range fixtures {
+		t.Run(f.Name, func(t *testing.T) {
+			got, err := parser.ParsePost(context.Background(), extractor, f.Post)
+			if errors.Is(err, replay.ErrNoRecording) {
+				t.Fatalf("%v, record it with go run ./cmd/replay -record", err)
+			}
+			var invalid *parser.InvalidListingError
+			if errors.As(err, &invalid) {
+				got = invalid.Listing
+			}
+			result := evaluate.NewGolden(got, err)
+			if *update {
+				if err := evaluate.SaveGolden(dir, f.Name, result); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			accepted, err := evaluate.LoadGolden(dir, f.Name)
+			if err != nil {
+				t.Fatalf("%v, write it with go test ./internal/parser -update", err)
+			}
+			for _, d := range accepted.Diff(result) {
+				t.Error(d)
+			}
+			if got == nil {
+				got = &models.FragranceListing{}
+			}
+			score := evaluate.Compare(&f.Expected, got)
+			t.Logf("against the labels: names %s sizes %s prices %s", score.Names, score.Sizes, score.Prices)
+		})
+	}
+}
+
+// the recordings in the repo are synthetic, openai shaped answers written by
+// hand with the kind of mistakes models make and no usage. seeded ones only
+// echo the labels back and can't catch anything, they don't belong here.
+func TestRecordingsNotSeeded(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join(evalDir, "recordings", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		rec, err := replay.Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(rec.Response.Body, &body); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(path), err)
+		}
+		if body.ID == replay.SeededID {
+			t.Errorf("%s is seeded from the labels, write or record a real answer", filepath.Base(path))
+		}
+	}
+}
+
+// the recorded answer for the spreadsheet post has "100 ml" as a size, the
+// pipeline has to send it back and take the recorded repair
+func TestPipelineRepairsRecordedMistake(t *testing.T) {
+	fixtures, err := evaluate.LoadFixtures(evalDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	llm := replayed(t)
+	for _, f := range fixtures {
+		if f.Name != "spreadsheet" {
+			continue
+		}
+		first, err := llm.ParsePostContent(context.Background(), f.Input())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if problems := parser.Validate(first); len(problems) == 0 {
+			t.Fatalf("recorded first answer for %s is valid, nothing to repair", f.Name)
+		}
+		repaired, err := parser.NewPipeline(llm, parser.DefaultRuleConfidence).ParsePostContent(context.Background(), f.Input())
+		if err != nil {
+			t.Fatalf("pipeline: %v", err)
+		}
+		if problems := parser.Validate(repaired); len(problems) != 0 {
+			t.Errorf("repaired listing still has problems: %v", problems)
+		}
+		if score := evaluate.Compare(&f.Expected, repaired); !score.Perfect() {
+			t.Errorf("repaired listing scores names %s sizes %s prices %s", score.Names, score.Sizes, score.Prices)
+		}
+		return
+	}
+	t.Fatal("spreadsheet fixture not found")
+}
@@ -0,0 +1,15 @@
+package parser
+
+import (
+	"encoding/base64"
+	"frag-aggra/internal/images"
+)
+
+// sent after the post's text when it has pictures, the system prompt only talks about text
+const imagesNote = "The pictures attached to this post are part of it, in the order they appear in the post. " +
+	"Read names, sizes and prices off them too (price tags, handwritten lists, screenshots of spreadsheets) " +
+	"and merge them with the items in the text. An item shown in a picture and written in the text is listed once."
+
+func dataURL(img images.Image) string {
+	return "data:" + img.MediaType + ";base64," + base64.StdEncoding.EncodeToString(img.Data)
+}
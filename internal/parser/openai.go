@@ -4,122 +4,162 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"frag-aggra/internal/images"
 	"frag-aggra/internal/models"
-	"os"
 
-	"github.com/invopop/jsonschema"
 	"github.com/openai/openai-go/v2"
 	"github.com/openai/openai-go/v2/option"
 )
 
-type Parser struct {
-	client       *openai.Client
-	systemPrompt string
+// OpenAIExtractor talks to the OpenAI chat completions api, or anything that
+// speaks the same protocol when given a base url (llama.cpp, vLLM, Ollama).
+type OpenAIExtractor struct {
+	client   *openai.Client
+	provider string
+	model    string
+	strict   bool
+	prompt   Prompt
+	limiter  *rateLimiter // nil sends without waiting
 }
 
-func New() (*Parser, error) {
-	apiKey := os.Getenv("OPENAI_API_KEY")
+// NewOpenAI builds an extractor against api.openai.com, opts are passed on to
+// the client (e.g. option.WithHTTPClient to replay recorded responses)
+func NewOpenAI(apiKey, model string, opts ...option.RequestOption) (*OpenAIExtractor, error) {
 	if apiKey == "" {
-		return nil, errors.New("OPENAI_API_KEY environment variable not set")
+		return nil, errors.New("openai api key not set")
 	}
-	client := openai.NewClient(option.WithAPIKey(apiKey))
-
-	systemPrompt := `
-	You are a hyper-precise data extraction engine. Your sole purpose is to extract fragrance information from a Reddit post and convert it into a structured JSON object based on the provided schema. You must adhere to the following rules without exception:
-
-**Primary Objective:** Populate the 'perfumes' array with every fragrance found in the listing.
-
-**Extraction & Standardization Rules:**
-
-1.  **Brand Name Standardization (CRITICAL):**
-    * TF, T Ford → Tom Ford
-    * MFK → Maison Francis Kurkdijan
-    * PdM → Parfums de Marly
-    * BDC → Bleu de Chanel
-    * ADG → Armani Acqua di Gio
-    * YSL → Yves Saint Laurent
-    * Apply these transformations universally.
+	if model == "" {
+		model = openai.ChatModelGPT4o2024_08_06
+	}
+	client := openai.NewClient(append([]option.RequestOption{option.WithAPIKey(apiKey)}, opts...)...)
+	return &OpenAIExtractor{
+		client:   &client,
+		provider: ProviderOpenAI,
+		model:    model,
+		strict:   true,
+		prompt:   defaultPrompt(),
+	}, nil
+}
 
-2.  **Price Cleaning:**
-    * The 'prices' array must ONLY contain strings with a '$' prefix and numbers (e.g., "$150").
-    * **REMOVE ALL OTHER TEXT.** Do not include words like "shipped", "OBO", "sold", or any descriptive notes.
-    * If a price is listed as a range (e.g., "$120-130"), use the lower value ("$120").
-    * If an item is marked as "SOLD" or crossed out, **DO NOT** include it in the output.
+// NewOpenAICompatible builds an extractor against a self hosted endpoint.
+// local servers often don't enforce strict schemas so it is turned off, and
+// most of them ignore the api key so it can be empty.
+func NewOpenAICompatible(baseURL, apiKey, model string, opts ...option.RequestOption) (*OpenAIExtractor, error) {
+	if baseURL == "" {
+		return nil, errors.New("base url not set for openai-compatible provider")
+	}
+	if model == "" {
+		return nil, errors.New("model not set for openai-compatible provider")
+	}
+	if apiKey == "" {
+		apiKey = "unused"
+	}
+	client := openai.NewClient(append([]option.RequestOption{option.WithBaseURL(baseURL), option.WithAPIKey(apiKey)}, opts...)...)
+	return &OpenAIExtractor{
+		client:   &client,
+		provider: ProviderOpenAICompatible,
+		model:    model,
+		strict:   false,
+		prompt:   defaultPrompt(),
+	}, nil
+}
 
-3.  **Size Formatting:**
-    * For partial bottles, always use the 'X/Yml' format (e.g., "80/100ml").
-    * For decants or full bottles, use the format 'Xml' (e.g., "10ml", "100ml").
-    * Ensure the "ml" suffix is always present.
-	* BNIB or bnib means "Brand New In Box" and should not affect size formatting.
+func (p *OpenAIExtractor) Model() string {
+	return p.model
+}
 
-4.  **Name Accuracy:**
-	* Extract the full perfume name as accurately as possible.
-	* If the name is abbreviated or contains typos, correct it based on common fragrance knowledge.
+func (p *OpenAIExtractor) Prompt() Prompt {
+	return p.prompt
+}
 
-**Handling Edge Cases:**
+func (p *OpenAIExtractor) setPrompt(prompt Prompt) {
+	p.prompt = prompt
+}
 
-* **Spreadsheet Links:** If the post directs you to a spreadsheet or an external link for prices (e.g., "See link for details"), and does not list prices directly in the body for an item, you MUST handle it as follows:
-    * Extract the perfume name and sizes as usual.
-    * For the corresponding entry in the 'prices' array, use the exact string: **"See Spreadsheet"**.
-    * Do this for every item whose price is not explicitly listed.
+func (p *OpenAIExtractor) ParsePostContent(ctx context.Context, postContent string) (*models.FragranceListing, error) {
+	return p.ParsePostImages(ctx, postContent, nil)
+}
 
-* **No Price or Size:** If a perfume is listed but has no price or size mentioned (and no spreadsheet link), omit it from the results entirely. Every valid entry must have a name, at least one size, and a corresponding price (or "See link for details").
+// ParsePostImages sends the pictures as image parts after the text, the
+// model has to support vision
+func (p *OpenAIExtractor) ParsePostImages(ctx context.Context, postContent string, imgs []images.Image) (*models.FragranceListing, error) {
+	return p.complete(ctx, openAIPostMessage(postContent, imgs))
+}
 
-**Final Output:**
-* Your final response must be ONLY the JSON object. Do not include any introductory text, apologies, or explanations.
-* The JSON must be perfectly valid and strictly adhere to the provided schema.
-`
+// RepairPostContent continues the conversation with the previous answer and
+// what was wrong with it
+func (p *OpenAIExtractor) RepairPostContent(ctx context.Context, postContent string, previous *models.FragranceListing, problems []Problem) (*models.FragranceListing, error) {
+	return p.RepairPostImages(ctx, postContent, nil, previous, problems)
+}
 
-	return &Parser{
-		client:       &client,
-		systemPrompt: systemPrompt,
-	}, nil
+func (p *OpenAIExtractor) RepairPostImages(ctx context.Context, postContent string, imgs []images.Image, previous *models.FragranceListing, problems []Problem) (*models.FragranceListing, error) {
+	answer, err := json.Marshal(previous)
+	if err != nil {
+		return nil, err
+	}
+	return p.complete(ctx,
+		openAIPostMessage(postContent, imgs),
+		openai.AssistantMessage(string(answer)),
+		openai.UserMessage(repairMessage(problems)),
+	)
+}
 
+func openAIPostMessage(postContent string, imgs []images.Image) openai.ChatCompletionMessageParamUnion {
+	if len(imgs) == 0 {
+		return openai.UserMessage(postContent)
+	}
+	parts := []openai.ChatCompletionContentPartUnionParam{openai.TextContentPart(postContent + "\n\n" + imagesNote)}
+	for _, img := range imgs {
+		parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: dataURL(img)}))
+	}
+	return openai.UserMessage(parts)
 }
 
-func (p *Parser) ParsePostContent(ctx context.Context, postContent string) (*models.FragranceListing, error) {
+func (p *OpenAIExtractor) complete(ctx context.Context, messages ...openai.ChatCompletionMessageParamUnion) (*models.FragranceListing, error) {
 
 	var FragranceListingSchema = generateSchema[models.FragranceListing]()
 
 	schemaParam := openai.ResponseFormatJSONSchemaJSONSchemaParam{
-		Name:        "fragrance_listing",
-		Description: openai.String("Information about the perfumes extracted from a sale listing"),
+		Name:        schemaName,
+		Description: openai.String(schemaDescription),
 		Schema:      FragranceListingSchema,
-		Strict:      openai.Bool(true),
+		Strict:      openai.Bool(p.strict),
 	}
 
+	reserved, err := p.limiter.wait(ctx, estimateTokens(p.prompt.Text))
+	if err != nil {
+		return nil, err
+	}
 	resp, err := p.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage(p.systemPrompt),
-			openai.UserMessage(postContent),
-		},
+		Messages: append([]openai.ChatCompletionMessageParamUnion{openai.SystemMessage(p.prompt.Text)}, messages...),
 		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
 			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
 				JSONSchema: schemaParam,
 			},
 		},
-		Model: openai.ChatModelGPT4o2024_08_06,
+		Model: p.model,
 	})
 
 	if err != nil {
+		p.limiter.spend(reserved, nil)
 		return nil, err
 	}
+	p.limiter.spend(reserved, &models.ParseUsage{PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens})
+	if len(resp.Choices) == 0 {
+		return nil, errors.New("openai returned no choices")
+	}
 
 	var listing models.FragranceListing
 	err = json.Unmarshal([]byte(resp.Choices[0].Message.Content), &listing)
 	if err != nil {
 		return nil, err
 	}
+	listing.Usage = &models.ParseUsage{
+		Provider:         p.provider,
+		Model:            resp.Model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+	}
 
 	return &listing, nil
 }
-
-func generateSchema[T any]() interface{} {
-	reflector := jsonschema.Reflector{
-		AllowAdditionalProperties: false,
-		DoNotReference:            true,
-	}
-	var v T
-	schema := reflector.Reflect(v)
-	return schema
-}
@@ -0,0 +1,212 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"frag-aggra/internal/images"
+	"frag-aggra/internal/models"
+	"log"
+	"strings"
+)
+
+// Pipeline tries the rule extractor first and only pays for an llm call when
+// the rules aren't confident. it stamps ExtractedBy so we know which path ran,
+// and the estimated cost of the llm call on the listing's Usage.
+type Pipeline struct {
+	rules         *RuleExtractor
+	llm           ListingExtractor
+	minConfidence float64
+
+	Pricing Pricing
+	Budget  *Budget    // nil means no limit
+	Cache   ParseCache // nil means every post not handled by the rules calls the llm
+
+	// llm calls per post before invalid output is given up on with an
+	// InvalidListingError, the first parse included. 1 never repairs.
+	MaxAttempts int
+
+	// downloads a post's pictures for llms that implement ImageExtractor,
+	// nil parses the text only. MaxImages caps how many are sent per post.
+	Images    images.Fetcher
+	MaxImages int
+}
+
+// DefaultMaxAttempts is a parse and one repair
+const DefaultMaxAttempts = 2
+
+// DefaultMaxImages keeps a post with a big gallery from costing more than a few text posts
+const DefaultMaxImages = 4
+
+func NewPipeline(llm ListingExtractor, minConfidence float64) *Pipeline {
+	if minConfidence <= 0 || minConfidence > 1 {
+		minConfidence = DefaultRuleConfidence
+	}
+	return &Pipeline{
+		rules:         NewRuleExtractor(),
+		llm:           llm,
+		minConfidence: minConfidence,
+		MaxAttempts:   DefaultMaxAttempts,
+		MaxImages:     DefaultMaxImages,
+	}
+}
+
+func (p *Pipeline) ParsePostContent(ctx context.Context, postContent string) (*models.FragranceListing, error) {
+	listing, confidence := p.rules.Extract(postContent)
+	if listing != nil && confidence >= p.minConfidence {
+		log.Printf("rule extractor matched %d perfumes (confidence %.2f), skipping llm", len(listing.Perfumes), confidence)
+		// no intent, the rules can't tell a sale from a trade priced for
+		// reference. the worker falls back to the post's tag.
+		listing.ExtractedBy = models.ExtractedByRules
+		return listing, nil
+	}
+	return p.parseLLM(ctx, postContent, nil)
+}
+
+// ParsePost is ParsePostContent for the post's title and body, plus its
+// pictures when the llm can read them. posts with pictures skip the rules,
+// they can't see what's in them.
+func (p *Pipeline) ParsePost(ctx context.Context, post models.Post) (*models.FragranceListing, error) {
+	postContent := post.Title + "\n" + post.Body
+	if _, ok := p.llm.(ImageExtractor); !ok || p.Images == nil || len(post.Images) == 0 {
+		return p.ParsePostContent(ctx, postContent)
+	}
+	links := post.Images
+	if p.MaxImages > 0 && len(links) > p.MaxImages {
+		links = links[:p.MaxImages]
+	}
+	return p.parseLLM(ctx, postContent, links)
+}
+
+func (p *Pipeline) parseLLM(ctx context.Context, postContent string, imageLinks []string) (*models.FragranceListing, error) {
+	// identical text and pictures already parsed with the same prompt and model is free
+	model, promptID := ModelOf(p.llm), PromptOf(p.llm).ID()
+	key := CacheKey(promptID, model, strings.Join(append([]string{postContent}, imageLinks...), "\n"))
+	if p.Cache != nil {
+		cached, ok, err := p.Cache.GetCachedParse(ctx, key)
+		if err != nil {
+			log.Printf("parse cache lookup failed, calling llm: %v", err)
+		} else if ok {
+			log.Printf("parse cache hit, skipping llm")
+			cached.ExtractedBy = models.ExtractedByLLM
+			cached.PromptVersion = promptID
+			cached.Usage = &models.ParseUsage{Model: model, Cached: true}
+			return cached, nil
+		}
+	}
+
+	exceeded, err := p.Budget.Exceeded(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if exceeded {
+		return nil, ErrBudgetExceeded
+	}
+
+	listing, err := p.parseValid(ctx, postContent, p.fetchImages(ctx, imageLinks))
+	var invalid *InvalidListingError
+	if errors.As(err, &invalid) {
+		// the calls were still paid for, and the review needs to know where it came from
+		p.stamp(invalid.Listing, promptID)
+	}
+	var repairErr *RepairError
+	if errors.As(err, &repairErr) && repairErr.Usage != nil {
+		repairErr.Usage.CostUSD = p.Pricing.Cost(repairErr.Usage)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if listing != nil {
+		p.stamp(listing, promptID)
+		if p.Cache != nil {
+			if err := p.Cache.PutCachedParse(ctx, key, promptID, model, listing); err != nil {
+				log.Printf("failed to cache parse: %v", err)
+			}
+		}
+	}
+	return listing, nil
+}
+
+// downloads what it can, a dead link shouldn't cost the post its text
+func (p *Pipeline) fetchImages(ctx context.Context, links []string) []images.Image {
+	var imgs []images.Image
+	for _, link := range links {
+		fetched, err := p.Images.Fetch(ctx, link)
+		if err != nil {
+			log.Printf("failed to fetch image %s, parsing without it: %v", link, err)
+		}
+		imgs = append(imgs, fetched...)
+	}
+	if p.MaxImages > 0 && len(imgs) > p.MaxImages {
+		// albums expand to more than one
+		imgs = imgs[:p.MaxImages]
+	}
+	return imgs
+}
+
+func (p *Pipeline) stamp(listing *models.FragranceListing, promptID string) {
+	listing.ExtractedBy = models.ExtractedByLLM
+	listing.PromptVersion = promptID
+	if listing.Usage != nil {
+		listing.Usage.CostUSD = p.Pricing.Cost(listing.Usage)
+	}
+}
+
+// calls the llm and, while its output fails Validate, asks it to repair it.
+// the returned listing's usage covers every call.
+func (p *Pipeline) parseValid(ctx context.Context, postContent string, imgs []images.Image) (*models.FragranceListing, error) {
+	parse := p.llm.ParsePostContent
+	repairer, canRepair := p.llm.(Repairer)
+	repair := func(ctx context.Context, previous *models.FragranceListing, problems []Problem) (*models.FragranceListing, error) {
+		return repairer.RepairPostContent(ctx, postContent, previous, problems)
+	}
+	if vision, ok := p.llm.(ImageExtractor); ok && len(imgs) > 0 {
+		parse = func(ctx context.Context, postContent string) (*models.FragranceListing, error) {
+			return vision.ParsePostImages(ctx, postContent, imgs)
+		}
+		// the repair has to see the pictures too or it drops what was read off them
+		canRepair = true
+		repair = func(ctx context.Context, previous *models.FragranceListing, problems []Problem) (*models.FragranceListing, error) {
+			return vision.RepairPostImages(ctx, postContent, imgs, previous, problems)
+		}
+	}
+
+	listing, err := parse(ctx, postContent)
+	if err != nil || listing == nil {
+		return listing, err
+	}
+	attempts := 1
+	for {
+		problems := Validate(listing)
+		if len(problems) == 0 {
+			return listing, nil
+		}
+		if !canRepair || attempts >= p.MaxAttempts {
+			return nil, &InvalidListingError{Listing: listing, Problems: problems, Attempts: attempts}
+		}
+		log.Printf("llm output has %d problems, asking for a repair (attempt %d of %d)", len(problems), attempts+1, p.MaxAttempts)
+		repaired, err := repair(ctx, listing, problems)
+		attempts++
+		if err != nil {
+			return nil, &RepairError{Err: err, Usage: listing.Usage}
+		}
+		if repaired == nil {
+			return nil, &InvalidListingError{Listing: listing, Problems: problems, Attempts: attempts}
+		}
+		repaired.Usage = addUsage(listing.Usage, repaired.Usage)
+		listing = repaired
+	}
+}
+
+// the tokens of two calls on the same post, the later call's model wins
+func addUsage(a, b *models.ParseUsage) *models.ParseUsage {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	sum := *b
+	sum.PromptTokens += a.PromptTokens
+	sum.CompletionTokens += a.CompletionTokens
+	return &sum
+}
@@ -0,0 +1,38 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"frag-aggra/internal/models"
+	"testing"
+)
+
+// answers with a listing Validate rejects and fails every repair
+type failingRepairs struct{}
+
+func (failingRepairs) ParsePostContent(ctx context.Context, postContent string) (*models.FragranceListing, error) {
+	return &models.FragranceListing{
+		Perfumes: []models.Perfume{{Name: "Creed Aventus", Offers: []models.Offer{{Size: "100 ml", Price: "$250"}}}},
+		Usage:    &models.ParseUsage{Provider: ProviderOpenAI, Model: "gpt-4o-mini", PromptTokens: 1200, CompletionTokens: 80},
+	}, nil
+}
+
+func (failingRepairs) RepairPostContent(ctx context.Context, postContent string, previous *models.FragranceListing, problems []Problem) (*models.FragranceListing, error) {
+	return nil, errors.New("openai returned status 500")
+}
+
+func TestPipelineKeepsUsageOfFailedRepair(t *testing.T) {
+	p := NewPipeline(failingRepairs{}, DefaultRuleConfidence)
+	_, err := p.ParsePostContent(context.Background(), "[WTS] see the comments")
+
+	var repairErr *RepairError
+	if !errors.As(err, &repairErr) {
+		t.Fatalf("err = %v, want a RepairError", err)
+	}
+	if repairErr.Usage == nil || repairErr.Usage.PromptTokens != 1200 || repairErr.Usage.CompletionTokens != 80 {
+		t.Fatalf("usage = %+v, want the first call's tokens", repairErr.Usage)
+	}
+	if repairErr.Usage.CostUSD == nil || *repairErr.Usage.CostUSD <= 0 {
+		t.Errorf("cost = %v, want the first call priced", repairErr.Usage.CostUSD)
+	}
+}
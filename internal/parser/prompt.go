@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"frag-aggra/internal/models"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+)
+
+//go:embed prompts/*.txt
+var promptFS embed.FS
+
+// DefaultPromptVersion is the prompt used when none is configured. released
+// versions are never edited, a change goes into a new file so the old one can
+// still be compared against it. v1 predates offers and still describes the
+// parallel sizes/prices arrays, the schema makes the model answer with offers
+// anyway.
+const DefaultPromptVersion = "v3"
+
+// Prompt is a system prompt and the version it's known by. versions live in
+// prompts/<version>.txt, shared by every provider so they all extract the same way.
+type Prompt struct {
+	Version string
+	Text    string
+}
+
+// LoadPrompt returns a built in prompt version
+func LoadPrompt(version string) (Prompt, error) {
+	text, err := promptFS.ReadFile("prompts/" + version + ".txt")
+	if err != nil {
+		return Prompt{}, fmt.Errorf("unknown prompt version %q", version)
+	}
+	return Prompt{Version: version, Text: string(text)}, nil
+}
+
+// LoadPromptFile reads a prompt from disk, its version is the file name
+// without the extension ("prompts/v2-terse.txt" is "v2-terse")
+func LoadPromptFile(path string) (Prompt, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return Prompt{}, fmt.Errorf("failed to read prompt %s: %w", path, err)
+	}
+	base := filepath.Base(path)
+	return Prompt{Version: strings.TrimSuffix(base, filepath.Ext(base)), Text: string(text)}, nil
+}
+
+// PromptVersions lists the built in prompt versions
+func PromptVersions() []string {
+	entries, _ := promptFS.ReadDir("prompts")
+	var versions []string
+	for _, e := range entries {
+		versions = append(versions, strings.TrimSuffix(e.Name(), ".txt"))
+	}
+	return versions
+}
+
+func defaultPrompt() Prompt {
+	p, err := LoadPrompt(DefaultPromptVersion)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// ID is the version plus a hash of the prompt text and the listing schema, so
+// an edit that forgot to bump the version still shows up as a different prompt
+func (p Prompt) ID() string {
+	schema, _ := json.Marshal(generateSchema[models.FragranceListing]())
+	sum := sha256.Sum256([]byte(p.Text + "\n" + string(schema)))
+	return p.Version + "-" + hex.EncodeToString(sum[:4])
+}
+
+const (
+	schemaName        = "fragrance_listing"
+	schemaDescription = "Information about the perfumes extracted from a sale listing"
+)
+
+func generateSchema[T any]() interface{} {
+	reflector := jsonschema.Reflector{
+		AllowAdditionalProperties: false,
+		DoNotReference:            true,
+	}
+	var v T
+	schema := reflector.Reflect(v)
+	return schema
+}
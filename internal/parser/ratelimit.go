@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"context"
+	"frag-aggra/internal/models"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit is how much a provider lets an account send per minute. zero
+// leaves that side unlimited.
+type RateLimit struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// RateLimitFromEnv reads LLM_REQUESTS_PER_MINUTE and LLM_TOKENS_PER_MINUTE
+func RateLimitFromEnv() RateLimit {
+	rpm, _ := strconv.Atoi(os.Getenv("LLM_REQUESTS_PER_MINUTE"))
+	tpm, _ := strconv.Atoi(os.Getenv("LLM_TOKENS_PER_MINUTE"))
+	return RateLimit{RequestsPerMinute: max(rpm, 0), TokensPerMinute: max(tpm, 0)}
+}
+
+func (l RateLimit) unlimited() bool {
+	return l.RequestsPerMinute <= 0 && l.TokensPerMinute <= 0
+}
+
+// rateLimiter spaces out the llm calls made to one provider. both budgets are
+// buckets holding a minute's worth that refill continuously. a call's tokens
+// aren't known until its response is back, so wait reserves what the call is
+// expected to use (the last call's usage, or the prompt's length when that's
+// more) and spend settles the difference once the real usage is in. without
+// the reservation every concurrent worker would go out on the same token.
+type rateLimiter struct {
+	limit RateLimit
+	now   func() time.Time
+
+	mu       sync.Mutex
+	requests float64
+	tokens   float64
+	updated  time.Time
+	lastCall int // tokens the last call used
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	return &rateLimiter{
+		limit:    limit,
+		now:      time.Now,
+		requests: float64(limit.RequestsPerMinute),
+		tokens:   float64(limit.TokensPerMinute),
+		updated:  time.Now(),
+	}
+}
+
+// every extractor for the same provider and endpoint shares a limiter, the
+// provider counts their calls against the same account
+var (
+	limitersMu sync.Mutex
+	limiters   = map[string]*rateLimiter{}
+)
+
+// limiterFor is the shared limiter of a provider, nil when limit is unlimited.
+// the first limit asked for a provider is the one it keeps.
+func limiterFor(provider, baseURL string, limit RateLimit) *rateLimiter {
+	if limit.unlimited() {
+		return nil
+	}
+	key := provider + " " + baseURL
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	if l, ok := limiters[key]; ok {
+		return l
+	}
+	l := newRateLimiter(limit)
+	limiters[key] = l
+	return l
+}
+
+// estimateTokens is roughly how many tokens text is, about 4 bytes each
+func estimateTokens(text string) int {
+	return len(text) / 4
+}
+
+// wait blocks until a request may be sent and the tokens it's expected to
+// use, at least estimate, are in the bucket, and takes both out. it returns
+// what it reserved for spend to settle. a nil limiter never waits.
+func (l *rateLimiter) wait(ctx context.Context, estimate int) (int, error) {
+	if l == nil {
+		return 0, nil
+	}
+	for {
+		l.mu.Lock()
+		l.refill(l.now())
+		reserve := l.reservation(estimate)
+		delay := l.delay(reserve)
+		if delay == 0 {
+			if l.limit.RequestsPerMinute > 0 {
+				l.requests--
+			}
+			l.tokens -= float64(reserve)
+		}
+		l.mu.Unlock()
+		if delay == 0 {
+			return reserve, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// the tokens to hold back for the next call. never more than a minute's
+// worth, a call that big could otherwise never go out.
+func (l *rateLimiter) reservation(estimate int) int {
+	if l.limit.TokensPerMinute <= 0 {
+		return 0
+	}
+	return min(max(estimate, l.lastCall), l.limit.TokensPerMinute)
+}
+
+// spend settles a call's reservation against the tokens it actually used.
+// usage is nil when the call failed, the reservation is given back.
+func (l *rateLimiter) spend(reserved int, usage *models.ParseUsage) {
+	if l == nil || l.limit.TokensPerMinute <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(l.now())
+	used := 0
+	if usage != nil {
+		used = int(usage.PromptTokens + usage.CompletionTokens)
+		l.lastCall = used
+	}
+	l.tokens += float64(reserved - used)
+}
+
+func (l *rateLimiter) refill(now time.Time) {
+	minutes := now.Sub(l.updated).Minutes()
+	l.updated = now
+	if rpm := float64(l.limit.RequestsPerMinute); rpm > 0 {
+		l.requests = min(l.requests+minutes*rpm, rpm)
+	}
+	if tpm := float64(l.limit.TokensPerMinute); tpm > 0 {
+		l.tokens = min(l.tokens+minutes*tpm, tpm)
+	}
+}
+
+// how long until there is a request and need tokens (at least one, so the
+// bucket is out of debt), 0 if now
+func (l *rateLimiter) delay(need int) time.Duration {
+	var minutes float64
+	if rpm := float64(l.limit.RequestsPerMinute); rpm > 0 && l.requests < 1 {
+		minutes = (1 - l.requests) / rpm
+	}
+	if tpm := float64(l.limit.TokensPerMinute); tpm > 0 {
+		if short := float64(max(need, 1)) - l.tokens; short > 0 {
+			minutes = max(minutes, short/tpm)
+		}
+	}
+	if minutes == 0 {
+		return 0
+	}
+	return time.Duration(minutes * float64(time.Minute))
+}
@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"context"
+	"frag-aggra/internal/models"
+	"testing"
+	"time"
+)
+
+// a limiter on a clock the test moves by hand
+func fakeLimiter(limit RateLimit) (*rateLimiter, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(limit)
+	l.now = func() time.Time { return now }
+	l.updated = now
+	return l, &now
+}
+
+func TestRateLimiterDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    RateLimit
+		requests float64
+		tokens   float64
+		need     int
+		want     time.Duration
+	}{
+		{"full buckets", RateLimit{RequestsPerMinute: 60, TokensPerMinute: 10000}, 60, 10000, 4500, 0},
+		{"out of requests", RateLimit{RequestsPerMinute: 60}, 0, 0, 0, time.Second},
+		{"half a request left", RateLimit{RequestsPerMinute: 60}, 0.5, 0, 0, 500 * time.Millisecond},
+		{"not enough tokens for the call", RateLimit{TokensPerMinute: 10000}, 0, 1000, 4500, 21 * time.Second},
+		{"tokens in debt", RateLimit{TokensPerMinute: 10000}, 0, -2000, 0, 12006 * time.Millisecond},
+		{"longer of the two", RateLimit{RequestsPerMinute: 60, TokensPerMinute: 6000}, 0, 0, 3000, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		l, _ := fakeLimiter(tt.limit)
+		l.requests, l.tokens = tt.requests, tt.tokens
+		if got := l.delay(tt.need); got.Round(time.Millisecond) != tt.want {
+			t.Errorf("%s: delay = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	l, now := fakeLimiter(RateLimit{RequestsPerMinute: 60, TokensPerMinute: 10000})
+	l.requests, l.tokens = 0, -5000
+
+	*now = now.Add(30 * time.Second)
+	l.refill(*now)
+	if l.requests != 30 || l.tokens != 0 {
+		t.Errorf("after 30s requests %v tokens %v, want 30 and 0", l.requests, l.tokens)
+	}
+	// never more than a minute's worth
+	*now = now.Add(5 * time.Minute)
+	l.refill(*now)
+	if l.requests != 60 || l.tokens != 10000 {
+		t.Errorf("after 5m requests %v tokens %v, want 60 and 10000", l.requests, l.tokens)
+	}
+}
+
+// concurrent workers each hold back a call's worth, so the third one waits
+// instead of all of them going out on the same token
+func TestRateLimiterReservesEstimate(t *testing.T) {
+	l, now := fakeLimiter(RateLimit{TokensPerMinute: 10000})
+	ctx := context.Background()
+
+	var reserved []int
+	for range 2 {
+		r, err := l.wait(ctx, 4500)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reserved = append(reserved, r)
+	}
+	if reserved[0] != 4500 || reserved[1] != 4500 || l.tokens != 1000 {
+		t.Fatalf("reserved %v leaving %v tokens, want 4500 each leaving 1000", reserved, l.tokens)
+	}
+	if d := l.delay(l.reservation(4500)); d != 21*time.Second {
+		t.Errorf("third call waits %s, want 21s", d)
+	}
+
+	// the first call used less than reserved, the second failed
+	l.spend(reserved[0], &models.ParseUsage{PromptTokens: 4000, CompletionTokens: 200})
+	l.spend(reserved[1], nil)
+	if l.tokens != 5800 || l.lastCall != 4200 {
+		t.Errorf("after settling tokens %v last call %d, want 5800 and 4200", l.tokens, l.lastCall)
+	}
+
+	// the last call's usage is the estimate when it's bigger than the caller's
+	if r := l.reservation(1000); r != 4200 {
+		t.Errorf("reservation = %d, want the last call's 4200", r)
+	}
+	// and a call is never held to more than a minute's worth
+	if r := l.reservation(50000); r != 10000 {
+		t.Errorf("reservation = %d, want it capped at 10000", r)
+	}
+
+	*now = now.Add(time.Minute)
+	if _, err := l.wait(ctx, 4500); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRateLimiterWaitCancelled(t *testing.T) {
+	l, _ := fakeLimiter(RateLimit{TokensPerMinute: 1000})
+	l.tokens = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.wait(ctx, 500); err == nil {
+		t.Fatal("wait on an empty bucket returned without the context's error")
+	}
+}
+
+func TestNilRateLimiter(t *testing.T) {
+	var l *rateLimiter
+	if r, err := l.wait(context.Background(), 4500); r != 0 || err != nil {
+		t.Errorf("nil limiter wait = %d %v", r, err)
+	}
+	l.spend(4500, &models.ParseUsage{PromptTokens: 10})
+}
@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"frag-aggra/internal/models"
+)
+
+// DefaultRuleConfidence is the minimum share of price/size lines the rule
+// extractor must understand before its result is trusted over the llm
+const DefaultRuleConfidence = 0.9
+
+var (
+	// a size followed by its price: "100ml $150", "80/100 ml - $120.50", "10ml: $25-30"
+	offerRe = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(?:ml)?\s*(?:/\s*(\d+(?:\.\d+)?))?\s*ml\b[^$\n]{0,12}?\$\s*(\d{1,3}(?:,\d{3})+|\d+)(\.\d+)?(?:\s*-\s*\$?\d+)?`)
+
+	// lines that mention a price or a size, these are what we need to account for
+	candidateRe = regexp.MustCompile(`(?i)\$\s*\d|\d\s*ml\b`)
+
+	soldRe        = regexp.MustCompile(`(?i)\bsold\b|~~`)
+	spreadsheetRe = regexp.MustCompile(`(?i)spreadsheet|docs\.google\.com|airtable\.com|\.csv\b`)
+	// trade and buy posts list what they want too, which the rules can't tell apart from offers
+	wantsRe  = regexp.MustCompile(`(?i)\[(?:wtt|wtb|iso)\]|\bwts\s*/\s*wtt\b|\bISO\b|looking for|wish\s*list|\bin exchange\b`)
+	bulletRe = regexp.MustCompile(`^\s*(?:[*•+\-]|\d+[.)])\s+`)
+	letterRe = regexp.MustCompile(`\p{L}`)
+
+	// condition and box words, they describe every offer on the line and aren't part of the name
+	bnibRe      = regexp.MustCompile(`(?i)\b(?:bnib|nib|sealed)\b`)
+	testerRe    = regexp.MustCompile(`(?i)\btester\b`)
+	unboxedRe   = regexp.MustCompile(`(?i)\bunboxed\b|\bno box\b`)
+	usedRe      = regexp.MustCompile(`(?i)\bused\b|\bsprayed\b`)
+	conditionRe = regexp.MustCompile(`(?i)\s*\(?\b(?:bnib|nib|sealed|tester|unboxed|no box|used|sprayed)\b\)?`)
+
+	// same abbreviations the llm is told to expand
+	abbreviations = []struct {
+		re   *regexp.Regexp
+		full string
+	}{
+		{regexp.MustCompile(`(?i)\b(?:TF|T Ford)\b`), "Tom Ford"},
+		{regexp.MustCompile(`(?i)\bMFK\b`), "Maison Francis Kurkdjian"},
+		{regexp.MustCompile(`(?i)\bPdM\b`), "Parfums de Marly"},
+		{regexp.MustCompile(`(?i)\bBDC\b`), "Bleu de Chanel"},
+		{regexp.MustCompile(`(?i)\bADG\b`), "Armani Acqua di Gio"},
+		{regexp.MustCompile(`(?i)\bYSL\b`), "Yves Saint Laurent"},
+	}
+)
+
+const maxRuleNameLen = 80
+
+// RuleExtractor is a deterministic extractor for posts laid out one item per
+// line as "Name - size - $price". it is cheap and predictable, but it gives up
+// (low confidence) on anything it can't fully account for so the llm gets it.
+type RuleExtractor struct{}
+
+func NewRuleExtractor() *RuleExtractor {
+	return &RuleExtractor{}
+}
+
+// Extract returns the listing it found and a confidence in [0, 1], which is the
+// share of lines mentioning a size or price that it parsed cleanly.
+func (r *RuleExtractor) Extract(postContent string) (*models.FragranceListing, float64) {
+	// prices live somewhere else, only the llm knows how to fill "See Spreadsheet"
+	if spreadsheetRe.MatchString(postContent) {
+		return nil, 0
+	}
+	if wantsRe.MatchString(postContent) {
+		return nil, 0
+	}
+
+	listing := &models.FragranceListing{}
+	index := map[string]int{}
+	candidates, parsed := 0, 0
+
+	for _, line := range strings.Split(postContent, "\n") {
+		if !candidateRe.MatchString(line) {
+			continue
+		}
+		candidates++
+
+		// sold items are left out on purpose, that still counts as understood
+		if soldRe.MatchString(line) {
+			parsed++
+			continue
+		}
+
+		name, offers, ok := parseRuleLine(line)
+		if !ok {
+			continue
+		}
+		parsed++
+
+		key := strings.ToLower(name)
+		i, seen := index[key]
+		if !seen {
+			i = len(listing.Perfumes)
+			index[key] = i
+			listing.Perfumes = append(listing.Perfumes, models.Perfume{Name: name})
+		}
+		listing.Perfumes[i].Offers = append(listing.Perfumes[i].Offers, offers...)
+	}
+
+	if candidates == 0 || len(listing.Perfumes) == 0 {
+		return nil, 0
+	}
+	return listing, float64(parsed) / float64(candidates)
+}
+
+// ParsePostContent makes the rules usable on their own as a ListingExtractor,
+// returning whatever they found however confident they are
+func (r *RuleExtractor) ParsePostContent(ctx context.Context, postContent string) (*models.FragranceListing, error) {
+	listing, _ := r.Extract(postContent)
+	if listing == nil {
+		listing = &models.FragranceListing{}
+	}
+	listing.ExtractedBy = models.ExtractedByRules
+	return listing, nil
+}
+
+// splits one line into a name and its size/price offers
+func parseRuleLine(line string) (string, []models.Offer, bool) {
+	matches := offerRe.FindAllStringSubmatchIndex(line, -1)
+	if len(matches) == 0 {
+		return "", nil, false
+	}
+
+	name := cleanRuleName(line[:matches[0][0]])
+	if name == "" {
+		return "", nil, false
+	}
+
+	var offers []models.Offer
+	for _, m := range matches {
+		first := line[m[2]:m[3]]
+		size := first + "ml"
+		if m[4] >= 0 {
+			size = first + "/" + line[m[4]:m[5]] + "ml"
+		}
+		amount, err := strconv.Atoi(strings.ReplaceAll(line[m[6]:m[7]], ",", ""))
+		if err != nil || amount <= 0 {
+			return "", nil, false
+		}
+		price := "$" + strconv.Itoa(amount)
+		if m[8] >= 0 {
+			price += line[m[8]:m[9]]
+		}
+		offers = append(offers, models.Offer{Size: size, Price: price, Quantity: 1})
+	}
+	condition, box := ConditionOf(line)
+	for i := range offers {
+		offers[i].Condition, offers[i].Box = condition, box
+	}
+	return name, offers, true
+}
+
+// ConditionOf is the condition and box status a piece of text mentions, unknown
+// when it doesn't
+func ConditionOf(text string) (string, string) {
+	condition, box := models.ConditionUnknown, models.BoxUnknown
+	switch {
+	case bnibRe.MatchString(text):
+		condition, box = models.ConditionNew, models.BoxBoxed
+	case testerRe.MatchString(text):
+		condition = models.ConditionTester
+	case usedRe.MatchString(text):
+		condition = models.ConditionUsed
+	}
+	if unboxedRe.MatchString(text) {
+		box = models.BoxUnboxed
+	}
+	return condition, box
+}
+
+func cleanRuleName(s string) string {
+	s = bulletRe.ReplaceAllString(s, "")
+	s = conditionRe.ReplaceAllString(s, "")
+	s = strings.NewReplacer("**", "", "__", "", "`", "").Replace(s)
+	s = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s), "-–—:|,(@"))
+	if s == "" || len(s) > maxRuleNameLen || !letterRe.MatchString(s) {
+		return ""
+	}
+	for _, a := range abbreviations {
+		s = a.re.ReplaceAllString(s, a.full)
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
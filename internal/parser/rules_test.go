@@ -0,0 +1,27 @@
+package parser
+
+import "testing"
+
+func TestParseRuleLinePrices(t *testing.T) {
+	tests := []struct {
+		line  string
+		name  string
+		size  string
+		price string
+	}{
+		{"Creed Aventus 100ml $250", "Creed Aventus", "100ml", "$250"},
+		{"Xerjoff Naxos 80/100 ml - $150.50", "Xerjoff Naxos", "80/100ml", "$150.50"},
+		{"Baccarat Rouge 540 70ml $1,200.99", "Baccarat Rouge 540", "70ml", "$1200.99"},
+		{"Dior Homme Intense 10ml: $25-30", "Dior Homme Intense", "10ml", "$25"},
+	}
+	for _, tt := range tests {
+		name, offers, ok := parseRuleLine(tt.line)
+		if !ok || len(offers) != 1 {
+			t.Errorf("parseRuleLine(%q) = %q %+v %v, want one offer", tt.line, name, offers, ok)
+			continue
+		}
+		if name != tt.name || offers[0].Size != tt.size || offers[0].Price != tt.price {
+			t.Errorf("parseRuleLine(%q) = %q %s %s, want %q %s %s", tt.line, name, offers[0].Size, offers[0].Price, tt.name, tt.size, tt.price)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"frag-aggra/internal/models"
+	"regexp"
+	"strings"
+)
+
+// the output formats the prompt asks for
+const (
+	PriceTrade       = "Trade"
+	PriceSpreadsheet = "See Spreadsheet"
+)
+
+var (
+	// "100ml", "7.5ml", "80/100ml"
+	validSizeRe = regexp.MustCompile(`(?i)^\d+(?:\.\d+)?(?:/\d+(?:\.\d+)?)?ml$`)
+	// "$150", "$1,200", "$12.50"
+	validPriceRe = regexp.MustCompile(`^\$(?:\d{1,3}(?:,\d{3})+|\d+)(?:\.\d{1,2})?$`)
+)
+
+// ErrInvalidListing is wrapped by InvalidListingError
+var ErrInvalidListing = errors.New("parsed listing failed validation")
+
+// Problem is one way a parsed listing breaks the prompt's rules
+type Problem struct {
+	Field   string // e.g. perfumes[2].offers[0].price
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Field + ": " + p.Message
+}
+
+// InvalidListingError is returned when the llm's output still breaks the
+// rules after every repair attempt. Listing is the last attempt, kept so a
+// person can fix it instead of starting over.
+type InvalidListingError struct {
+	Listing  *models.FragranceListing
+	Problems []Problem
+	Attempts int
+}
+
+func (e *InvalidListingError) Error() string {
+	return fmt.Sprintf("%v after %d attempts: %s", ErrInvalidListing, e.Attempts, joinProblems(e.Problems))
+}
+
+func (e *InvalidListingError) Unwrap() error {
+	return ErrInvalidListing
+}
+
+// RepairError is returned when asking the llm to repair its output fails.
+// the calls before it were still paid for, Usage is what they used.
+type RepairError struct {
+	Err   error
+	Usage *models.ParseUsage
+}
+
+func (e *RepairError) Error() string {
+	return fmt.Sprintf("repair failed: %v", e.Err)
+}
+
+func (e *RepairError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks a parsed listing against the rules in the prompt: every
+// perfume has a name and at least one offer, sizes are "Xml" or "X/Yml",
+// prices are "$N" (or "Trade" / "See Spreadsheet"). nothing means it's fine.
+func Validate(listing *models.FragranceListing) []Problem {
+	var problems []Problem
+	add := func(field, format string, args ...any) {
+		problems = append(problems, Problem{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	if listing == nil {
+		add("listing", "is missing")
+		return problems
+	}
+
+	switch listing.Intent {
+	case "", models.IntentSell, models.IntentTrade, models.IntentBuy:
+	default:
+		add("intent", "%q is not sell, trade or buy", listing.Intent)
+	}
+
+	for i, p := range listing.Perfumes {
+		field := fmt.Sprintf("perfumes[%d]", i)
+		if strings.TrimSpace(p.Name) == "" {
+			add(field+".name", "is empty")
+		}
+		if len(p.Offers) == 0 {
+			add(field+".offers", "is empty, items without a size and price must be left out")
+		}
+		for j, o := range p.Offers {
+			offer := fmt.Sprintf("%s.offers[%d]", field, j)
+			if !validSizeRe.MatchString(o.Size) {
+				add(offer+".size", "%q is not in the 'Xml' or 'X/Yml' format", o.Size)
+			}
+			if !validPrice(o.Price) {
+				add(offer+".price", "%q must be '$' and a number only, %q or %q", o.Price, PriceTrade, PriceSpreadsheet)
+			}
+			switch o.Condition {
+			case "", models.ConditionNew, models.ConditionTester, models.ConditionUsed, models.ConditionUnknown:
+			default:
+				add(offer+".condition", "%q is not new, tester, used or unknown", o.Condition)
+			}
+			switch o.Box {
+			case "", models.BoxBoxed, models.BoxUnboxed, models.BoxDamaged, models.BoxUnknown:
+			default:
+				add(offer+".box", "%q is not boxed, unboxed, damaged or unknown", o.Box)
+			}
+			if o.FillPercent < 0 || o.FillPercent > 100 {
+				add(offer+".fill_percent", "%d is not between 0 and 100", o.FillPercent)
+			}
+			if o.Quantity < 0 {
+				add(offer+".quantity", "%d is negative", o.Quantity)
+			}
+		}
+	}
+
+	for i, w := range listing.Wants {
+		field := fmt.Sprintf("wants[%d]", i)
+		if strings.TrimSpace(w.Name) == "" {
+			add(field+".name", "is empty")
+		}
+		for j, size := range w.Sizes {
+			if !validSizeRe.MatchString(size) {
+				add(fmt.Sprintf("%s.sizes[%d]", field, j), "%q is not in the 'Xml' or 'X/Yml' format", size)
+			}
+		}
+		if w.MaxPrice != "" && !validPriceRe.MatchString(w.MaxPrice) {
+			add(field+".max_price", "%q must be '$' and a number only, or empty", w.MaxPrice)
+		}
+	}
+	return problems
+}
+
+func validPrice(price string) bool {
+	return price == PriceTrade || price == PriceSpreadsheet || validPriceRe.MatchString(price)
+}
+
+func joinProblems(problems []Problem) string {
+	lines := make([]string, len(problems))
+	for i, p := range problems {
+		lines[i] = p.String()
+	}
+	return strings.Join(lines, "; ")
+}
+
+// the follow up message asking the llm to fix its own output
+func repairMessage(problems []Problem) string {
+	var b strings.Builder
+	b.WriteString("Your previous JSON output breaks the extraction rules:\n")
+	for _, p := range problems {
+		fmt.Fprintf(&b, "- %s\n", p)
+	}
+	b.WriteString("\nRe-read the post and return the complete corrected JSON object. Fix only these problems, keep every other item as it was, and drop items that have no size or price.")
+	return b.String()
+}
@@ -3,6 +3,8 @@ package pubsub
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -41,19 +43,29 @@ func New(connStr string) (*RabbitMQClient, error) {
 	}, nil
 }
 
-// Closes the connection, defer this func when creating a new RabbitMQClient
+// Closes the channel and then the connection, defer this func when creating a new RabbitMQClient
 func (r *RabbitMQClient) Close() {
 	if r != nil && r.Channel != nil && r.Conn != nil {
-		r.Conn.Close()
-		r.Channel.Close()
+		if err := r.Channel.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+			log.Printf("failed to close rabbitmq channel: %v", err)
+		}
+		if err := r.Conn.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+			log.Printf("failed to close rabbitmq connection: %v", err)
+		}
 	}
 }
 
 func (r *RabbitMQClient) ConsumeFromClient(q string) (<-chan amqp.Delivery, error) {
+	return r.Consume(q, "")
+}
+
+// Consume is ConsumeFromClient under a known consumer tag, so the consumer
+// can be stopped with StopConsuming
+func (r *RabbitMQClient) Consume(q, tag string) (<-chan amqp.Delivery, error) {
 
 	msgs, err := r.Channel.Consume(
 		q,     // queue
-		"",    // consumer tag (empty for auto-generation)
+		tag,   // consumer tag (empty for auto-generation)
 		false, // auto-ack
 		false, // exclusive
 		false, // no-local
@@ -63,6 +75,19 @@ func (r *RabbitMQClient) ConsumeFromClient(q string) (<-chan amqp.Delivery, erro
 	return msgs, err
 }
 
+// SetPrefetch caps how many unacked messages the broker hands this channel's
+// consumers at once, 0 is unlimited
+func (r *RabbitMQClient) SetPrefetch(count int) error {
+	return r.Channel.Qos(count, 0, false)
+}
+
+// StopConsuming tells the broker to stop delivering to the consumer with this
+// tag. messages already delivered stay unacked until they are acked or nacked,
+// and the deliveries channel is closed once the broker confirms.
+func (r *RabbitMQClient) StopConsuming(tag string) error {
+	return r.Channel.Cancel(tag, false)
+}
+
 // Calling publish on this for the scraper service
 func (r *RabbitMQClient) Publish2JSON(exchange, key string, val any, ctx context.Context) error {
 	body, err := json.Marshal(val)
@@ -82,3 +107,38 @@ func (r *RabbitMQClient) Publish2JSON(exchange, key string, val any, ctx context
 		},
 	)
 }
+
+// Declares a durable direct exchange and queue and binds them with key.
+// safe to call from every service, declarations are idempotent
+func (r *RabbitMQClient) DeclareAndBind(exchange, queue, key string) (amqp.Queue, error) {
+	err := r.Channel.ExchangeDeclare(
+		exchange, //name
+		"direct", //type
+		true,     // durability
+		false,    // autoDelete
+		false,    // internal?
+		false,    //no wait
+		nil,
+	)
+	if err != nil {
+		return amqp.Queue{}, fmt.Errorf("failed to declare exchange %s: %w", exchange, err)
+	}
+
+	q, err := r.Channel.QueueDeclare(
+		queue, //queue name
+		true,  //durable
+		false, //delete when unused
+		false, //exclusive
+		false, //nowait
+		nil,
+	)
+	if err != nil {
+		return amqp.Queue{}, fmt.Errorf("failed to declare queue %s: %w", queue, err)
+	}
+
+	err = r.Channel.QueueBind(q.Name, key, exchange, false, nil)
+	if err != nil {
+		return amqp.Queue{}, fmt.Errorf("failed to bind queue %s to %s: %w", queue, exchange, err)
+	}
+	return q, nil
+}
@@ -0,0 +1,195 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"frag-aggra/internal/routing"
+	"log"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// headers carried on retried and dead-lettered messages
+const (
+	HeaderRetryCount = "x-retry-count"
+	HeaderLastError  = "x-last-error"
+	HeaderFailedAt   = "x-failed-at"
+)
+
+// RetryPolicy bounds how many times a failing message is retried and how long
+// it waits between attempts. the delay doubles every attempt up to MaxDelay.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+
+	// where the message goes back to once its delay is up
+	Exchange string
+	Key      string
+
+	RetryExchange    string
+	RetryKeyPrefix   string
+	RetryQueuePrefix string
+
+	DeadExchange string
+	DeadKey      string
+	DeadQueue    string
+}
+
+// PostRetryPolicy is the policy for the post queue the worker consumes from
+func PostRetryPolicy(maxRetries int, baseDelay time.Duration) RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:       maxRetries,
+		BaseDelay:        baseDelay,
+		MaxDelay:         time.Hour,
+		Exchange:         routing.ExchangePostDirect,
+		Key:              routing.PostKey,
+		RetryExchange:    routing.ExchangePostRetry,
+		RetryKeyPrefix:   routing.PostRetryKeyPrefix,
+		RetryQueuePrefix: routing.PostRetryQueuePrefix,
+		DeadExchange:     routing.ExchangePostDead,
+		DeadKey:          routing.PostDeadKey,
+		DeadQueue:        routing.PostDeadQueue,
+	}
+}
+
+// Delay returns how long to wait before the given attempt (1 based)
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return d
+}
+
+// the delay queues are named and routed by their delay rather than the
+// attempt. a queue's ttl can't change once it's declared, so a new policy gets
+// new queues instead of a PRECONDITION_FAILED, and the old ones drain on their own.
+func (p RetryPolicy) retryKey(attempt int) string {
+	return fmt.Sprintf("%s.%dms", p.RetryKeyPrefix, p.Delay(attempt).Milliseconds())
+}
+
+func (p RetryPolicy) retryQueue(attempt int) string {
+	return fmt.Sprintf("%s.%dms", p.RetryQueuePrefix, p.Delay(attempt).Milliseconds())
+}
+
+// DeclareRetryTopology declares the retry exchange with one delay queue per
+// distinct delay and the dead-letter exchange + queue. each delay queue has a
+// fixed ttl and dead-letters expired messages back to the policy's
+// exchange/key, which is what gives us the delayed requeue without a plugin.
+func (r *RabbitMQClient) DeclareRetryTopology(p RetryPolicy) error {
+	for _, exchange := range []string{p.RetryExchange, p.DeadExchange} {
+		err := r.Channel.ExchangeDeclare(exchange, "direct", true, false, false, false, nil)
+		if err != nil {
+			return fmt.Errorf("failed to declare exchange %s: %w", exchange, err)
+		}
+	}
+
+	declared := map[string]bool{}
+	for attempt := 1; attempt <= p.MaxRetries; attempt++ {
+		queue := p.retryQueue(attempt)
+		// attempts past MaxDelay all share its queue
+		if declared[queue] {
+			continue
+		}
+		declared[queue] = true
+		_, err := r.Channel.QueueDeclare(queue, true, false, false, false, amqp.Table{
+			"x-message-ttl":             p.Delay(attempt).Milliseconds(),
+			"x-dead-letter-exchange":    p.Exchange,
+			"x-dead-letter-routing-key": p.Key,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to declare retry queue %s: %w", queue, err)
+		}
+		if err := r.Channel.QueueBind(queue, p.retryKey(attempt), p.RetryExchange, false, nil); err != nil {
+			return fmt.Errorf("failed to bind retry queue %s: %w", queue, err)
+		}
+	}
+
+	_, err := r.Channel.QueueDeclare(p.DeadQueue, true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue %s: %w", p.DeadQueue, err)
+	}
+	if err := r.Channel.QueueBind(p.DeadQueue, p.DeadKey, p.DeadExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue %s: %w", p.DeadQueue, err)
+	}
+	return nil
+}
+
+// Retry acks msg and republishes it to the next delay queue, or to the
+// dead-letter queue once it has used up MaxRetries. returns true if it was dead-lettered.
+func (r *RabbitMQClient) Retry(ctx context.Context, p RetryPolicy, msg amqp.Delivery, cause error) (bool, error) {
+	attempt := RetryCount(msg) + 1
+	if attempt > p.MaxRetries {
+		return true, r.DeadLetter(ctx, p, msg, cause)
+	}
+
+	headers := failureHeaders(msg, cause)
+	headers[HeaderRetryCount] = int32(attempt)
+	if err := r.republish(ctx, p.RetryExchange, p.retryKey(attempt), msg, headers); err != nil {
+		return false, err
+	}
+	log.Printf("retrying message in %s (retry %d/%d): %v", p.Delay(attempt), attempt, p.MaxRetries, cause)
+	return false, msg.Ack(false)
+}
+
+// DeadLetter acks msg and moves it to the dead-letter queue with the failure reason
+func (r *RabbitMQClient) DeadLetter(ctx context.Context, p RetryPolicy, msg amqp.Delivery, cause error) error {
+	if err := r.republish(ctx, p.DeadExchange, p.DeadKey, msg, failureHeaders(msg, cause)); err != nil {
+		return err
+	}
+	log.Printf("moved message to %s after %d retries: %v", p.DeadQueue, RetryCount(msg), cause)
+	return msg.Ack(false)
+}
+
+// RetryCount reads how many times msg has been retried already
+func RetryCount(msg amqp.Delivery) int {
+	switch v := msg.Headers[HeaderRetryCount].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
+
+func failureHeaders(msg amqp.Delivery, cause error) amqp.Table {
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	if cause != nil {
+		headers[HeaderLastError] = cause.Error()
+	}
+	headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339)
+	return headers
+}
+
+func (r *RabbitMQClient) republish(ctx context.Context, exchange, key string, msg amqp.Delivery, headers amqp.Table) error {
+	err := r.Channel.PublishWithContext(ctx, exchange, key, false, false, amqp.Publishing{
+		Headers:      headers,
+		DeliveryMode: amqp.Persistent,
+		ContentType:  msg.ContentType,
+		Body:         msg.Body,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to republish to %s/%s: %w", exchange, key, err)
+	}
+	return nil
+}
+
+// Replay acks a dead-lettered msg and publishes it back to the policy's
+// exchange with its retry count reset, so it gets the full set of retries again
+func (r *RabbitMQClient) Replay(ctx context.Context, p RetryPolicy, msg amqp.Delivery) error {
+	if err := r.republish(ctx, p.Exchange, p.Key, msg, nil); err != nil {
+		return err
+	}
+	return msg.Ack(false)
+}
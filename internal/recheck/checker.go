@@ -0,0 +1,140 @@
+package recheck
+
+import (
+	"context"
+	"fmt"
+	"frag-aggra/internal/catalog"
+	"frag-aggra/internal/database"
+	"frag-aggra/internal/models"
+	"frag-aggra/internal/parser"
+	"frag-aggra/internal/pubsub"
+	"frag-aggra/internal/routing"
+	"frag-aggra/internal/scraper"
+	"log"
+	"time"
+)
+
+// Checker re-fetches recent posts, and re-parses the ones whose text changed
+// so sold and removed items get their listing status updated
+type Checker struct {
+	repo      *database.Repository
+	reddit    *scraper.RedditScraper
+	extractor parser.ListingExtractor
+
+	// optional, resolves newly found items against the catalog
+	Linker *catalog.Linker
+
+	// optional, told about posts that gained items so alerts match them too
+	Events *pubsub.RabbitMQClient
+
+	// how far back to look and how long to wait before checking a post again
+	Window   time.Duration
+	MinAge   time.Duration
+	MaxBatch int
+}
+
+func NewChecker(repo *database.Repository, reddit *scraper.RedditScraper, extractor parser.ListingExtractor) *Checker {
+	return &Checker{
+		repo:      repo,
+		reddit:    reddit,
+		extractor: extractor,
+		Window:    7 * 24 * time.Hour,
+		MinAge:    time.Hour,
+		MaxBatch:  200,
+	}
+}
+
+// RunOnce checks one batch of due posts, returning how many were re-parsed
+func (c *Checker) RunOnce(ctx context.Context) (int, error) {
+	now := time.Now()
+	candidates, err := c.repo.PostsForRecheck(ctx, now.Add(-c.Window), now.Add(-c.MinAge), c.MaxBatch)
+	if err != nil {
+		return 0, err
+	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]string, len(candidates))
+	for i, cand := range candidates {
+		ids[i] = cand.RedditID
+	}
+	fetched, err := c.reddit.FetchPostsByID(ctx, ids)
+	if err != nil {
+		return 0, fmt.Errorf("failed to re-fetch posts: %w", err)
+	}
+	byID := make(map[string]models.Post, len(fetched))
+	for _, post := range fetched {
+		byID[post.PostID] = post
+	}
+
+	reparsed := 0
+	for _, cand := range candidates {
+		if ctx.Err() != nil {
+			return reparsed, ctx.Err()
+		}
+
+		post, ok := byID[cand.RedditID]
+		if !ok {
+			// reddit didn't return it at all, treat it like a deleted post
+			post = models.Post{PostID: cand.RedditID, Body: "[deleted]"}
+		}
+
+		changed, err := c.checkPost(ctx, cand, post)
+		if err != nil {
+			log.Printf("recheck of post %s failed: %v", cand.RedditID, err)
+			continue
+		}
+		if changed {
+			reparsed++
+		}
+	}
+	return reparsed, nil
+}
+
+func (c *Checker) checkPost(ctx context.Context, cand database.RecheckCandidate, post models.Post) (bool, error) {
+	hash := post.ContentHash()
+	gone := isGone(post)
+
+	// posts stored before hashing existed get a baseline instead of a paid
+	// re-parse, unless they're gone, which doesn't need a parse to tell
+	if cand.BodyHash == hash || (cand.BodyHash == "" && !gone) {
+		return false, c.repo.MarkPostChecked(ctx, cand.ID, hash)
+	}
+
+	stored, err := c.repo.GetPost(ctx, cand.RedditID)
+	if err != nil {
+		return false, err
+	}
+
+	var fresh *models.FragranceListing
+	if !gone {
+		fresh, err = parser.ParsePost(ctx, c.extractor, post)
+		if err != nil {
+			return false, fmt.Errorf("failed to re-parse: %w", err)
+		}
+	}
+
+	diff := Diff(stored.Listings, fresh, post)
+	// same fallback as the worker when the parse didn't decide
+	if diff.Added.Intent == "" && stored.TradeType != nil {
+		diff.Added.Intent = *stored.TradeType
+	}
+	if c.Linker != nil {
+		c.Linker.Link(ctx, &diff.Added)
+	}
+	if err := c.repo.ApplyListingDiff(ctx, cand.ID, hash, diff); err != nil {
+		return false, err
+	}
+	log.Printf("post %s changed: %d new items, %d status changes, %d price changes",
+		cand.RedditID, len(diff.Added.Perfumes), len(diff.Status), len(diff.Repriced))
+
+	// the matcher only alerts on listings it hasn't already, so the whole post can go
+	if c.Events != nil && len(diff.Added.Perfumes) > 0 {
+		event := models.ListingEvent{RedditID: cand.RedditID}
+		if err := c.Events.Publish2JSON(routing.ExchangePostDirect, routing.ListingKey, event, ctx); err != nil {
+			log.Printf("failed to publish listing event for post %s: %v", cand.RedditID, err)
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,171 @@
+package recheck
+
+import (
+	"frag-aggra/internal/models"
+	"frag-aggra/internal/parser"
+	"math"
+	"regexp"
+	"strings"
+)
+
+var (
+	soldLineRe = regexp.MustCompile(`(?i)\bsold\b|~~`)
+	allSoldRe  = regexp.MustCompile(`(?i)\[sold\]|\ball (?:items )?(?:are )?sold\b|\beverything(?: is)? sold\b`)
+	wordRe     = regexp.MustCompile(`[\p{L}\p{N}]+`)
+)
+
+// reddit replaces the body with one of these when a post goes away
+var goneBodies = map[string]bool{"[removed]": true, "[deleted]": true}
+
+// Diff compares the stored listings of a post against a fresh parse of its
+// edited text. the parser leaves sold items out, so anything stored that is
+// missing from the fresh parse is marked sold if the new text strikes it
+// through or calls it sold, and removed otherwise.
+func Diff(stored []models.Listing, fresh *models.FragranceListing, post models.Post) models.ListingDiff {
+	diff := models.ListingDiff{
+		Status:   map[int64]string{},
+		Repriced: map[int64]string{},
+	}
+	if fresh == nil {
+		fresh = &models.FragranceListing{}
+	}
+	diff.Added.ExtractedBy = fresh.ExtractedBy
+	diff.Added.Intent = fresh.Intent
+	diff.Added.PromptVersion = fresh.PromptVersion
+
+	// index the fresh parse by name + size
+	freshPrices := map[string]string{}
+	for _, perfume := range fresh.Perfumes {
+		for _, offer := range perfume.Offers {
+			freshPrices[key(perfume.Name, offer.Size)] = offer.Price
+		}
+	}
+
+	gone := isGone(post)
+	allSold := allSoldRe.MatchString(post.Title) || allSoldRe.MatchString(post.Body)
+	soldLines := soldLines(post.Body)
+
+	seen := map[string]bool{}
+	fromSheet := map[string]bool{}
+	for _, l := range stored {
+		k := key(l.Name, l.Size)
+		seen[k] = true
+
+		// rows read from a linked price list aren't in the text, the sheet
+		// ingester looks after them
+		if l.ExtractedBy != nil && *l.ExtractedBy == models.ExtractedBySheet && !gone {
+			fromSheet[key(l.Name, "")] = true
+			continue
+		}
+
+		price, found := freshPrices[k]
+		if found {
+			if l.Status != models.ListingAvailable {
+				// came back, e.g. a sale fell through
+				diff.Status[l.ID] = models.ListingAvailable
+			}
+			if price != l.Price {
+				diff.Repriced[l.ID] = price
+			}
+			continue
+		}
+
+		if l.Status != models.ListingAvailable {
+			continue
+		}
+		switch {
+		case gone:
+			diff.Status[l.ID] = models.ListingRemoved
+		case allSold || mentionedIn(l.Name, stored, soldLines):
+			diff.Status[l.ID] = models.ListingSold
+		default:
+			diff.Status[l.ID] = models.ListingRemoved
+		}
+	}
+
+	for _, perfume := range fresh.Perfumes {
+		added := models.Perfume{Name: perfume.Name}
+		for _, offer := range perfume.Offers {
+			if seen[key(perfume.Name, offer.Size)] {
+				continue
+			}
+			if offer.Price == parser.PriceSpreadsheet && fromSheet[key(perfume.Name, "")] {
+				continue
+			}
+			added.Offers = append(added.Offers, offer)
+		}
+		if len(added.Offers) > 0 {
+			diff.Added.Perfumes = append(diff.Added.Perfumes, added)
+		}
+	}
+	return diff
+}
+
+func isGone(post models.Post) bool {
+	return goneBodies[strings.TrimSpace(post.Body)]
+}
+
+func key(name, size string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " ")) + "|" + strings.ToLower(strings.ReplaceAll(size, " ", ""))
+}
+
+func soldLines(body string) []map[string]bool {
+	var lines []map[string]bool
+	for _, line := range strings.Split(body, "\n") {
+		if soldLineRe.MatchString(line) {
+			lines = append(lines, words(line))
+		}
+	}
+	return lines
+}
+
+// mentionedIn reports whether a sold line names this perfume. the seller
+// usually abbreviates the brand, so only the words that set this name apart
+// from the other perfumes in the post are looked for, and at least a third of
+// them have to show up.
+func mentionedIn(name string, stored []models.Listing, lines []map[string]bool) bool {
+	if len(lines) == 0 {
+		return false
+	}
+
+	shared := map[string]int{}
+	for _, l := range stored {
+		if strings.EqualFold(l.Name, name) {
+			continue
+		}
+		for w := range words(l.Name) {
+			shared[w]++
+		}
+	}
+	var distinct []string
+	for w := range words(name) {
+		if len(w) >= 3 && shared[w] == 0 {
+			distinct = append(distinct, w)
+		}
+	}
+	if len(distinct) == 0 {
+		return false
+	}
+
+	need := int(math.Ceil(float64(len(distinct)) / 3))
+	for _, line := range lines {
+		hits := 0
+		for _, w := range distinct {
+			if line[w] {
+				hits++
+			}
+		}
+		if hits >= need {
+			return true
+		}
+	}
+	return false
+}
+
+func words(s string) map[string]bool {
+	out := map[string]bool{}
+	for _, w := range wordRe.FindAllString(strings.ToLower(s), -1) {
+		out[w] = true
+	}
+	return out
+}
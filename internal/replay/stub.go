@@ -0,0 +1,57 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// SeededID is the completion id of responses written by OpenAIStub, so seeded
+// recordings can be told apart from hand-written and recorded ones
+const SeededID = "chatcmpl-seeded"
+
+// OpenAIStub answers every chat completion with content, for seeding
+// recordings from a fixture's expected listing instead of a live call.
+func OpenAIStub(content string) http.RoundTripper {
+	return stubTransport(func(req *http.Request) (*http.Response, error) {
+		var sent struct {
+			Model string `json:"model"`
+		}
+		if req.Body != nil {
+			_ = json.NewDecoder(req.Body).Decode(&sent)
+		}
+		body, err := json.Marshal(map[string]any{
+			"id":      SeededID,
+			"object":  "chat.completion",
+			"created": 0,
+			"model":   sent.Model,
+			"choices": []map[string]any{{
+				"index":         0,
+				"message":       map[string]any{"role": "assistant", "content": content},
+				"finish_reason": "stop",
+			}},
+			"usage": map[string]int{"prompt_tokens": 0, "completion_tokens": 0, "total_tokens": 0},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": []string{"application/json"}},
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	})
+}
+
+type stubTransport func(*http.Request) (*http.Response, error)
+
+func (f stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
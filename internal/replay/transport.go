@@ -0,0 +1,177 @@
+package replay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Mode is what a Transport does with a request
+type Mode int
+
+const (
+	// Replay serves recordings and fails requests that have none
+	Replay Mode = iota
+	// Record sends requests upstream and saves the responses
+	Record
+)
+
+// ErrNoRecording is returned in Replay mode for a request that was never recorded
+var ErrNoRecording = errors.New("no recording for request")
+
+// Recording is one request/response pair as stored on disk
+type Recording struct {
+	Request struct {
+		Method string          `json:"method"`
+		Path   string          `json:"path"`
+		Body   json.RawMessage `json:"body"`
+	} `json:"request"`
+	Response struct {
+		Status      int             `json:"status"`
+		ContentType string          `json:"content_type"`
+		Body        json.RawMessage `json:"body"`
+	} `json:"response"`
+}
+
+// Transport is an http.RoundTripper that serves llm responses from Dir, one
+// file per request named after the hash of its method, path and body. the
+// prompt, schema, model and post text are all in the body, so changing any of
+// them needs a new recording. headers (and so api keys) are never stored.
+type Transport struct {
+	Dir  string
+	Mode Mode
+	Next http.RoundTripper // upstream for Record, http.DefaultTransport if nil
+}
+
+// Client is an http.Client using the transport
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	path := t.path(Key(req.Method, req.URL.Path, body))
+
+	if t.Mode == Replay {
+		rec, err := Load(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s %s (%s)", ErrNoRecording, req.Method, req.URL.Path, filepath.Base(path))
+		}
+		if err != nil {
+			return nil, err
+		}
+		return rec.response(req), nil
+	}
+
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	// only keep what a replay can use, errors are worth recording too
+	var rec Recording
+	rec.Request.Method, rec.Request.Path, rec.Request.Body = req.Method, req.URL.Path, rawJSON(body)
+	rec.Response.Status, rec.Response.ContentType = resp.StatusCode, resp.Header.Get("Content-Type")
+	rec.Response.Body = rawJSON(respBody)
+	if err := Save(path, rec); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (t *Transport) path(key string) string {
+	return filepath.Join(t.Dir, key+".json")
+}
+
+// Key names the recording of a request
+func Key(method, path string, body []byte) string {
+	sum := sha256.Sum256([]byte(method + " " + path + "\n" + string(body)))
+	return hex.EncodeToString(sum[:12])
+}
+
+// Load reads a recording
+func Load(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rec Recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("failed to decode recording %s: %w", path, err)
+	}
+	return &rec, nil
+}
+
+// Save writes a recording, creating the directory if needed
+func Save(path string, rec Recording) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create recording dir: %w", err)
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write recording %s: %w", path, err)
+	}
+	return nil
+}
+
+func (rec *Recording) response(req *http.Request) *http.Response {
+	body := []byte(rec.Response.Body)
+	// non-json bodies are stored as a json string
+	var s string
+	if json.Unmarshal(body, &s) == nil {
+		body = []byte(s)
+	}
+	header := http.Header{}
+	if rec.Response.ContentType != "" {
+		header.Set("Content-Type", rec.Response.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.Response.Status, http.StatusText(rec.Response.Status)),
+		StatusCode:    rec.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// json bodies are stored as is so recordings stay readable, anything else as a string
+func rawJSON(body []byte) json.RawMessage {
+	if json.Valid(body) {
+		return body
+	}
+	s, _ := json.Marshal(string(body))
+	return s
+}
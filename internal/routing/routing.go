@@ -3,9 +3,24 @@ package routing
 const (
 	ExchangePostDirect = "post_exchange"
 	ExchangePostTopic  = "post_topic"
+	ExchangePostRetry  = "post_retry_exchange"
+	ExchangePostDead   = "post_dead_letter_exchange"
 )
 
 const (
 	PostKey   = "post_new"
 	PostQueue = "post_queue"
+
+	// one delay queue per delay, suffixed with it in ms (post_retry_queue.30000ms, ...)
+	PostRetryKeyPrefix   = "post_retry"
+	PostRetryQueuePrefix = "post_retry_queue"
+
+	PostDeadKey   = "post_dead"
+	PostDeadQueue = "post_dead_queue"
+)
+
+const (
+	// ListingEvent published once a post's listings are committed, for the alert matcher
+	ListingKey        = "listing_new"
+	ListingAlertQueue = "listing_alert_queue"
 )
@@ -0,0 +1,89 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Checkpoint is the high-water mark of reddit post ids the scraper has already
+// published. reddit ids are base36 and increase monotonically, so anything at or
+// below the mark was seen in an earlier poll and can be skipped.
+type Checkpoint struct {
+	path   string
+	LastID string `json:"last_id"`
+	last   int64
+}
+
+// LoadCheckpoint reads the checkpoint at path, a missing file starts from zero
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	c := &Checkpoint{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint %s: %w", path, err)
+	}
+	if c.LastID != "" {
+		c.last, err = parseRedditID(c.LastID)
+		if err != nil {
+			return nil, fmt.Errorf("bad last_id in checkpoint %s: %w", path, err)
+		}
+	}
+	return c, nil
+}
+
+// Seen reports whether id is at or below the high-water mark.
+// ids that can't be decoded are treated as unseen so they still get published
+func (c *Checkpoint) Seen(id string) bool {
+	n, err := parseRedditID(id)
+	if err != nil {
+		return false
+	}
+	return n <= c.last
+}
+
+// Advance moves the mark forward to id if it is newer, returns true if it moved
+func (c *Checkpoint) Advance(id string) bool {
+	n, err := parseRedditID(id)
+	if err != nil || n <= c.last {
+		return false
+	}
+	c.last = n
+	c.LastID = id
+	return true
+}
+
+// Save writes the checkpoint atomically via a temp file + rename
+func (c *Checkpoint) Save() error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(c.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create checkpoint dir: %w", err)
+		}
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// strips the optional t3_ fullname prefix and decodes base36
+func parseRedditID(id string) (int64, error) {
+	if len(id) > 3 && id[2] == '_' {
+		id = id[3:]
+	}
+	return strconv.ParseInt(id, 36, 64)
+}
@@ -0,0 +1,27 @@
+package scraper
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// "Trades: 42", "42 confirmed trades", "Swaps | 12", "Trade Count: 7"
+var confirmedTradesRe = regexp.MustCompile(`(?i)(\d+)\s*(?:confirmed\s+)?(?:trades?|swaps?)\b|\b(?:trades?|swaps?)(?:\s+count)?\s*[:|#-]?\s*(\d+)`)
+
+// ConfirmedTrades pulls the confirmed trade count out of a user flair, ok is
+// false when the flair doesn't carry one
+func ConfirmedTrades(flair string) (int, bool) {
+	m := confirmedTradesRe.FindStringSubmatch(flair)
+	if m == nil {
+		return 0, false
+	}
+	digits := m[1]
+	if digits == "" {
+		digits = m[2]
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,114 @@
+package scraper
+
+import (
+	"frag-aggra/internal/images"
+	"math"
+	"sort"
+	"strings"
+)
+
+// the parts of a reddit post that point at pictures
+type media struct {
+	PostHint            string `json:"post_hint"`
+	URLOverriddenByDest string `json:"url_overridden_by_dest"`
+
+	// galleries list their pictures in order in gallery_data and describe
+	// them in media_metadata, which also has the pictures inlined in a text post
+	GalleryData *struct {
+		Items []struct {
+			MediaID string `json:"media_id"`
+		} `json:"items"`
+	} `json:"gallery_data"`
+	MediaMetadata map[string]mediaMetadata `json:"media_metadata"`
+
+	Preview *struct {
+		Images []struct {
+			Source struct {
+				URL string `json:"url"`
+			} `json:"source"`
+		} `json:"images"`
+	} `json:"preview"`
+}
+
+type mediaMetadata struct {
+	Status string `json:"status"` // "valid" once reddit has processed the upload
+	Kind   string `json:"e"`      // "Image", "AnimatedImage", "RedditVideo"
+	Source struct {
+		URL string `json:"u"`
+	} `json:"s"`
+}
+
+// imagesOf lists a post's pictures, each once: the gallery in order, pictures
+// inlined in the text, the post's own link when it is a picture, and picture
+// links in the body. the preview is only used for image posts that had none of
+// those, for link posts it's a thumbnail of the page.
+func imagesOf(post rawPost) []string {
+	var out []string
+	seen := map[string]bool{}
+	add := func(link string) {
+		// the same picture turns up on i.redd.it and preview.redd.it, with and without a query
+		key := images.FileName(link)
+		if link != "" && !seen[key] {
+			seen[key] = true
+			out = append(out, link)
+		}
+	}
+
+	inGallery := map[string]bool{}
+	if post.GalleryData != nil {
+		for _, item := range post.GalleryData.Items {
+			inGallery[item.MediaID] = true
+			add(post.MediaMetadata[item.MediaID].url())
+		}
+	}
+	// inlined pictures in the order they appear in the text, the ids are random
+	inline := make([]string, 0, len(post.MediaMetadata))
+	for id := range post.MediaMetadata {
+		if !inGallery[id] {
+			inline = append(inline, id)
+		}
+	}
+	sort.Slice(inline, func(i, j int) bool {
+		a, b := bodyIndex(post.Body, inline[i]), bodyIndex(post.Body, inline[j])
+		if a != b {
+			return a < b
+		}
+		return inline[i] < inline[j]
+	})
+	for _, id := range inline {
+		add(post.MediaMetadata[id].url())
+	}
+
+	link := post.URLOverriddenByDest
+	if link == "" {
+		link = post.URL
+	}
+	for _, l := range images.FindLinks(link) {
+		add(l)
+	}
+	for _, l := range images.FindLinks(post.Body) {
+		add(l)
+	}
+
+	if len(out) == 0 && post.PostHint == "image" && post.Preview != nil {
+		for _, img := range post.Preview.Images {
+			add(img.Source.URL)
+		}
+	}
+	return out
+}
+
+func (m mediaMetadata) url() string {
+	if m.Status != "valid" || m.Kind != "Image" {
+		return ""
+	}
+	return m.Source.URL
+}
+
+// where a picture's media id shows up in the text, pictures not mentioned go last
+func bodyIndex(body, id string) int {
+	if i := strings.Index(body, id); i >= 0 {
+		return i
+	}
+	return math.MaxInt
+}
@@ -0,0 +1,76 @@
+package scraper
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestImagesOf(t *testing.T) {
+	tests := []struct {
+		name string
+		post string
+		want []string
+	}{
+		{
+			name: "gallery in order, then inline by position in the text, then links",
+			post: `{
+				"selftext": "second ![img](inl2) first ![img](inl1) https://i.imgur.com/Xq7LmPa.jpg",
+				"url": "https://www.reddit.com/gallery/1abcde",
+				"gallery_data": {"items": [{"media_id": "gal2"}, {"media_id": "gal1"}]},
+				"media_metadata": {
+					"gal1": {"status": "valid", "e": "Image", "s": {"u": "https://preview.redd.it/gal1.jpg?width=1080"}},
+					"gal2": {"status": "valid", "e": "Image", "s": {"u": "https://preview.redd.it/gal2.jpg?width=1080"}},
+					"inl1": {"status": "valid", "e": "Image", "s": {"u": "https://preview.redd.it/inl1.png?width=640"}},
+					"inl2": {"status": "valid", "e": "Image", "s": {"u": "https://preview.redd.it/inl2.png?width=640"}},
+					"vid1": {"status": "valid", "e": "RedditVideo"},
+					"new1": {"status": "unprocessed", "e": "Image"}
+				}
+			}`,
+			want: []string{
+				"https://preview.redd.it/gal2.jpg?width=1080",
+				"https://preview.redd.it/gal1.jpg?width=1080",
+				"https://preview.redd.it/inl2.png?width=640",
+				"https://preview.redd.it/inl1.png?width=640",
+				"https://i.imgur.com/Xq7LmPa.jpg",
+			},
+		},
+		{
+			name: "image post's link before the body, the same picture once",
+			post: `{
+				"selftext": "also here https://preview.redd.it/q8v2x1ab3c.png?width=640 and https://imgur.com/a/Hk3Pq9s",
+				"url": "https://i.redd.it/q8v2x1ab3c.png",
+				"post_hint": "image",
+				"preview": {"images": [{"source": {"url": "https://preview.redd.it/q8v2x1ab3c.png?auto=webp"}}]}
+			}`,
+			want: []string{"https://i.redd.it/q8v2x1ab3c.png", "https://imgur.com/a/Hk3Pq9s"},
+		},
+		{
+			name: "preview of an image post with nothing else",
+			post: `{
+				"url": "https://www.reddit.com/r/fragranceswap/comments/1abcde/",
+				"post_hint": "image",
+				"preview": {"images": [{"source": {"url": "https://preview.redd.it/only1.jpg?auto=webp"}}]}
+			}`,
+			want: []string{"https://preview.redd.it/only1.jpg?auto=webp"},
+		},
+		{
+			name: "no preview for link posts",
+			post: `{
+				"url": "https://www.fragrantica.com/perfume/Creed/Aventus-9828.html",
+				"post_hint": "link",
+				"preview": {"images": [{"source": {"url": "https://external-preview.redd.it/page.jpg"}}]}
+			}`,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		var post rawPost
+		if err := json.Unmarshal([]byte(tt.post), &post); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := imagesOf(post); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: imagesOf = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
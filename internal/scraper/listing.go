@@ -0,0 +1,59 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/vartanbeno/go-reddit/v2/reddit"
+)
+
+// go-reddit's Post leaves out the author's flair, which is where the swap subs
+// keep a user's confirmed trade count, and the post's pictures
+type rawPost struct {
+	reddit.Post
+	AuthorFlairText string `json:"author_flair_text"`
+	media
+}
+
+type rawListing struct {
+	Data struct {
+		Children []struct {
+			Data rawPost `json:"data"`
+		} `json:"children"`
+	} `json:"data"`
+}
+
+// newPosts is Subreddit.NewPosts, but keeping each author's flair text and the post's media
+func (r *RedditScraper) newPosts(ctx context.Context, subreddit string, limit int) ([]rawPost, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	return r.listing(ctx, fmt.Sprintf("r/%s/new", subreddit), query)
+}
+
+// postsByID is Listings.GetPosts, same as newPosts. ids carry the t3_ prefix.
+func (r *RedditScraper) postsByID(ctx context.Context, fullIDs []string) ([]rawPost, error) {
+	return r.listing(ctx, "by_id/"+strings.Join(fullIDs, ","), url.Values{})
+}
+
+func (r *RedditScraper) listing(ctx context.Context, path string, query url.Values) ([]rawPost, error) {
+	// unescaped bodies and media urls
+	query.Set("raw_json", "1")
+	req, err := r.client.NewRequest(http.MethodGet, path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var listing rawListing
+	if _, err := r.client.Do(ctx, req, &listing); err != nil {
+		return nil, err
+	}
+	posts := make([]rawPost, 0, len(listing.Data.Children))
+	for _, child := range listing.Data.Children {
+		posts = append(posts, child.Data)
+	}
+	return posts, nil
+}
@@ -5,13 +5,10 @@ import (
 	"frag-aggra/internal/models"
 	"log"
 	"os"
-	"regexp"
 
 	"github.com/vartanbeno/go-reddit/v2/reddit"
 )
 
-var wtsRe = regexp.MustCompile(`(?i)\[wts\]`)
-
 type RedditScraper struct {
 	client *reddit.Client
 }
@@ -35,39 +32,33 @@ func New() (*RedditScraper, error) {
 	}, nil
 }
 
-func (r *RedditScraper) FetchPost(subreddit string, limit int) ([]models.Post, error) {
+// FetchPost grabs the newest posts of a source and keeps the ones its tags match,
+// stamped with the source name and trade type
+func (r *RedditScraper) FetchPost(ctx context.Context, src Source) ([]models.Post, error) {
 
-	if limit <= 0 {
-		limit = 5
-	}
-
-	posts, _, err := r.client.Subreddit.NewPosts(context.Background(), subreddit, &reddit.ListOptions{
-		Limit: limit,
-	})
+	posts, err := r.newPosts(ctx, src.Subreddit, src.FetchLimit)
 
 	if err != nil {
 		return nil, err
 	}
 
-	log.Print("Grabbing ", limit, " posts")
+	log.Printf("Grabbing %d posts from r/%s", src.FetchLimit, src.Subreddit)
 	var job_postings []models.Post
 	for _, post := range posts {
 
-		// only include posts that contain [WTS] (case-insensitive) in title or body
-		if !r.ContainsWTS(post.Title) && !r.ContainsWTS(post.Body) {
-			log.Printf("Skipping post %s without [WTS] in title or body", post.ID)
+		// only include posts tagged with one of the source's tags in title or body
+		tradeType, ok := src.Classify(post.Title, post.Body)
+		if !ok {
+			log.Printf("Skipping post %s without a %s tag in title or body", post.ID, src.Name)
 			continue
 		}
-		//grab post_id first
-
-		job_posting := models.Post{
-			PostID:         post.ID,
-			URL:            post.URL,
-			Title:          post.Title,
-			Body:           post.Body,
-			SellerUsername: post.Author,
+		job_posting := toModel(post)
+		job_posting.SellerFlair = post.AuthorFlairText
+		if trades, ok := ConfirmedTrades(post.AuthorFlairText); ok {
+			job_posting.SellerTrades = &trades
 		}
-
+		job_posting.Source = src.Name
+		job_posting.TradeType = tradeType
 		job_postings = append(job_postings, job_posting)
 	}
 
@@ -88,6 +79,39 @@ func (r *RedditScraper) FetchPaginatedPosts(ctx context.Context, subreddit strin
 
 }
 
-func (r *RedditScraper) ContainsWTS(s string) bool {
-	return wtsRe.MatchString(s)
+// reddit caps by_id lookups at 100 ids per request
+const maxIDsPerLookup = 100
+
+// FetchPostsByID re-fetches posts by their short reddit id (no t3_ prefix).
+// posts reddit no longer returns are simply missing from the result.
+func (r *RedditScraper) FetchPostsByID(ctx context.Context, ids []string) ([]models.Post, error) {
+	var out []models.Post
+	for start := 0; start < len(ids); start += maxIDsPerLookup {
+		end := min(start+maxIDsPerLookup, len(ids))
+		fullIDs := make([]string, 0, end-start)
+		for _, id := range ids[start:end] {
+			fullIDs = append(fullIDs, "t3_"+id)
+		}
+
+		posts, err := r.postsByID(ctx, fullIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, post := range posts {
+			out = append(out, toModel(post))
+		}
+	}
+	return out, nil
+}
+
+func toModel(post rawPost) models.Post {
+	return models.Post{
+		PostID:         post.ID,
+		URL:            post.URL,
+		Title:          post.Title,
+		Body:           post.Body,
+		SellerUsername: post.Author,
+		Subreddit:      post.SubredditName,
+		Images:         imagesOf(post),
+	}
 }
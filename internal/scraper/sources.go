@@ -0,0 +1,155 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"frag-aggra/internal/models"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// trade types a post can be tagged with, the same values the parser uses for intent
+const (
+	TradeSell  = models.IntentSell
+	TradeTrade = models.IntentTrade
+	TradeBuy   = models.IntentBuy
+)
+
+// checked in this order, so a "[WTS/WTT]" post counts as a sale
+var tradeTypeOrder = []string{TradeSell, TradeTrade, TradeBuy}
+
+// Source is one subreddit to poll and the tags that make a post worth parsing
+type Source struct {
+	Name         string              `json:"name"`
+	Subreddit    string              `json:"subreddit"`
+	Tags         map[string][]string `json:"tags"` // trade type -> regexes
+	PollInterval string              `json:"poll_interval"`
+	FetchLimit   int                 `json:"fetch_limit"`
+
+	interval time.Duration
+	tags     map[string][]*regexp.Regexp
+}
+
+// a source's name ends up in its checkpoint's file name, so nothing that could
+// leave the checkpoint directory
+var sourceNameRe = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// DefaultSubreddit is the one subreddit the scraper polled before sources
+const DefaultSubreddit = "fragranceswap"
+
+// DefaultSources is what runs when no sources file is configured, the same
+// r/fragranceswap polling the scraper always did plus trade posts
+func DefaultSources(fetchLimit int) []Source {
+	sources := []Source{{
+		Name:      DefaultSubreddit,
+		Subreddit: DefaultSubreddit,
+		Tags: map[string][]string{
+			TradeSell:  {`(?i)\[wts\]`, `(?i)\[wts\s*/\s*wtt\]`},
+			TradeTrade: {`(?i)\[wtt\]`},
+		},
+		PollInterval: "5m",
+		FetchLimit:   fetchLimit,
+	}}
+	for i := range sources {
+		// the defaults are known good
+		_ = sources[i].compile(fetchLimit)
+	}
+	return sources
+}
+
+// LoadSources reads a json array of sources from path. sources without a
+// fetch limit use defaultLimit, ones without an interval poll every 5 minutes.
+func LoadSources(path string, defaultLimit int) ([]Source, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sources %s: %w", path, err)
+	}
+	var sources []Source
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return nil, fmt.Errorf("failed to decode sources %s: %w", path, err)
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no sources in %s", path)
+	}
+
+	seen := map[string]bool{}
+	for i := range sources {
+		if err := sources[i].compile(defaultLimit); err != nil {
+			return nil, err
+		}
+		if seen[sources[i].Name] {
+			return nil, fmt.Errorf("duplicate source name %q", sources[i].Name)
+		}
+		seen[sources[i].Name] = true
+	}
+	return sources, nil
+}
+
+func (s *Source) compile(defaultLimit int) error {
+	if s.Subreddit == "" {
+		return errors.New("source is missing a subreddit")
+	}
+	if s.Name == "" {
+		s.Name = strings.ToLower(s.Subreddit)
+	}
+	if !sourceNameRe.MatchString(s.Name) {
+		return fmt.Errorf("source name %q must be lowercase letters, digits, _ or -", s.Name)
+	}
+	if len(s.Tags) == 0 {
+		return fmt.Errorf("source %s has no tags", s.Name)
+	}
+
+	s.interval = 5 * time.Minute
+	if s.PollInterval != "" {
+		d, err := time.ParseDuration(s.PollInterval)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("source %s has invalid poll_interval %q", s.Name, s.PollInterval)
+		}
+		s.interval = d
+	}
+
+	if s.FetchLimit <= 0 {
+		s.FetchLimit = defaultLimit
+	}
+	if s.FetchLimit <= 0 || s.FetchLimit > 100 {
+		return fmt.Errorf("source %s fetch_limit %d out of range [1, 100]", s.Name, s.FetchLimit)
+	}
+
+	s.tags = map[string][]*regexp.Regexp{}
+	for tradeType, patterns := range s.Tags {
+		if tradeType != TradeSell && tradeType != TradeTrade && tradeType != TradeBuy {
+			return fmt.Errorf("source %s has unknown trade type %q", s.Name, tradeType)
+		}
+		for _, p := range patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return fmt.Errorf("source %s has invalid %s tag %q: %w", s.Name, tradeType, p, err)
+			}
+			s.tags[tradeType] = append(s.tags[tradeType], re)
+		}
+	}
+	return nil
+}
+
+// Interval is how often the source is polled
+func (s Source) Interval() time.Duration {
+	return s.interval
+}
+
+// Classify returns the trade type a post's tags say it is, checking the title
+// before the body. ok is false when none of the source's tags match.
+func (s Source) Classify(title, body string) (string, bool) {
+	for _, text := range []string{title, body} {
+		for _, tradeType := range tradeTypeOrder {
+			for _, re := range s.tags[tradeType] {
+				if re.MatchString(text) {
+					return tradeType, true
+				}
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,47 @@
+package scraper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadSourcesNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources string
+		want    []string // the names, or nil when it should fail
+		err     string
+	}{
+		{"name defaults to the subreddit", `[{"subreddit": "FragranceSwap", "tags": {"sell": ["WTS"]}}]`, []string{"fragranceswap"}, ""},
+		{"explicit names", `[{"name": "swap-sell", "subreddit": "fragranceswap", "tags": {"sell": ["WTS"]}}, {"name": "decant_2", "subreddit": "fragranceswap", "tags": {"buy": ["WTB"]}}]`, []string{"swap-sell", "decant_2"}, ""},
+		{"path in the name", `[{"name": "../../etc/cron", "subreddit": "fragranceswap", "tags": {"sell": ["WTS"]}}]`, nil, "must be lowercase"},
+		{"uppercase name", `[{"name": "Swap", "subreddit": "fragranceswap", "tags": {"sell": ["WTS"]}}]`, nil, "must be lowercase"},
+		{"duplicate", `[{"subreddit": "fragranceswap", "tags": {"sell": ["WTS"]}}, {"name": "fragranceswap", "subreddit": "fragranceswap", "tags": {"buy": ["WTB"]}}]`, nil, "duplicate"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "sources.json")
+		if err := os.WriteFile(path, []byte(tt.sources), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		sources, err := LoadSources(path, 25)
+		if tt.want == nil {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var names []string
+		for _, s := range sources {
+			names = append(names, s.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: names = %q, want %q", tt.name, names, tt.want)
+		}
+	}
+}
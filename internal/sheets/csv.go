@@ -0,0 +1,185 @@
+package sheets
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"frag-aggra/internal/models"
+	"frag-aggra/internal/parser"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNoHeader is returned for a csv without a recognizable name and price column
+var ErrNoHeader = errors.New("no name and price columns found")
+
+// how far down a sheet the header row is looked for, sellers like title rows
+const maxHeaderRow = 10
+
+var (
+	cellPriceRe = regexp.MustCompile(`\$?\s*(\d{1,3}(?:,\d{3})+|\d+)(?:\.(\d{1,2}))?`)
+	cellSizeRe  = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(?:ml)?\s*(?:/\s*(\d+(?:\.\d+)?))?\s*(?:ml)?`)
+	cellFillRe  = regexp.MustCompile(`(\d{1,3})\s*%`)
+	soldCellRe  = regexp.MustCompile(`(?i)\b(?:sold|pending|traded|gone|on hold)\b`)
+)
+
+// column roles, matched against lowercased header cells in this order so
+// "price per ml" doesn't end up as the price
+var columnRoles = []struct {
+	role  string
+	words []string
+}{
+	{"notes", []string{"note", "comment", "detail"}},
+	{"status", []string{"status", "sold", "available"}},
+	{"batch", []string{"batch"}},
+	{"fill", []string{"fill", "remaining", "level", "%"}},
+	{"quantity", []string{"qty", "quantity", "count"}},
+	{"condition", []string{"condition", "box"}},
+	{"brand", []string{"brand", "house", "designer"}},
+	{"size", []string{"size", "ml", "volume"}},
+	{"price", []string{"price", "asking", "cost", "$"}},
+	{"name", []string{"name", "fragrance", "perfume", "scent", "item", "product"}},
+}
+
+// ParseCSV reads a price list into perfumes, one offer per row. rows without
+// a name or a price, and rows marked sold, are skipped.
+func ParseCSV(data []byte) ([]models.Perfume, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv: %w", err)
+	}
+
+	headerRow, cols := -1, map[string]int{}
+	for i := 0; i < len(records) && i < maxHeaderRow; i++ {
+		if c := columns(records[i]); c != nil {
+			headerRow, cols = i, c
+			break
+		}
+	}
+	if headerRow < 0 {
+		return nil, ErrNoHeader
+	}
+
+	var perfumes []models.Perfume
+	index := map[string]int{}
+	for _, record := range records[headerRow+1:] {
+		cell := func(role string) string {
+			i, ok := cols[role]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		name := strings.Join(strings.Fields(cell("name")), " ")
+		if brand := strings.TrimSpace(cell("brand")); brand != "" && !strings.HasPrefix(strings.ToLower(name), strings.ToLower(brand)) {
+			name = brand + " " + name
+		}
+		if name == "" || soldCellRe.MatchString(cell("status")) || soldCellRe.MatchString(cell("price")) {
+			continue
+		}
+		offer, ok := rowOffer(cell)
+		if !ok {
+			continue
+		}
+
+		key := strings.ToLower(name)
+		i, seen := index[key]
+		if !seen {
+			i = len(perfumes)
+			index[key] = i
+			perfumes = append(perfumes, models.Perfume{Name: name})
+		}
+		perfumes[i].Offers = append(perfumes[i].Offers, offer)
+	}
+	return perfumes, nil
+}
+
+// maps roles to column indexes, nil unless the row has a name and a price column
+func columns(header []string) map[string]int {
+	cols := map[string]int{}
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "" {
+			continue
+		}
+		for _, c := range columnRoles {
+			if _, taken := cols[c.role]; taken {
+				continue
+			}
+			if containsAny(h, c.words) {
+				cols[c.role] = i
+				break
+			}
+		}
+	}
+	_, hasName := cols["name"]
+	_, hasPrice := cols["price"]
+	if !hasName || !hasPrice {
+		return nil
+	}
+	return cols
+}
+
+func containsAny(s string, words []string) bool {
+	for _, w := range words {
+		if strings.Contains(s, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// the offer on one row, in the same formats the llm is asked for
+func rowOffer(cell func(string) string) (models.Offer, bool) {
+	priceCell := cell("price")
+	price := cellPriceRe.FindStringSubmatchIndex(priceCell)
+	if price == nil {
+		return models.Offer{}, false
+	}
+	offer := models.Offer{
+		Price:     "$" + priceCell[price[2]:price[3]],
+		BatchCode: cell("batch"),
+		Quantity:  1,
+		Notes:     cell("notes"),
+	}
+	if price[4] >= 0 {
+		offer.Price += "." + priceCell[price[4]:price[5]]
+	}
+	// "$250 OBO", "$40 shipped"
+	if rest := strings.TrimSpace(priceCell[price[1]:]); rest != "" {
+		offer.Notes = strings.TrimSpace(offer.Notes + " " + rest)
+	}
+	offer.Condition, offer.Box = parser.ConditionOf(cell("condition") + " " + cell("notes"))
+	if q, err := strconv.Atoi(cell("quantity")); err == nil && q > 0 {
+		offer.Quantity = q
+	}
+	if m := cellFillRe.FindStringSubmatch(cell("fill")); m != nil {
+		if fill, _ := strconv.Atoi(m[1]); fill > 0 && fill <= 100 {
+			offer.FillPercent = fill
+		}
+	}
+
+	if m := cellSizeRe.FindStringSubmatch(cell("size")); m != nil && m[1] != "" {
+		switch {
+		case m[2] != "":
+			offer.Size = m[1] + "/" + m[2] + "ml"
+		case offer.FillPercent > 0 && offer.FillPercent < 100:
+			// "100ml" with a fill column of "80%" is a partial, written the way partials are
+			capacity, _ := strconv.ParseFloat(m[1], 64)
+			remaining := strconv.FormatFloat(capacity*float64(offer.FillPercent)/100, 'f', -1, 64)
+			offer.Size = remaining + "/" + m[1] + "ml"
+		default:
+			offer.Size = m[1] + "ml"
+		}
+	}
+	if offer.Condition == models.ConditionUnknown && strings.Contains(offer.Size, "/") {
+		offer.Condition = models.ConditionUsed
+	}
+	return offer, true
+}
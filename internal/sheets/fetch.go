@@ -0,0 +1,144 @@
+package sheets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"frag-aggra/internal/models"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// MaxSheetBytes caps a download, price lists are a few kB
+const MaxSheetBytes = 5 << 20
+
+// ErrNotShared is returned when google answers with a sign in page instead of
+// the csv, the seller didn't share the sheet publicly
+var ErrNotShared = errors.New("sheet is not shared publicly")
+
+// Fetcher downloads a link's rows as csv
+type Fetcher interface {
+	Fetch(ctx context.Context, link models.SheetLink) ([]byte, error)
+}
+
+// google's export redirects once, anything past this is refused
+const maxRedirects = 3
+
+// HTTPFetcher downloads the csv export of a link. with BaseURL set it asks
+// BaseURL/FileName(link) instead, so any static file server over a directory
+// of csv files (python3 -m http.server, httptest) can stand in for google.
+//
+// the links come from reddit posts, so without BaseURL only AllowedHost hosts
+// are fetched, redirects included, and never at a loopback, private or link
+// local address whatever the name resolves to.
+type HTTPFetcher struct {
+	Client  *http.Client
+	BaseURL string
+}
+
+func NewHTTPFetcher(baseURL string) *HTTPFetcher {
+	f := &HTTPFetcher{BaseURL: strings.TrimRight(baseURL, "/")}
+	f.Client = &http.Client{Timeout: 30 * time.Second, CheckRedirect: f.checkRedirect}
+	if f.BaseURL == "" {
+		dialer := &net.Dialer{Timeout: 10 * time.Second, Control: publicOnly}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = dialer.DialContext
+		// through a proxy the dial check would only ever see the proxy
+		transport.Proxy = nil
+		f.Client.Transport = transport
+	}
+	return f
+}
+
+func (f *HTTPFetcher) Fetch(ctx context.Context, link models.SheetLink) ([]byte, error) {
+	target := ExportURL(link)
+	if f.BaseURL != "" {
+		target = f.BaseURL + "/" + FileName(link)
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if !f.allowed(u) {
+		return nil, fmt.Errorf("refusing to fetch %s: host not allowed", target)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := f.Client
+	if client == nil {
+		client = NewHTTPFetcher(f.BaseURL).Client
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status %d", target, resp.StatusCode)
+	}
+	// private sheets redirect to an html sign in page
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "text/html" {
+		return nil, ErrNotShared
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxSheetBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", target, err)
+	}
+	if len(data) > MaxSheetBytes {
+		return nil, fmt.Errorf("%s is over %d bytes", target, MaxSheetBytes)
+	}
+	return data, nil
+}
+
+// a local BaseURL is trusted, everything else has to be an allowed host
+func (f *HTTPFetcher) allowed(u *url.URL) bool {
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return false
+	}
+	if f.BaseURL != "" {
+		base, err := url.Parse(f.BaseURL)
+		return err == nil && strings.EqualFold(u.Host, base.Host)
+	}
+	return AllowedHost(u.Hostname())
+}
+
+func (f *HTTPFetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", len(via))
+	}
+	if !f.allowed(req.URL) {
+		return fmt.Errorf("refusing redirect to %s: host not allowed", req.URL.Host)
+	}
+	return nil
+}
+
+// net.Dialer.Control, runs on the resolved address so a public name pointing
+// at an internal one is caught too
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return fmt.Errorf("refusing to connect to %s", address)
+	}
+	return nil
+}
+
+// carrier grade nat, private in all but name
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified() && !sharedAddressSpace.Contains(ip)
+}
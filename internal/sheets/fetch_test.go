@@ -0,0 +1,73 @@
+package sheets
+
+import (
+	"context"
+	"frag-aggra/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFindLinksOnlyAllowedHosts(t *testing.T) {
+	body := `prices: https://raw.githubusercontent.com/seller/decants/main/prices.csv
+also http://169.254.169.254/latest/meta-data/x.csv and http://localhost:8080/admin.csv
+and https://10.0.0.5/prices.csv, https://example.com/list.csv`
+	links := FindLinks(body)
+	if len(links) != 1 || links[0].URL != "https://raw.githubusercontent.com/seller/decants/main/prices.csv" {
+		t.Fatalf("FindLinks = %+v, want only the github csv", links)
+	}
+}
+
+func TestPublicOnly(t *testing.T) {
+	tests := []struct {
+		address string
+		ok      bool
+	}{
+		{"142.250.72.14:443", true},
+		{"[2607:f8b0:4005:80b::200e]:443", true},
+		{"127.0.0.1:443", false},
+		{"[::1]:443", false},
+		{"10.1.2.3:443", false},
+		{"172.16.0.1:443", false},
+		{"192.168.1.10:80", false},
+		{"169.254.169.254:80", false},
+		{"100.64.0.1:80", false},
+		{"0.0.0.0:80", false},
+		{"[fe80::1]:80", false},
+		{"[fd00::1]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+	}
+	for _, tt := range tests {
+		err := publicOnly("tcp", tt.address, nil)
+		if (err == nil) != tt.ok {
+			t.Errorf("publicOnly(%s) = %v, want allowed %v", tt.address, err, tt.ok)
+		}
+	}
+}
+
+func TestHTTPFetcherRefusesHosts(t *testing.T) {
+	f := NewHTTPFetcher("")
+	for _, raw := range []string{
+		"http://127.0.0.1:8080/prices.csv",
+		"http://169.254.169.254/latest/meta-data/prices.csv",
+		"https://example.com/prices.csv",
+		"file:///etc/passwd.csv",
+	} {
+		link := models.SheetLink{URL: raw, Kind: models.SheetCSV}
+		if _, err := f.Fetch(context.Background(), link); err == nil {
+			t.Errorf("Fetch(%s) succeeded", raw)
+		}
+	}
+}
+
+func TestHTTPFetcherRefusesRedirectOffHost(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	t.Cleanup(srv.Close)
+
+	link := models.SheetLink{URL: "https://raw.githubusercontent.com/seller/decants/main/prices.csv", Kind: models.SheetCSV}
+	if _, err := NewHTTPFetcher(srv.URL).Fetch(context.Background(), link); err == nil {
+		t.Fatal("Fetch followed a redirect off the sheet host")
+	}
+}
@@ -0,0 +1,109 @@
+package sheets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"frag-aggra/internal/catalog"
+	"frag-aggra/internal/database"
+	"frag-aggra/internal/models"
+	"log"
+)
+
+// ErrNoOffers is recorded for a price list that was read but had nothing for sale
+var ErrNoOffers = errors.New("no offers found in sheet")
+
+// Ingester reads the price lists stored posts link to and fills in their
+// "See Spreadsheet" listings
+type Ingester struct {
+	repo    *database.Repository
+	fetcher Fetcher
+
+	// optional, resolves items only the sheet mentions against the catalog
+	Linker *catalog.Linker
+
+	// how often a failing link is retried and how many links a run reads
+	MaxAttempts int
+	MaxBatch    int
+}
+
+func NewIngester(repo *database.Repository, fetcher Fetcher) *Ingester {
+	return &Ingester{
+		repo:        repo,
+		fetcher:     fetcher,
+		MaxAttempts: 3,
+		MaxBatch:    50,
+	}
+}
+
+// RunOnce reads one batch of due links, returning how many were read
+func (in *Ingester) RunOnce(ctx context.Context) (int, error) {
+	links, err := in.repo.DueSheetLinks(ctx, in.MaxAttempts, in.MaxBatch)
+	if err != nil {
+		return 0, err
+	}
+	fetched := 0
+	for _, link := range links {
+		if ctx.Err() != nil {
+			return fetched, ctx.Err()
+		}
+		if err := in.Ingest(ctx, link); err != nil {
+			log.Printf("sheet %s of post %s failed: %v", link.URL, link.RedditID, err)
+			continue
+		}
+		fetched++
+	}
+	return fetched, nil
+}
+
+// Ingest reads one link and applies its offers to the post, recording the
+// outcome on the link either way
+func (in *Ingester) Ingest(ctx context.Context, link models.SheetLink) error {
+	offers, err := in.ingest(ctx, link)
+	if err != nil {
+		if markErr := in.repo.MarkSheetLink(ctx, link.ID, models.SheetFailed, 0, err); markErr != nil {
+			log.Printf("failed to mark sheet link %d: %v", link.ID, markErr)
+		}
+		return err
+	}
+	return in.repo.MarkSheetLink(ctx, link.ID, models.SheetFetched, offers, nil)
+}
+
+func (in *Ingester) ingest(ctx context.Context, link models.SheetLink) (int, error) {
+	data, err := in.fetcher.Fetch(ctx, link)
+	if err != nil {
+		return 0, err
+	}
+	sheet, err := ParseCSV(data)
+	if err != nil {
+		return 0, err
+	}
+	offers := 0
+	for _, perfume := range sheet {
+		offers += len(perfume.Offers)
+	}
+	if offers == 0 {
+		return 0, ErrNoOffers
+	}
+
+	post, err := in.repo.GetPost(ctx, link.RedditID)
+	if err != nil {
+		return 0, err
+	}
+	match := Attach(post.Listings, sheet)
+	if match.Empty() {
+		return offers, nil
+	}
+	if len(post.Listings) > 0 && post.Listings[0].Intent != "" {
+		match.Added.Intent = post.Listings[0].Intent
+	}
+	if in.Linker != nil {
+		in.Linker.Link(ctx, &match.Added)
+	}
+	if err := in.repo.ApplySheetOffers(ctx, link.RedditID, match.Updates, match.Added); err != nil {
+		return 0, fmt.Errorf("failed to apply sheet offers: %w", err)
+	}
+	log.Printf("sheet of post %s: %d offers, %d listings filled in, %d new items",
+		link.RedditID, offers, len(match.Updates), len(match.Added.Perfumes))
+	return offers, nil
+}
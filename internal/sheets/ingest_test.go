@@ -0,0 +1,160 @@
+package sheets
+
+import (
+	"context"
+	"frag-aggra/internal/database"
+	"frag-aggra/internal/models"
+	"frag-aggra/internal/parser"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+const testSheetURL = "https://docs.google.com/spreadsheets/d/1AbCdEfGhIjKlMnOpQrStUvWxYz0123456789/edit#gid=0"
+
+// a static file server over testdata/sheets stands in for google
+func sheetServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.FileServer(http.Dir("../../testdata/sheets")))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTPFetcherFromFileServer(t *testing.T) {
+	srv := sheetServer(t)
+	links := FindLinks("prices here: " + testSheetURL)
+	if len(links) != 1 || links[0].Kind != models.SheetGoogle {
+		t.Fatalf("FindLinks = %+v, want one google sheet", links)
+	}
+
+	data, err := NewHTTPFetcher(srv.URL).Fetch(context.Background(), links[0])
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	sheet, err := ParseCSV(data)
+	if err != nil {
+		t.Fatalf("ParseCSV: %v", err)
+	}
+
+	want := map[string][]models.Offer{
+		"Creed Aventus": {{Size: "80/100ml", Price: "$250", Notes: "OBO"}, {Size: "10ml", Price: "$35"}},
+		"Maison Francis Kurkdjian Baccarat Rouge 540": {{Size: "70ml", Price: "$1,200"}},
+		"Xerjoff Naxos": {{Size: "50/100ml", Price: "$120"}},
+	}
+	if len(sheet) != len(want) {
+		t.Fatalf("got %d perfumes, want %d: %+v", len(sheet), len(want), sheet)
+	}
+	for _, perfume := range sheet {
+		offers, ok := want[perfume.Name]
+		if !ok {
+			t.Errorf("unexpected perfume %q", perfume.Name)
+			continue
+		}
+		if len(perfume.Offers) != len(offers) {
+			t.Errorf("%s: got %d offers, want %d", perfume.Name, len(perfume.Offers), len(offers))
+			continue
+		}
+		for i, offer := range perfume.Offers {
+			w := offers[i]
+			if offer.Size != w.Size || offer.Price != w.Price || offer.Notes != w.Notes {
+				t.Errorf("%s offer %d = %s %s %q, want %s %s %q", perfume.Name, i, offer.Size, offer.Price, offer.Notes, w.Size, w.Price, w.Notes)
+			}
+		}
+	}
+}
+
+func TestHTTPFetcherMissingSheet(t *testing.T) {
+	srv := sheetServer(t)
+	link := models.SheetLink{URL: "https://example.com/nope.csv", Kind: models.SheetCSV}
+	if _, err := NewHTTPFetcher(srv.URL).Fetch(context.Background(), link); err == nil {
+		t.Fatal("Fetch of a missing sheet succeeded")
+	}
+}
+
+// runs against TEST_DATABASE_URL, a migrated database the test may write to
+func testRepo(t *testing.T) *database.Repository {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	repo, err := database.New(context.Background(), dbURL)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(repo.Close)
+	return repo
+}
+
+func TestIngest(t *testing.T) {
+	repo := testRepo(t)
+	ctx := context.Background()
+	srv := sheetServer(t)
+
+	post := models.Post{
+		PostID:         "test_sheet_ingest",
+		URL:            "https://www.reddit.com/r/fragranceswap/comments/test_sheet_ingest",
+		Title:          "[USA-CA] [H] Aventus, BR540 [W] PayPal",
+		Body:           "Prices in the sheet: " + testSheetURL,
+		SellerUsername: "sheet_tester",
+	}
+	listing := models.FragranceListing{
+		Intent: models.IntentSell,
+		Perfumes: []models.Perfume{
+			{Name: "Creed Aventus", Offers: []models.Offer{{Size: "10ml", Price: parser.PriceSpreadsheet}}},
+			{Name: "Baccarat Rouge 540", Offers: []models.Offer{{Price: parser.PriceSpreadsheet}}},
+		},
+	}
+	_ = repo.DeletePost(ctx, post.PostID)
+	t.Cleanup(func() { _ = repo.DeletePost(ctx, post.PostID) })
+	if err := repo.InsertItem(ctx, post, listing); err != nil {
+		t.Fatalf("InsertItem: %v", err)
+	}
+	if err := repo.AddSheetLinks(ctx, post.PostID, FindLinks(post.Body)); err != nil {
+		t.Fatalf("AddSheetLinks: %v", err)
+	}
+	links, err := repo.PostSheetLinks(ctx, post.PostID)
+	if err != nil || len(links) != 1 {
+		t.Fatalf("PostSheetLinks = %+v, %v", links, err)
+	}
+
+	in := NewIngester(repo, NewHTTPFetcher(srv.URL))
+	if err := in.Ingest(ctx, links[0]); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+
+	links, err = repo.PostSheetLinks(ctx, post.PostID)
+	if err != nil {
+		t.Fatalf("PostSheetLinks: %v", err)
+	}
+	if links[0].Status != models.SheetFetched || links[0].Offers != 4 {
+		t.Errorf("link is %s with %d offers, want fetched with 4", links[0].Status, links[0].Offers)
+	}
+
+	stored, err := repo.GetPost(ctx, post.PostID)
+	if err != nil {
+		t.Fatalf("GetPost: %v", err)
+	}
+	prices := map[string]string{}
+	for _, l := range stored.Listings {
+		if l.ExtractedBy == nil || *l.ExtractedBy != models.ExtractedBySheet {
+			t.Errorf("%s %s: extracted_by = %v, want %s", l.Name, l.Size, l.ExtractedBy, models.ExtractedBySheet)
+		}
+		prices[l.Name+" "+l.Size] = l.Price
+	}
+	want := map[string]string{
+		"Creed Aventus 80/100ml":  "$250",
+		"Baccarat Rouge 540 70ml": "$1,200",
+		"Creed Aventus 10ml":      "$35",
+		"Xerjoff Naxos 50/100ml":  "$120",
+	}
+	for key, price := range want {
+		if prices[key] != price {
+			t.Errorf("%s priced %q, want %q (stored %v)", key, prices[key], price, prices)
+		}
+	}
+	if len(prices) != len(want) {
+		t.Errorf("stored %d listings, want %d: %v", len(prices), len(want), prices)
+	}
+}
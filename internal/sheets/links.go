@@ -0,0 +1,117 @@
+package sheets
+
+import (
+	"frag-aggra/internal/models"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	// bare and markdown links, reddit escapes underscores in raw_json=0 bodies
+	urlRe = regexp.MustCompile(`https?://[^\s)\]>"'<]+`)
+
+	// /spreadsheets/d/<id>/... and the published /spreadsheets/d/e/<id>/pubhtml
+	googleSheetRe = regexp.MustCompile(`^/spreadsheets/d/(e/)?([A-Za-z0-9_-]+)`)
+	gidRe         = regexp.MustCompile(`gid=(\d+)`)
+)
+
+// hosts a plain .csv link is downloaded from. the links come from anyone's
+// post and the server fetches them, so it's these and google sheets only.
+var csvHosts = map[string]bool{
+	"raw.githubusercontent.com": true, "gist.githubusercontent.com": true,
+	"dropbox.com": true, "dl.dropboxusercontent.com": true,
+}
+
+// AllowedHost reports whether a sheet may be downloaded from host, redirects
+// included. google's export answers from a googleusercontent.com host.
+func AllowedHost(host string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	return host == "docs.google.com" || csvHosts[host] || strings.HasSuffix(host, ".googleusercontent.com")
+}
+
+// FindLinks returns the price list links in a post body, each once
+func FindLinks(body string) []models.SheetLink {
+	var links []models.SheetLink
+	seen := map[string]bool{}
+	for _, raw := range urlRe.FindAllString(body, -1) {
+		raw = strings.TrimRight(strings.ReplaceAll(raw, `\_`, "_"), ".,;:!?")
+		u, err := url.Parse(raw)
+		if err != nil || seen[raw] {
+			continue
+		}
+		kind := kindOf(u)
+		if kind == "" {
+			continue
+		}
+		seen[raw] = true
+		links = append(links, models.SheetLink{URL: raw, Kind: kind, Status: models.SheetPending})
+	}
+	return links
+}
+
+func kindOf(u *url.URL) string {
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	switch {
+	case host == "docs.google.com" && googleSheetRe.MatchString(u.Path):
+		return models.SheetGoogle
+	case csvHosts[host] && strings.HasSuffix(strings.ToLower(u.Path), ".csv"):
+		return models.SheetCSV
+	}
+	return ""
+}
+
+// ExportURL is where a link's rows can be downloaded as csv. google sheets
+// have to be shared with "anyone with the link" for the export to work.
+func ExportURL(link models.SheetLink) string {
+	u, err := url.Parse(link.URL)
+	if err != nil || link.Kind != models.SheetGoogle {
+		return link.URL
+	}
+	m := googleSheetRe.FindStringSubmatch(u.Path)
+	if m == nil {
+		return link.URL
+	}
+	if m[1] != "" {
+		// published to the web
+		export := "https://docs.google.com/spreadsheets/d/e/" + m[2] + "/pub?output=csv"
+		if gid := gidOf(u); gid != "" {
+			export += "&gid=" + gid
+		}
+		return export
+	}
+	export := "https://docs.google.com/spreadsheets/d/" + m[2] + "/export?format=csv"
+	if gid := gidOf(u); gid != "" {
+		export += "&gid=" + gid
+	}
+	return export
+}
+
+// FileName names a link's csv when it's served from a directory instead,
+// "<sheet id>[-<gid>].csv" for google sheets and the file's own name otherwise
+func FileName(link models.SheetLink) string {
+	u, err := url.Parse(link.URL)
+	if err != nil {
+		return ""
+	}
+	if m := googleSheetRe.FindStringSubmatch(u.Path); link.Kind == models.SheetGoogle && m != nil {
+		name := m[2]
+		if gid := gidOf(u); gid != "" {
+			name += "-" + gid
+		}
+		return name + ".csv"
+	}
+	return path.Base(u.Path)
+}
+
+// the tab, from the query or the #gid= fragment the share button adds
+func gidOf(u *url.URL) string {
+	if gid := u.Query().Get("gid"); gid != "" {
+		return gid
+	}
+	if m := gidRe.FindStringSubmatch(u.Fragment); m != nil {
+		return m[1]
+	}
+	return ""
+}
@@ -0,0 +1,90 @@
+package sheets
+
+import (
+	"frag-aggra/internal/models"
+	"frag-aggra/internal/parser"
+	"strings"
+)
+
+// Match is how a price list's offers fit onto a post's stored listings
+type Match struct {
+	Updates map[int64]models.Offer  // offer for a stored "See Spreadsheet" listing, by listing id
+	Added   models.FragranceListing // offers the post's text didn't mention
+}
+
+// Empty is true when the sheet adds nothing
+func (m Match) Empty() bool {
+	return len(m.Updates) == 0 && len(m.Added.Perfumes) == 0
+}
+
+// Attach pairs every sheet offer with a stored listing still waiting for its
+// price: same name (or one name containing the other, sheets often leave the
+// brand out) and the same size when the listing has one. offers for items the
+// post has stored with a real price are left alone, the rest become new
+// listings.
+func Attach(stored []models.Listing, sheet []models.Perfume) Match {
+	m := Match{Updates: map[int64]models.Offer{}}
+	m.Added.ExtractedBy = models.ExtractedBySheet
+	m.Added.Intent = models.IntentSell
+
+	used := map[int64]bool{}
+	for _, perfume := range sheet {
+		added := models.Perfume{Name: perfume.Name}
+		for _, offer := range perfume.Offers {
+			if l := waiting(stored, used, perfume.Name, offer.Size); l != nil {
+				used[l.ID] = true
+				m.Updates[l.ID] = offer
+				continue
+			}
+			if priced(stored, perfume.Name, offer.Size) {
+				continue
+			}
+			added.Offers = append(added.Offers, offer)
+		}
+		if len(added.Offers) > 0 {
+			m.Added.Perfumes = append(m.Added.Perfumes, added)
+		}
+	}
+	return m
+}
+
+// the first unused stored listing for this item whose price is still "See Spreadsheet"
+func waiting(stored []models.Listing, used map[int64]bool, name, size string) *models.Listing {
+	for i := range stored {
+		l := &stored[i]
+		if used[l.ID] || l.Price != parser.PriceSpreadsheet || !sameName(l.Name, name) {
+			continue
+		}
+		if l.Size != "" && size != "" && sizeKey(l.Size) != sizeKey(size) {
+			continue
+		}
+		return l
+	}
+	return nil
+}
+
+// whether the post already lists this item with a price of its own
+func priced(stored []models.Listing, name, size string) bool {
+	for _, l := range stored {
+		if l.Price != parser.PriceSpreadsheet && sameName(l.Name, name) && sizeKey(l.Size) == sizeKey(size) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameName(a, b string) bool {
+	a, b = nameKey(a), nameKey(b)
+	if a == "" || b == "" {
+		return false
+	}
+	return a == b || strings.Contains(a, b) || strings.Contains(b, a)
+}
+
+func nameKey(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+func sizeKey(s string) string {
+	return strings.ToLower(strings.ReplaceAll(s, " ", ""))
+}
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_listings_bottle_kind;
+DROP INDEX IF EXISTS idx_listings_price_per_ml;
+DROP INDEX IF EXISTS idx_listings_price_cents;
+
+ALTER TABLE listings
+    DROP COLUMN IF EXISTS normalize_error,
+    DROP COLUMN IF EXISTS price_per_ml_cents,
+    DROP COLUMN IF EXISTS bottle_kind,
+    DROP COLUMN IF EXISTS capacity_ml,
+    DROP COLUMN IF EXISTS remaining_ml,
+    DROP COLUMN IF EXISTS currency,
+    DROP COLUMN IF EXISTS price_cents;
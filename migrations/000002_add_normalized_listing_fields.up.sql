@@ -0,0 +1,25 @@
+ALTER TABLE listings
+    -- The price in minor units (e.g., 15000 for '$150'), NULL if it couldn't be parsed.
+    ADD COLUMN price_cents INTEGER,
+
+    -- ISO currency code of the price (e.g., 'USD').
+    ADD COLUMN currency CHAR(3),
+
+    -- How much juice is left in the bottle, in ml (80 for '80/100ml').
+    ADD COLUMN remaining_ml NUMERIC(7, 1),
+
+    -- The full capacity of the bottle, in ml (100 for '80/100ml').
+    ADD COLUMN capacity_ml NUMERIC(7, 1),
+
+    -- 'full', 'partial' or 'decant'.
+    ADD COLUMN bottle_kind VARCHAR(10),
+
+    -- price_cents / remaining_ml, for sorting by value.
+    ADD COLUMN price_per_ml_cents NUMERIC(12, 2),
+
+    -- Why the row couldn't be normalized (e.g., 'See Spreadsheet'), NULL when it was.
+    ADD COLUMN normalize_error TEXT;
+
+CREATE INDEX idx_listings_price_cents ON listings(price_cents);
+CREATE INDEX idx_listings_price_per_ml ON listings(price_per_ml_cents);
+CREATE INDEX idx_listings_bottle_kind ON listings(bottle_kind);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_listings_extracted_by;
+
+ALTER TABLE listings DROP COLUMN IF EXISTS extracted_by;
@@ -0,0 +1,4 @@
+-- Which path produced the row: 'rules' for the deterministic pre-parser, 'llm' otherwise.
+ALTER TABLE listings ADD COLUMN extracted_by VARCHAR(10);
+
+CREATE INDEX idx_listings_extracted_by ON listings(extracted_by);
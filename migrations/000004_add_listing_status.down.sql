@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_posts_created_at;
+DROP INDEX IF EXISTS idx_listings_status;
+
+ALTER TABLE listings
+    DROP COLUMN IF EXISTS status_changed_at,
+    DROP COLUMN IF EXISTS status;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS last_checked_at,
+    DROP COLUMN IF EXISTS body_hash;
@@ -0,0 +1,18 @@
+ALTER TABLE posts
+    -- sha256 of the title and body we last parsed, to spot edits.
+    ADD COLUMN body_hash CHAR(64),
+
+    -- When the post was last re-fetched from Reddit to look for edits.
+    ADD COLUMN last_checked_at TIMESTAMPTZ;
+
+ALTER TABLE listings
+    -- 'available', 'sold' or 'removed'.
+    ADD COLUMN status VARCHAR(10) NOT NULL DEFAULT 'available',
+
+    -- When the status last changed (set to created_at for new rows).
+    ADD COLUMN status_changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+UPDATE listings SET status_changed_at = created_at WHERE created_at IS NOT NULL;
+
+CREATE INDEX idx_listings_status ON listings(status);
+CREATE INDEX idx_posts_created_at ON posts(created_at);
@@ -0,0 +1,49 @@
+CREATE TABLE saved_searches (
+    id SERIAL PRIMARY KEY,
+
+    -- A label for the search (e.g., 'cheap layton').
+    name VARCHAR(255) NOT NULL,
+
+    -- Words that must all appear in the perfume name, case-insensitive (e.g., 'marly layton').
+    name_pattern VARCHAR(255) NOT NULL,
+
+    -- Bounds on the ml left in the bottle, NULL for no bound.
+    min_ml NUMERIC(7, 1),
+    max_ml NUMERIC(7, 1),
+
+    -- Only match 'full', 'partial' or 'decant', NULL for any.
+    bottle_kind VARCHAR(10),
+
+    -- Highest acceptable price in cents, NULL for any.
+    max_price_cents INTEGER,
+
+    -- Sellers whose listings never match.
+    seller_blocklist TEXT[] NOT NULL DEFAULT '{}',
+
+    -- Where to send matches: 'webhook', 'discord' or 'smtp', and the url/address.
+    notify_kind VARCHAR(20) NOT NULL,
+    notify_target TEXT NOT NULL,
+
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE notifications (
+    id SERIAL PRIMARY KEY,
+    saved_search_id INTEGER NOT NULL REFERENCES saved_searches(id) ON DELETE CASCADE,
+    listing_id INTEGER NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+
+    -- When the sink accepted it, NULL if delivery failed.
+    sent_at TIMESTAMPTZ,
+
+    -- Why delivery failed.
+    error TEXT,
+
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+
+    -- A listing is only ever alerted once per search.
+    UNIQUE (saved_search_id, listing_id)
+);
+
+CREATE INDEX idx_saved_searches_active ON saved_searches(active);
+CREATE INDEX idx_notifications_listing_id ON notifications(listing_id);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS fragrance_reviews;
+
+DROP INDEX IF EXISTS idx_listings_fragrance_id;
+ALTER TABLE listings
+    DROP COLUMN IF EXISTS resolve_score,
+    DROP COLUMN IF EXISTS fragrance_id;
+
+DROP TABLE IF EXISTS fragrances;
+DROP TABLE IF EXISTS brands;
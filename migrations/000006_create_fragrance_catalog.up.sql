@@ -0,0 +1,161 @@
+CREATE TABLE brands (
+    id SERIAL PRIMARY KEY,
+
+    -- The canonical brand name (e.g., 'Maison Francis Kurkdjian').
+    name VARCHAR(255) UNIQUE NOT NULL,
+
+    -- Other ways sellers write it (e.g., '{MFK}').
+    aliases TEXT[] NOT NULL DEFAULT '{}'
+);
+
+CREATE TABLE fragrances (
+    id SERIAL PRIMARY KEY,
+    brand_id INTEGER NOT NULL REFERENCES brands(id) ON DELETE CASCADE,
+
+    -- The fragrance line without the brand (e.g., 'Baccarat Rouge 540').
+    line VARCHAR(255) NOT NULL,
+
+    -- 'EDP', 'EDT', 'Parfum', 'Extrait', 'Cologne', ... NULL when there is only one.
+    concentration VARCHAR(20),
+
+    -- Other ways sellers write the line (e.g., '{BR540, BR 540}').
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_fragrances_identity ON fragrances(brand_id, line, COALESCE(concentration, ''));
+
+ALTER TABLE listings
+    -- The catalog entry the extracted name resolved to, NULL if it didn't resolve confidently.
+    ADD COLUMN fragrance_id INTEGER REFERENCES fragrances(id) ON DELETE SET NULL,
+
+    -- How confident the resolver was, 0 to 1.
+    ADD COLUMN resolve_score REAL;
+
+CREATE INDEX idx_listings_fragrance_id ON listings(fragrance_id);
+
+-- Extracted names the resolver couldn't place, waiting for a human.
+CREATE TABLE fragrance_reviews (
+    id SERIAL PRIMARY KEY,
+
+    -- The name as extracted from the post.
+    raw_name VARCHAR(255) UNIQUE NOT NULL,
+
+    -- The resolver's best guess and its score.
+    suggested_fragrance_id INTEGER REFERENCES fragrances(id) ON DELETE SET NULL,
+    suggested_score REAL,
+
+    -- How many listings have had this name.
+    seen_count INTEGER NOT NULL DEFAULT 1,
+
+    -- 'pending', 'resolved' or 'ignored'.
+    status VARCHAR(10) NOT NULL DEFAULT 'pending',
+
+    resolved_fragrance_id INTEGER REFERENCES fragrances(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_fragrance_reviews_status ON fragrance_reviews(status);
+
+-- Seed the catalog with the usual suspects on the sub.
+INSERT INTO brands (name, aliases) VALUES
+    ('Tom Ford', '{TF,T Ford}'),
+    ('Maison Francis Kurkdjian', '{MFK,Francis Kurkdjian,Kurkdjian}'),
+    ('Parfums de Marly', '{PdM,Marly}'),
+    ('Creed', '{}'),
+    ('Chanel', '{}'),
+    ('Dior', '{Christian Dior}'),
+    ('Yves Saint Laurent', '{YSL,Saint Laurent}'),
+    ('Giorgio Armani', '{Armani}'),
+    ('Xerjoff', '{}'),
+    ('Amouage', '{}'),
+    ('Le Labo', '{}'),
+    ('Byredo', '{}'),
+    ('Initio', '{Initio Parfums Prives}'),
+    ('Nishane', '{}'),
+    ('Louis Vuitton', '{LV}'),
+    ('Kilian', '{By Kilian}'),
+    ('Frederic Malle', '{Editions de Parfums Frederic Malle,FM}'),
+    ('Roja Parfums', '{Roja,Roja Dove}'),
+    ('Guerlain', '{}'),
+    ('Hermes', '{Hermès}'),
+    ('Diptyque', '{}'),
+    ('Jean Paul Gaultier', '{JPG}'),
+    ('Viktor & Rolf', '{Viktor and Rolf,V&R}'),
+    ('Montblanc', '{Mont Blanc}'),
+    ('Prada', '{}'),
+    ('Valentino', '{}'),
+    ('Maison Margiela', '{Margiela,MM}'),
+    ('Bvlgari', '{Bulgari}');
+
+INSERT INTO fragrances (brand_id, line, concentration, aliases)
+SELECT b.id, f.line, f.concentration, f.aliases::TEXT[]
+FROM (VALUES
+    ('Tom Ford', 'Tobacco Vanille', 'EDP', '{Tobacco Vanilla,TV}'),
+    ('Tom Ford', 'Oud Wood', 'EDP', '{}'),
+    ('Tom Ford', 'Lost Cherry', 'EDP', '{}'),
+    ('Tom Ford', 'Ombre Leather', 'EDP', '{}'),
+    ('Tom Ford', 'Tuscan Leather', 'EDP', '{}'),
+    ('Tom Ford', 'Noir Extreme', 'EDP', '{}'),
+    ('Tom Ford', 'Fucking Fabulous', 'EDP', '{F Fabulous,FF}'),
+    ('Maison Francis Kurkdjian', 'Baccarat Rouge 540', 'EDP', '{BR540,BR 540,Baccarat Rouge}'),
+    ('Maison Francis Kurkdjian', 'Baccarat Rouge 540', 'Extrait', '{BR540 Extrait,BR 540 Extrait,Baccarat Rouge Extrait}'),
+    ('Maison Francis Kurkdjian', 'Grand Soir', 'EDP', '{}'),
+    ('Maison Francis Kurkdjian', 'Oud Satin Mood', 'EDP', '{OSM}'),
+    ('Parfums de Marly', 'Layton', 'EDP', '{}'),
+    ('Parfums de Marly', 'Layton Exclusif', 'EDP', '{}'),
+    ('Parfums de Marly', 'Herod', 'EDP', '{}'),
+    ('Parfums de Marly', 'Pegasus', 'EDP', '{}'),
+    ('Parfums de Marly', 'Greenley', 'EDP', '{}'),
+    ('Parfums de Marly', 'Delina', 'EDP', '{}'),
+    ('Parfums de Marly', 'Althair', 'EDP', '{}'),
+    ('Creed', 'Aventus', 'EDP', '{}'),
+    ('Creed', 'Green Irish Tweed', 'EDP', '{GIT}'),
+    ('Creed', 'Silver Mountain Water', 'EDP', '{SMW}'),
+    ('Creed', 'Viking', 'EDP', '{}'),
+    ('Chanel', 'Bleu de Chanel', 'EDP', '{BDC,BdC EDP}'),
+    ('Chanel', 'Bleu de Chanel', 'Parfum', '{BDC Parfum,BdC Parfum}'),
+    ('Chanel', 'Bleu de Chanel', 'EDT', '{BDC EDT,BdC EDT}'),
+    ('Chanel', 'Allure Homme Sport', 'EDT', '{AHS}'),
+    ('Dior', 'Sauvage', 'EDT', '{}'),
+    ('Dior', 'Sauvage', 'EDP', '{}'),
+    ('Dior', 'Sauvage', 'Elixir', '{Sauvage Elixir}'),
+    ('Dior', 'Homme Intense', 'EDP', '{DHI}'),
+    ('Yves Saint Laurent', 'La Nuit de L''Homme', 'EDT', '{LNDLH,La Nuit}'),
+    ('Yves Saint Laurent', 'Y', 'EDP', '{Y EDP}'),
+    ('Yves Saint Laurent', 'Tuxedo', 'EDP', '{}'),
+    ('Giorgio Armani', 'Acqua di Gio', 'EDT', '{ADG}'),
+    ('Giorgio Armani', 'Acqua di Gio Profumo', 'Parfum', '{ADG Profumo,ADGP}'),
+    ('Giorgio Armani', 'Acqua di Gio Profondo', 'EDP', '{ADG Profondo}'),
+    ('Xerjoff', 'Naxos', 'EDP', '{1861 Naxos}'),
+    ('Xerjoff', 'Erba Pura', 'EDP', '{}'),
+    ('Amouage', 'Interlude Man', 'EDP', '{Interlude}'),
+    ('Amouage', 'Reflection Man', 'EDP', '{Reflection}'),
+    ('Le Labo', 'Santal 33', 'EDP', '{}'),
+    ('Le Labo', 'Another 13', 'EDP', '{}'),
+    ('Byredo', 'Gypsy Water', 'EDP', '{}'),
+    ('Initio', 'Side Effect', 'EDP', '{}'),
+    ('Initio', 'Oud for Greatness', 'EDP', '{OFG}'),
+    ('Nishane', 'Hacivat', 'Extrait', '{}'),
+    ('Nishane', 'Ani', 'Extrait', '{}'),
+    ('Louis Vuitton', 'Imagination', 'EDP', '{}'),
+    ('Louis Vuitton', 'Ombre Nomade', 'EDP', '{}'),
+    ('Kilian', 'Angels'' Share', 'EDP', '{Angels Share}'),
+    ('Frederic Malle', 'Portrait of a Lady', 'EDP', '{POAL}'),
+    ('Roja Parfums', 'Elysium', 'Parfum', '{}'),
+    ('Guerlain', 'L''Homme Ideal', 'EDP', '{Homme Ideal}'),
+    ('Hermes', 'Terre d''Hermes', 'EDT', '{TDH,Terre}'),
+    ('Diptyque', 'Tam Dao', 'EDT', '{}'),
+    ('Jean Paul Gaultier', 'Le Male Le Parfum', 'EDP', '{Le Male LP}'),
+    ('Jean Paul Gaultier', 'Ultra Male', 'EDT', '{}'),
+    ('Viktor & Rolf', 'Spicebomb Extreme', 'EDP', '{SBE}'),
+    ('Montblanc', 'Explorer', 'EDP', '{}'),
+    ('Prada', 'L''Homme', 'EDT', '{Prada L''Homme}'),
+    ('Valentino', 'Born in Roma Uomo Intense', 'EDP', '{BIR Intense}'),
+    ('Maison Margiela', 'Replica Jazz Club', 'EDT', '{Jazz Club}'),
+    ('Maison Margiela', 'Replica By the Fireplace', 'EDT', '{By the Fireplace,BTF}'),
+    ('Bvlgari', 'Tygar', 'EDP', '{}')
+) AS f(brand, line, concentration, aliases)
+JOIN brands b ON b.name = f.brand;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_listings_created_at;
+DROP INDEX IF EXISTS idx_listings_market;
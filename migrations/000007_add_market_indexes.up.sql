@@ -0,0 +1,5 @@
+-- Market stats group listings by fragrance and bottle kind over a recent window.
+CREATE INDEX idx_listings_market ON listings(fragrance_id, bottle_kind, created_at)
+    WHERE price_per_ml_cents IS NOT NULL;
+
+CREATE INDEX idx_listings_created_at ON listings(created_at);
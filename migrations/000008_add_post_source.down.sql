@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_posts_trade_type;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS trade_type,
+    DROP COLUMN IF EXISTS source,
+    DROP COLUMN IF EXISTS subreddit;
@@ -0,0 +1,14 @@
+ALTER TABLE posts
+    -- Subreddit the post was found in.
+    ADD COLUMN subreddit VARCHAR(64),
+
+    -- Name of the configured scraper source that found it.
+    ADD COLUMN source VARCHAR(64),
+
+    -- 'sell', 'trade' or 'buy', from the post's tags.
+    ADD COLUMN trade_type VARCHAR(10);
+
+-- Everything scraped before sources existed came from [WTS] posts on r/fragranceswap.
+UPDATE posts SET subreddit = 'fragranceswap', source = 'fragranceswap', trade_type = 'sell';
+
+CREATE INDEX idx_posts_trade_type ON posts(trade_type);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS wants;
+
+DROP INDEX IF EXISTS idx_listings_intent;
+
+ALTER TABLE listings
+    DROP COLUMN IF EXISTS intent;
@@ -0,0 +1,33 @@
+ALTER TABLE listings
+    -- 'sell' (offered for money), 'trade' (only offered in exchange) or 'buy'.
+    ADD COLUMN intent VARCHAR(10) NOT NULL DEFAULT 'sell';
+
+CREATE INDEX idx_listings_intent ON listings(intent);
+
+CREATE TABLE wants (
+    id SERIAL PRIMARY KEY,
+
+    -- The post asking for it (WTT in exchange list, WTB, ISO).
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+
+    -- The name of the perfume wanted.
+    name VARCHAR(255) NOT NULL,
+
+    -- Wanted size as written and its bottle capacity, NULL when any size will do.
+    size VARCHAR(50),
+    capacity_ml NUMERIC(7, 1),
+
+    -- The most the poster will pay, NULL when no budget was given.
+    max_price VARCHAR(50),
+    max_price_cents INTEGER,
+    currency CHAR(3),
+
+    -- Catalog entry the name resolved to.
+    fragrance_id INTEGER REFERENCES fragrances(id) ON DELETE SET NULL,
+    resolve_score REAL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_wants_post_id ON wants(post_id);
+CREATE INDEX idx_wants_fragrance_id ON wants(fragrance_id);
@@ -0,0 +1,20 @@
+CREATE TABLE sellers (
+    -- The reddit username, the same value as posts.seller_username.
+    username VARCHAR(255) PRIMARY KEY,
+
+    -- The seller's user flair as last seen on one of their posts.
+    flair TEXT,
+
+    -- Confirmed trade count read from the flair, NULL if the flair doesn't show one.
+    confirmed_trades INTEGER,
+
+    -- When the flair was last read.
+    flair_updated_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Every seller we already have posts from.
+INSERT INTO sellers (username)
+SELECT DISTINCT seller_username FROM posts WHERE seller_username IS NOT NULL
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,31 @@
+CREATE TABLE parse_runs (
+    id SERIAL PRIMARY KEY,
+
+    -- The post that was parsed. post_id is NULL when the parse failed before the post was stored.
+    reddit_id VARCHAR(20) NOT NULL,
+    post_id INTEGER REFERENCES posts(id) ON DELETE CASCADE,
+
+    -- 'rules' or 'llm', NULL if the parse failed.
+    extracted_by VARCHAR(10),
+
+    -- Which llm was called, NULL when the rules handled the post.
+    provider VARCHAR(32),
+    model VARCHAR(100),
+
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+
+    -- How long the whole parse took.
+    latency_ms INTEGER NOT NULL,
+
+    -- Estimated from the model's token prices, NULL when the price isn't known.
+    cost_usd NUMERIC(12, 6),
+
+    -- Why the parse failed, NULL when it didn't.
+    error TEXT,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_parse_runs_reddit_id ON parse_runs(reddit_id);
+CREATE INDEX idx_parse_runs_created_at ON parse_runs(created_at);
@@ -0,0 +1,4 @@
+ALTER TABLE parse_runs
+    DROP COLUMN IF EXISTS cached;
+
+DROP TABLE IF EXISTS parse_cache;
@@ -0,0 +1,21 @@
+CREATE TABLE parse_cache (
+    -- sha256 of the prompt version, model and normalized post text.
+    key CHAR(64) PRIMARY KEY,
+
+    -- What produced the entry, kept so stale entries can be pruned.
+    prompt_version VARCHAR(64) NOT NULL,
+    model VARCHAR(100) NOT NULL,
+
+    -- The extracted FragranceListing as the llm returned it.
+    listing JSONB NOT NULL,
+
+    hits INTEGER NOT NULL DEFAULT 0,
+    last_hit_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_parse_cache_prompt_version ON parse_cache(prompt_version);
+
+ALTER TABLE parse_runs
+    -- The result came from parse_cache instead of an llm call.
+    ADD COLUMN cached BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_parse_runs_prompt_version;
+
+ALTER TABLE listings
+    DROP COLUMN IF EXISTS prompt_version;
+
+ALTER TABLE parse_runs
+    DROP COLUMN IF EXISTS prompt_version;
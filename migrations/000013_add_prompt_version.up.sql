@@ -0,0 +1,9 @@
+ALTER TABLE parse_runs
+    -- Prompt id (version plus a hash of the prompt text) the llm was sent, NULL for rule parses.
+    ADD COLUMN prompt_version VARCHAR(64);
+
+ALTER TABLE listings
+    -- Prompt id that produced the row, NULL for rule parses and rows from before prompts were versioned.
+    ADD COLUMN prompt_version VARCHAR(64);
+
+CREATE INDEX idx_parse_runs_prompt_version ON parse_runs(prompt_version);
@@ -0,0 +1,26 @@
+-- Posts whose llm output still broke the extraction rules after every repair
+-- attempt, waiting for a human instead of being stored half right.
+CREATE TABLE parse_reviews (
+    id SERIAL PRIMARY KEY,
+    reddit_id VARCHAR(20) UNIQUE NOT NULL,
+
+    -- The post as the worker got it, so an accepted review can be inserted.
+    post JSONB NOT NULL,
+
+    -- The last attempt and what was still wrong with it.
+    listing JSONB NOT NULL,
+    problems TEXT[] NOT NULL DEFAULT '{}',
+    attempts INTEGER NOT NULL,
+    prompt_version VARCHAR(64),
+
+    -- How many times the post has ended up here.
+    seen_count INTEGER NOT NULL DEFAULT 1,
+
+    -- 'pending', 'resolved' or 'ignored'.
+    status VARCHAR(10) NOT NULL DEFAULT 'pending',
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_parse_reviews_status ON parse_reviews(status);
@@ -0,0 +1,19 @@
+-- Back to parallel "sizes" and "prices" arrays, offer details are lost.
+UPDATE parse_reviews r SET listing = jsonb_set(r.listing, '{perfumes}', COALESCE((
+    SELECT jsonb_agg(
+        (p.perfume - 'offers') || jsonb_build_object(
+            'sizes', COALESCE((SELECT jsonb_agg(o.offer->'size' ORDER BY o.n)
+                FROM jsonb_array_elements(p.perfume->'offers') WITH ORDINALITY AS o(offer, n)), '[]'::jsonb),
+            'prices', COALESCE((SELECT jsonb_agg(o.offer->'price' ORDER BY o.n)
+                FROM jsonb_array_elements(p.perfume->'offers') WITH ORDINALITY AS o(offer, n)), '[]'::jsonb)
+        )
+        ORDER BY p.n)
+    FROM jsonb_array_elements(r.listing->'perfumes') WITH ORDINALITY AS p(perfume, n)
+), '[]'::jsonb))
+WHERE jsonb_typeof(r.listing->'perfumes') = 'array'
+    AND EXISTS (SELECT 1 FROM jsonb_array_elements(r.listing->'perfumes') AS p(perfume) WHERE p.perfume ? 'offers');
+
+ALTER TABLE listings
+    DROP COLUMN IF EXISTS notes,
+    DROP COLUMN IF EXISTS quantity,
+    DROP COLUMN IF EXISTS condition;
@@ -0,0 +1,31 @@
+ALTER TABLE listings
+    -- As the seller described it (e.g., 'BNIB', 'used', 'tester'), NULL if not mentioned.
+    ADD COLUMN condition VARCHAR(50),
+
+    -- How many of this size are for sale.
+    ADD COLUMN quantity INTEGER NOT NULL DEFAULT 1,
+
+    -- Anything else said about the offer ('OBO', 'shipped', ...).
+    ADD COLUMN notes TEXT;
+
+-- Perfumes used to carry parallel "sizes" and "prices" arrays, rewrite the
+-- parked reviews into one offer per size so they can be edited in the new
+-- shape. parse_cache entries are left alone, the decoder still reads them and
+-- the schema change gives them a new prompt version anyway.
+UPDATE parse_reviews r SET listing = jsonb_set(r.listing, '{perfumes}', COALESCE((
+    SELECT jsonb_agg(
+        (p.perfume - 'sizes' - 'prices') || jsonb_build_object('offers', COALESCE((
+            SELECT jsonb_agg(jsonb_build_object(
+                'size', s.size,
+                'price', COALESCE(p.perfume->'prices'->>((s.n - 1)::int), ''),
+                'condition', '',
+                'quantity', 1,
+                'notes', ''
+            ) ORDER BY s.n)
+            FROM jsonb_array_elements_text(COALESCE(p.perfume->'sizes', '[]'::jsonb)) WITH ORDINALITY AS s(size, n)
+        ), '[]'::jsonb))
+        ORDER BY p.n)
+    FROM jsonb_array_elements(r.listing->'perfumes') WITH ORDINALITY AS p(perfume, n)
+), '[]'::jsonb))
+WHERE jsonb_typeof(r.listing->'perfumes') = 'array'
+    AND EXISTS (SELECT 1 FROM jsonb_array_elements(r.listing->'perfumes') AS p(perfume) WHERE p.perfume ? 'sizes');
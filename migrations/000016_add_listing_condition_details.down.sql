@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_listings_box;
+DROP INDEX IF EXISTS idx_listings_condition;
+
+ALTER TABLE listings
+    DROP COLUMN IF EXISTS fill_percent,
+    DROP COLUMN IF EXISTS batch_code,
+    DROP COLUMN IF EXISTS box;
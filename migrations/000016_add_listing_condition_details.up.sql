@@ -0,0 +1,30 @@
+ALTER TABLE listings
+    -- 'boxed', 'unboxed' or 'damaged', NULL if the post didn't say.
+    ADD COLUMN box VARCHAR(10),
+
+    -- The batch code as written by the seller.
+    ADD COLUMN batch_code VARCHAR(50),
+
+    -- How full the bottle is, from the post or worked out from a partial's size.
+    ADD COLUMN fill_percent SMALLINT CHECK (fill_percent BETWEEN 0 AND 100);
+
+-- condition was free text until now, fold it into 'new', 'tester' or 'used'
+-- and move what it said about the box to its own column.
+UPDATE listings SET
+    box = CASE
+        WHEN condition ~* 'unbox|no box|without box' THEN 'unboxed'
+        WHEN condition ~* 'damaged box|box damage' THEN 'damaged'
+        WHEN condition ~* 'bnib|in box|boxed' THEN 'boxed'
+    END,
+    condition = CASE
+        WHEN condition ~* 'tester' THEN 'tester'
+        WHEN condition ~* 'bnib|\mnew\M|sealed' THEN 'new'
+        WHEN condition ~* 'used|partial|sprayed' THEN 'used'
+    END
+WHERE condition IS NOT NULL;
+
+UPDATE listings SET fill_percent = ROUND(remaining_ml / capacity_ml * 100)
+WHERE bottle_kind = 'partial' AND capacity_ml > 0 AND remaining_ml <= capacity_ml;
+
+CREATE INDEX idx_listings_condition ON listings(condition) WHERE condition IS NOT NULL;
+CREATE INDEX idx_listings_box ON listings(box) WHERE box IS NOT NULL;
@@ -0,0 +1,25 @@
+-- Spreadsheets, csv files and price list pictures linked from posts, fetched
+-- on their own schedule to fill in "See Spreadsheet" listings.
+CREATE TABLE sheet_links (
+    id SERIAL PRIMARY KEY,
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+
+    -- 'google_sheet', 'csv' or 'image'.
+    kind VARCHAR(20) NOT NULL,
+
+    -- 'pending', 'fetched', 'failed' or 'unsupported'.
+    status VARCHAR(12) NOT NULL DEFAULT 'pending',
+
+    -- Offers read on the last fetch, and why the last fetch failed.
+    offers INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+
+    fetched_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    UNIQUE (post_id, url)
+);
+
+CREATE INDEX idx_sheet_links_status ON sheet_links(status);
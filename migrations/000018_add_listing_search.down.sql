@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_brands_name_trgm;
+DROP INDEX IF EXISTS idx_listings_name_tsv;
+ALTER TABLE listings DROP COLUMN IF EXISTS name_tsv;
+DROP INDEX IF EXISTS idx_listings_name_trgm;
+-- pg_trgm stays installed, anything else built on it would go with it.
@@ -0,0 +1,15 @@
+-- Fuzzy name search. The trigram indexes also serve the existing
+-- name ILIKE '%...%' filter, which was a sequential scan.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_listings_name_trgm ON listings USING GIN (name gin_trgm_ops);
+
+-- Word search over the name. 'simple' because perfume names aren't english,
+-- stemming "Sauvage" or "Tygar" only hurts.
+ALTER TABLE listings ADD COLUMN name_tsv TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(name, ''))) STORED;
+
+CREATE INDEX idx_listings_name_tsv ON listings USING GIN (name_tsv);
+
+-- Brand facets filter on the catalog brand name.
+CREATE INDEX idx_brands_name_trgm ON brands USING GIN (name gin_trgm_ops);
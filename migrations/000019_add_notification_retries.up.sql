@@ -0,0 +1,13 @@
+-- Failed deliveries are retried with backoff until one is sent or
+-- max attempts is reached.
+ALTER TABLE notifications ADD COLUMN attempts INTEGER NOT NULL DEFAULT 1;
+
+-- When the next delivery may be tried, NULL once sent or given up. A claim
+-- pushes it out too, so a matcher that dies mid send doesn't hold the row forever.
+ALTER TABLE notifications ADD COLUMN next_attempt_at TIMESTAMPTZ;
+
+-- Deliveries that already failed get another go.
+UPDATE notifications SET next_attempt_at = NOW() WHERE sent_at IS NULL;
+
+CREATE INDEX idx_notifications_next_attempt_at ON notifications(next_attempt_at)
+    WHERE sent_at IS NULL;
@@ -0,0 +1,2 @@
+-- Nothing to restore, the deleted rows were never going to be fetched.
+SELECT 1;
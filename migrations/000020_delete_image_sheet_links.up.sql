@@ -0,0 +1,4 @@
+-- Picture links are read from the post by the images package now, the sheets
+-- ingester no longer fetches or marks them. Rows it left behind would never
+-- be picked up again.
+DELETE FROM sheet_links WHERE kind = 'image' OR status = 'unsupported';